@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/glopal/sessions/pkg/store"
+	"github.com/spf13/cobra"
+)
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive <key>",
+	Short: "Move a session or artifact into the archive",
+	Long: `Move a session or artifact into .sessions/archive/. Archived sessions are
+hidden from list, query and context unless --include-archived is given.
+Archiving a session archives its artifacts too.
+
+Links from other sessions to an archived session, and a session's reference
+to an archived artifact, are removed; 'sessions restore' adds them back.
+Use --dry-run to see what would change.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runArchive,
+}
+
+var archiveDryRun bool
+
+func init() {
+	archiveCmd.Flags().BoolVar(&archiveDryRun, "dry-run", false, "Show what would be archived without changing anything")
+	rootCmd.AddCommand(archiveCmd)
+}
+
+func runArchive(cmd *cobra.Command, args []string) error {
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	c, err := st.Archive(args[0], archiveDryRun)
+	if err != nil {
+		return err
+	}
+	return printChange(c, archiveDryRun, "Archived", "Would archive")
+}
+
+// changeResult is the JSON result of rm, archive and restore.
+type changeResult struct {
+	Key     string   `json:"key"`
+	Files   []string `json:"files"`
+	Updated []string `json:"updated"`
+	DryRun  bool     `json:"dry_run"`
+}
+
+// printChange reports the files and references affected by rm, archive or
+// restore.
+func printChange(c *store.Change, dryRun bool, done, wouldDo string) error {
+	res := changeResult{Key: c.Key, Files: nonNil(c.Files), Updated: nonNil(c.Updated), DryRun: dryRun}
+	return writeResult(res, func() error {
+		printChangeText(c, dryRun, done, wouldDo)
+		return nil
+	})
+}
+
+func printChangeText(c *store.Change, dryRun bool, done, wouldDo string) {
+	verb := done
+	if dryRun {
+		verb = wouldDo
+	}
+	fmt.Printf("%s %s\n", verb, c.Key)
+	for _, f := range c.Files {
+		fmt.Printf("  %s\n", f)
+	}
+	if len(c.Updated) > 0 {
+		verb = "Updated"
+		if dryRun {
+			verb = "Would update"
+		}
+		fmt.Printf("%s references in %s\n", verb, strings.Join(c.Updated, ", "))
+	}
+}
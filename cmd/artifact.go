@@ -5,12 +5,13 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
+	"time"
 
 	"github.com/glopal/sessions/internal/parser"
-	"github.com/glopal/sessions/internal/root"
 	"github.com/glopal/sessions/internal/session"
+	"github.com/glopal/sessions/internal/templates"
+	"github.com/glopal/sessions/pkg/store"
 	"github.com/spf13/cobra"
 )
 
@@ -24,29 +25,44 @@ var artifactCmd = &cobra.Command{
   ...
   ART
   sessions artifact foo --import f   Body from file f (keeps original)
-  sessions artifact foo --ingest f   Body from file f (deletes original after write)`,
+  sessions artifact foo --ingest f   Body from file f (deletes original after write)
+
+The template and empty imported files start from a body template for the
+artifact's type: Context/Decision/Consequences for a decision,
+Hypothesis/Steps/Findings for an investigation, a timestamped log for a
+debug-log, and so on. A file .sessions/templates/artifacts/<type>.md
+overrides the built-in template for that type, and default.md the one for
+types without their own. Templates use Go text/template syntax, with .Title,
+.Type, .SessionID and .Now available.
+
+With --supersedes KEY the new artifact replaces an earlier one: the artifact
+at KEY is marked superseded and points back at the new one. The target must
+exist and not already be superseded, and chains of supersession cannot form
+cycles. 'sessions status --chain KEY' prints a chain.`,
 	Args: cobra.ExactArgs(1),
 	RunE: runArtifact,
 }
 
 var (
-	artifactSession string
-	artifactType    string
-	artifactImport  string
-	artifactIngest  string
+	artifactSession    string
+	artifactType       string
+	artifactImport     string
+	artifactIngest     string
+	artifactSupersedes string
 )
 
 func init() {
 	artifactCmd.Flags().StringVar(&artifactSession, "session", "", "Session ID to attach to (default: most recent)")
-	artifactCmd.Flags().StringVar(&artifactType, "type", "analysis", "Artifact type: decision, analysis, investigation, architecture, debug-log")
+	artifactCmd.Flags().StringVar(&artifactType, "type", "analysis", "Artifact type, as declared in .sessions/config.yaml")
 	artifactCmd.Flags().StringVar(&artifactImport, "import", "", "File path to import body from (keeps original)")
 	artifactCmd.Flags().StringVar(&artifactIngest, "ingest", "", "File path to ingest body from (deletes original after write)")
+	artifactCmd.Flags().StringVar(&artifactSupersedes, "supersedes", "", "Key of the artifact this one replaces (SESSION_ID/name.md)")
 	rootCmd.AddCommand(artifactCmd)
 }
 
 func runArtifact(cmd *cobra.Command, args []string) error {
 	if artifactImport != "" && artifactIngest != "" {
-		return fmt.Errorf("--import and --ingest are mutually exclusive")
+		return usageErrorf("--import and --ingest are mutually exclusive")
 	}
 	if artifactImport != "" || artifactIngest != "" {
 		return runArtifactFromFile(cmd, args[0])
@@ -59,30 +75,46 @@ func runArtifact(cmd *cobra.Command, args []string) error {
 
 // runArtifactTemplate prints a HEREDOC template to stdout. Does NOT write a file.
 func runArtifactTemplate(name string) error {
-	sessionsDir, err := getSessionsDir()
+	st, err := openStore()
 	if err != nil {
 		return err
 	}
-	sessionID, err := resolveSessionID(sessionsDir)
+	sessionID, err := resolveSessionID(st)
 	if err != nil {
 		return err
 	}
 
+	cfg, err := loadConfig(st)
+	if err != nil {
+		return err
+	}
+	if err := cfg.CheckType(artifactType); err != nil {
+		return err
+	}
+	if artifactSupersedes != "" {
+		if err := checkSupersedable(st, cfg, artifactSupersedes); err != nil {
+			return err
+		}
+	}
+
 	artifactName := ensureMD(name)
 	title := titleFromName(artifactName)
 
-	template := buildArtifactHeredocTemplate(artifactName, sessionID, artifactType, title)
-	fmt.Print(template)
-	return nil
+	body, err := artifactBody(st, sessionID, artifactType, title)
+	if err != nil {
+		return err
+	}
+
+	return printTemplate(buildArtifactHeredocTemplate(artifactName, sessionID, artifactType, cfg.InitialStatus(artifactType), title, artifactSupersedes, body))
 }
 
 // runArtifactFromStdin reads artifact content from stdin, parses it, and writes a file.
 func runArtifactFromStdin(cmd *cobra.Command, name string) error {
-	sessionsDir, err := getSessionsDir()
+	st, err := openStore()
 	if err != nil {
 		return err
 	}
-	sessionID, err := resolveSessionID(sessionsDir)
+	sessionID, err := resolveSessionID(st)
 	if err != nil {
 		return err
 	}
@@ -100,18 +132,20 @@ func runArtifactFromStdin(cmd *cobra.Command, name string) error {
 		return fmt.Errorf("parsing stdin: %w", err)
 	}
 
-	// --type flag overrides stdin type if explicitly set
+	// --type and --supersedes flags override stdin if explicitly set
 	if cmd.Flags().Changed("type") {
 		a.Type = artifactType
 	}
+	if cmd.Flags().Changed("supersedes") {
+		a.Supersedes = artifactSupersedes
+	}
 
 	artifactName := ensureMD(name)
-	path, err := writeArtifact(sessionsDir, sessionID, artifactName, a)
+	path, err := createArtifact(st, sessionID, artifactName, a)
 	if err != nil {
 		return err
 	}
-	fmt.Println(path)
-	return nil
+	return printCreated(session.FormatArtifactKey(sessionID, artifactName), path)
 }
 
 // runArtifactFromFile reads body from a file, optional frontmatter from stdin.
@@ -128,11 +162,11 @@ func runArtifactFromFile(cmd *cobra.Command, name string) error {
 		return fmt.Errorf("reading source file: %w", err)
 	}
 
-	sessionsDir, err := getSessionsDir()
+	st, err := openStore()
 	if err != nil {
 		return err
 	}
-	sessionID, err := resolveSessionID(sessionsDir)
+	sessionID, err := resolveSessionID(st)
 	if err != nil {
 		return err
 	}
@@ -162,17 +196,28 @@ func runArtifactFromFile(cmd *cobra.Command, name string) error {
 			Title:   titleFromName(artifactName),
 			Type:    artifactType,
 			Summary: "",
-			Status:  "draft",
 			Body:    strings.TrimSpace(string(bodyData)),
 		}
 	}
 
-	// --type flag overrides if explicitly set
+	// --type and --supersedes flags override if explicitly set
 	if cmd.Flags().Changed("type") {
 		a.Type = artifactType
 	}
+	if cmd.Flags().Changed("supersedes") {
+		a.Supersedes = artifactSupersedes
+	}
+
+	// An empty file is a stub to fill in later, so it starts from the template
+	if a.Body == "" {
+		body, err := artifactBody(st, sessionID, a.Type, a.Title)
+		if err != nil {
+			return err
+		}
+		a.Body = strings.TrimSpace(body)
+	}
 
-	path, err := writeArtifact(sessionsDir, sessionID, artifactName, a)
+	path, err := createArtifact(st, sessionID, artifactName, a)
 	if err != nil {
 		return err
 	}
@@ -184,29 +229,43 @@ func runArtifactFromFile(cmd *cobra.Command, name string) error {
 		}
 	}
 
-	fmt.Println(path)
-	return nil
+	return printCreated(session.FormatArtifactKey(sessionID, artifactName), path)
 }
 
 // buildArtifactHeredocTemplate builds the HEREDOC template string for stdout.
-func buildArtifactHeredocTemplate(name, sessionID, artifactType, title string) string {
+// supersedes, if set, is passed on as the --supersedes flag.
+func buildArtifactHeredocTemplate(name, sessionID, artifactType, status, title, supersedes, body string) string {
 	var b strings.Builder
 
 	b.WriteString("Run the following command with an updated HEREDOC.\n\n")
-	fmt.Fprintf(&b, "sessions artifact %s --session %s <<ART\n", strings.TrimSuffix(name, ".md"), sessionID)
+	fmt.Fprintf(&b, "sessions artifact %s --session %s", strings.TrimSuffix(name, ".md"), sessionID)
+	if supersedes != "" {
+		fmt.Fprintf(&b, " --supersedes %s", supersedes)
+	}
+	b.WriteString(" <<ART\n")
 	b.WriteString("---\n")
 	fmt.Fprintf(&b, "title: %s\n", title)
 	fmt.Fprintf(&b, "type: %s\n", artifactType)
 	b.WriteString("summary: \"\"\n")
-	b.WriteString("status: draft\n")
+	fmt.Fprintf(&b, "status: %s\n", status)
 	b.WriteString("supersedes: \"\"\n")
 	b.WriteString("---\n\n")
-	b.WriteString("Content goes here.\n")
+	b.WriteString(strings.TrimSpace(body) + "\n")
 	b.WriteString("ART\n")
 
 	return b.String()
 }
 
+// artifactBody renders the body template for a new artifact.
+func artifactBody(st *store.Store, sessionID, artifactType, title string) (string, error) {
+	return templates.ArtifactBody(st.Dir(), templates.Artifact{
+		Title:     title,
+		Type:      artifactType,
+		SessionID: sessionID,
+		Now:       time.Now(),
+	})
+}
+
 // titleFromName derives a title from an artifact filename.
 // "sessions-new-spec.md" → "Sessions New Spec"
 func titleFromName(name string) string {
@@ -215,99 +274,41 @@ func titleFromName(name string) string {
 	return titleCase(spaced)
 }
 
-// writeArtifact writes an artifact file and updates the parent session's artifacts list.
-func writeArtifact(sessionsDir, sessionID, name string, a *session.Artifact) (string, error) {
-	// Validate session file exists
-	sessionFile := session.ResolveSessionPath(sessionsDir, sessionID)
-	if _, err := os.Stat(sessionFile); os.IsNotExist(err) {
-		return "", fmt.Errorf("session file not found: %s", sessionFile)
-	}
-
-	// Create artifact subdirectory
-	artifactDir := session.ResolveArtifactDir(sessionsDir, sessionID)
-	if err := os.MkdirAll(artifactDir, 0755); err != nil {
-		return "", fmt.Errorf("creating artifact subdirectory: %w", err)
-	}
-
-	// Write artifact file
-	artifactPath := filepath.Join(artifactDir, name)
-	if err := parser.WriteArtifactFile(artifactPath, a); err != nil {
-		return "", fmt.Errorf("writing artifact: %w", err)
-	}
-
-	// Update parent session's artifacts list
-	if err := addArtifactToSession(sessionFile, name, a); err != nil {
-		return "", fmt.Errorf("updating session file: %w", err)
-	}
-
-	return artifactPath, nil
-}
-
-// resolveSessionID resolves the --session flag or finds the most recent session.
-func resolveSessionID(sessionsDir string) (string, error) {
-	if artifactSession != "" {
-		return artifactSession, nil
-	}
-	return findMostRecentSession(sessionsDir)
-}
-
-// getSessionsDir returns the sessions directory, ensuring it exists.
-func getSessionsDir() (string, error) {
-	sessionsDir, err := root.SessionsDir()
+// createArtifact checks a new artifact against the store's config, writes
+// it and returns its file path. An artifact without a status starts in the
+// initial status of its type. An artifact the config does not allow is
+// reported as store.ErrInvalid.
+func createArtifact(st *store.Store, sessionID, name string, a *session.Artifact) (string, error) {
+	cfg, err := loadConfig(st)
 	if err != nil {
 		return "", err
 	}
-	if err := ensureSessionsDir(sessionsDir); err != nil {
-		return "", err
+	if err := cfg.CheckType(a.Type); err != nil {
+		return "", &store.Error{Kind: store.ErrInvalid, Err: err}
 	}
-	return sessionsDir, nil
-}
-
-func findMostRecentSession(sessionsDir string) (string, error) {
-	sessionsSubDir := filepath.Join(sessionsDir, "sessions")
-	ymDirs, err := os.ReadDir(sessionsSubDir)
-	if err != nil {
-		return "", fmt.Errorf("reading sessions directory: %w", err)
+	if a.Status == "" {
+		a.Status = cfg.InitialStatus(a.Type)
 	}
-
-	var sessionIDs []string
-	for _, ym := range ymDirs {
-		if !ym.IsDir() {
-			continue
-		}
-		entries, err := os.ReadDir(filepath.Join(sessionsSubDir, ym.Name()))
-		if err != nil {
-			continue
-		}
-		for _, e := range entries {
-			if e.IsDir() || !strings.HasSuffix(e.Name(), ".md") {
-				continue
-			}
-			sessionIDs = append(sessionIDs, strings.TrimSuffix(e.Name(), ".md"))
+	if err := cfg.CheckStatus(a.Type, a.Status); err != nil {
+		return "", &store.Error{Kind: store.ErrInvalid, Err: err}
+	}
+	if a.Supersedes != "" {
+		if err := checkSupersedable(st, cfg, a.Supersedes); err != nil {
+			return "", err
 		}
 	}
-
-	if len(sessionIDs) == 0 {
-		return "", fmt.Errorf("no sessions found; create one with 'sessions new'")
+	if err := st.CreateArtifact(sessionID, name, a); err != nil {
+		return "", err
 	}
-
-	sort.Strings(sessionIDs)
-	return sessionIDs[len(sessionIDs)-1], nil
+	return st.Path(session.FormatArtifactKey(sessionID, name))
 }
 
-func addArtifactToSession(sessionFile, artifactName string, a *session.Artifact) error {
-	s, err := parser.ParseSessionFile(sessionFile)
-	if err != nil {
-		return err
+// resolveSessionID resolves the --session flag or finds the most recent session.
+func resolveSessionID(st *store.Store) (string, error) {
+	if artifactSession != "" {
+		return artifactSession, nil
 	}
-
-	s.Artifacts = append(s.Artifacts, session.ArtifactRef{
-		Path:    artifactName,
-		Type:    a.Type,
-		Summary: a.Summary,
-	})
-
-	return parser.WriteSessionFile(sessionFile, s)
+	return st.MostRecent()
 }
 
 // ensureMD appends .md to a name if not already present.
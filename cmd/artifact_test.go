@@ -31,7 +31,7 @@ func TestTitleFromName(t *testing.T) {
 
 func TestBuildArtifactHeredocTemplate(t *testing.T) {
 	t.Run("default structure", func(t *testing.T) {
-		out := buildArtifactHeredocTemplate("foo.md", "1771969857", "analysis", "Foo")
+		out := buildArtifactHeredocTemplate("foo.md", "1771969857", "analysis", "draft", "Foo", "", "Content goes here.")
 
 		if !strings.Contains(out, "sessions artifact foo --session 1771969857 <<ART") {
 			t.Error("expected HEREDOC command with name (without .md) and session ID")
@@ -60,7 +60,7 @@ func TestBuildArtifactHeredocTemplate(t *testing.T) {
 	})
 
 	t.Run("custom type", func(t *testing.T) {
-		out := buildArtifactHeredocTemplate("my-design.md", "123456", "decision", "My Design")
+		out := buildArtifactHeredocTemplate("my-design.md", "123456", "decision", "draft", "My Design", "", "Content goes here.")
 		if !strings.Contains(out, "type: decision") {
 			t.Error("expected custom type in output")
 		}
@@ -68,11 +68,18 @@ func TestBuildArtifactHeredocTemplate(t *testing.T) {
 			t.Error("expected correct command with custom values")
 		}
 	})
+
+	t.Run("supersedes", func(t *testing.T) {
+		out := buildArtifactHeredocTemplate("adr-v2.md", "123456", "decision", "draft", "Adr V2", "123000/adr.md", "Content goes here.")
+		if !strings.Contains(out, "sessions artifact adr-v2 --session 123456 --supersedes 123000/adr.md <<ART") {
+			t.Error("expected --supersedes in command")
+		}
+	})
 }
 
 func TestArtifactRoundTrip(t *testing.T) {
 	// Build a template, extract the HEREDOC content, parse with real parser, verify fields
-	out := buildArtifactHeredocTemplate("sessions-new-spec.md", "1771969857", "analysis", "Sessions New Spec")
+	out := buildArtifactHeredocTemplate("sessions-new-spec.md", "1771969857", "analysis", "draft", "Sessions New Spec", "", "Content goes here.")
 
 	// Extract content between "<<ART\n" and "\nART\n"
 	startMarker := "<<ART\n"
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var attachCommitCmd = &cobra.Command{
+	Use:   "attach-commit <session> <commit>",
+	Short: "Record a commit made as part of a session",
+	Long: `Record a commit made as part of a session in its git.commits list. The
+commit may be any ref git understands (HEAD, a branch, an abbreviated hash);
+it is stored as a full hash and can then be found with 'query --commit'.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runAttachCommit,
+}
+
+func init() {
+	rootCmd.AddCommand(attachCommitCmd)
+}
+
+func runAttachCommit(cmd *cobra.Command, args []string) error {
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	id, rev := args[0], args[1]
+	sha, err := gitOutput("rev-parse", "--verify", "--quiet", "--end-of-options", rev+"^{commit}")
+	if err != nil {
+		return fmt.Errorf("%q is not a commit in this repository", rev)
+	}
+
+	added, err := st.AttachCommit(id, sha)
+	if err != nil {
+		return err
+	}
+	return writeResult(attachCommitResult{SessionID: id, Commit: sha, Added: added}, func() error {
+		if !added {
+			fmt.Printf("Commit %s already attached to %s\n", shortSHA(sha), id)
+			return nil
+		}
+		fmt.Printf("Attached %s to %s\n", shortSHA(sha), id)
+		return nil
+	})
+}
+
+// attachCommitResult is the JSON result of attach-commit. Added is false if
+// the commit was already attached.
+type attachCommitResult struct {
+	SessionID string `json:"session_id"`
+	Commit    string `json:"commit"`
+	Added     bool   `json:"added"`
+}
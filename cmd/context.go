@@ -3,172 +3,843 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
+	"slices"
+	"sort"
 	"strings"
 
-	"github.com/glopal/sessions/internal/root"
+	"github.com/glopal/sessions/internal/budget"
+	"github.com/glopal/sessions/internal/config"
 	"github.com/glopal/sessions/internal/session"
+	"github.com/glopal/sessions/pkg/store"
+	"github.com/gobwas/glob"
 	"github.com/spf13/cobra"
 )
 
 var contextCmd = &cobra.Command{
 	Use:   "context [files...]",
 	Short: "Build a context bundle for a file or topic",
-	Args:  cobra.MinimumNArgs(1),
-	RunE:  runContext,
+	Long: `Build a context bundle for one or more files: every session that changed
+them, with their artifacts and status.
+
+Each argument may be a file path, a directory (matching every recorded file
+below it), or a glob such as 'internal/**/*.go'. The bundle has one section
+per matched file. Renames are followed: sessions that changed a file under
+an earlier name, recorded with 'from:' on a renamed change, are included.
+
+Instead of listing files, --diff, --staged or --worktree take the changed
+files from git and build one bundle for all of them, listing each session
+once:
+
+  sessions context --diff main...HEAD   Files changed on the current branch
+  sessions context --staged             Files staged for commit
+  sessions context --worktree           All uncommitted changes, including untracked files
+
+With --max-tokens, the bundle is fitted into an approximate token budget.
+Sessions are prioritized by how many of the requested files they touched,
+recency, and the status of their artifacts (accepted first, superseded and
+deprecated last). Lower-priority sessions are condensed or omitted and
+artifact bodies truncated, and what was trimmed is listed at the end.
+
+Artifacts that have been superseded are replaced by the head of their
+supersession chain, the version that replaced all others, and listed as
+replaced by it. --history shows every version instead.`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runContext,
 }
 
 var (
-	contextDeep   bool
-	contextFormat string
+	contextDeep      bool
+	contextFormat    string
+	contextMaxTokens int
+	contextDiff      string
+	contextStaged    bool
+	contextWorktree  bool
+	contextArchived  bool
+	contextHistory   bool
 )
 
 func init() {
 	contextCmd.Flags().BoolVar(&contextDeep, "deep", false, "Include artifact bodies in output")
 	contextCmd.Flags().StringVar(&contextFormat, "format", "markdown", "Output format: markdown or json")
+	contextCmd.Flags().MarkDeprecated("format", "use --output json")
+	contextCmd.Flags().IntVar(&contextMaxTokens, "max-tokens", 0, "Fit the bundle into about N tokens (0 = no limit)")
+	contextCmd.Flags().StringVar(&contextDiff, "diff", "", "Build context for the files changed in a git revision or range")
+	contextCmd.Flags().BoolVar(&contextStaged, "staged", false, "Build context for the files staged in git")
+	contextCmd.Flags().BoolVar(&contextWorktree, "worktree", false, "Build context for all uncommitted files in git")
+	contextCmd.Flags().BoolVar(&contextArchived, "include-archived", false, "Include archived sessions")
+	contextCmd.Flags().BoolVar(&contextHistory, "history", false, "Show superseded artifacts instead of the head of their chain")
 	rootCmd.AddCommand(contextCmd)
 }
 
 func runContext(cmd *cobra.Command, args []string) error {
-	sessionsDir, err := root.SessionsDir()
+	if contextMaxTokens < 0 {
+		return usageErrorf("--max-tokens must not be negative")
+	}
+	fromGit := 0
+	for _, set := range []bool{contextDiff != "", contextStaged, contextWorktree} {
+		if set {
+			fromGit++
+		}
+	}
+	if fromGit > 1 {
+		return usageErrorf("--diff, --staged and --worktree are mutually exclusive")
+	}
+	if fromGit == 1 && len(args) > 0 {
+		return usageErrorf("file arguments cannot be used with --diff, --staged or --worktree")
+	}
+	if fromGit == 0 && len(args) == 0 {
+		return usageErrorf("requires at least 1 file, or one of --diff, --staged or --worktree")
+	}
+
+	st, err := openStore()
 	if err != nil {
 		return err
 	}
-
-	if err := ensureSessionsDir(sessionsDir); err != nil {
+	cfg, err := loadConfig(st)
+	if err != nil {
 		return err
 	}
 
-	sessions, err := loadAllSessions(sessionsDir)
+	list := st.List
+	if contextArchived {
+		list = st.ListAll
+	}
+	sessions, err := list()
 	if err != nil {
 		return err
 	}
 
+	var groups []*contextGroup
+	if fromGit == 1 {
+		label, changed, err := gitContextFiles()
+		if err != nil {
+			return err
+		}
+		changed = withoutSessionsDir(changed)
+		groups = []*contextGroup{collectDiffContext(st, cfg, sessions, label, changed)}
+	} else {
+		groups = collectContext(st, cfg, sessions, args)
+	}
+	if !contextHistory {
+		if err := showChainHeads(st, cfg, groups); err != nil {
+			return err
+		}
+	}
+	if jsonOutput() {
+		// Fitting the bundle to --max-tokens renders it, so only the output
+		// asked for is built.
+		return writeResult(contextResult{Bundles: buildContextJSON(groups, contextDeep, contextMaxTokens)}, nil)
+	}
 	if contextFormat == "json" {
-		return outputContextJSON(sessionsDir, sessions, args)
+		return outputContextJSON(groups)
+	}
+	return outputContextMarkdown(groups)
+}
+
+// contextResult is the JSON result of context: one bundle per file, or a
+// single one for --diff, --staged or --worktree.
+type contextResult struct {
+	Bundles []contextJSONOutput `json:"bundles"`
+}
+
+func (r contextResult) items() any { return r.Bundles }
+
+// gitContextFiles returns the files changed in the diff selected by the
+// --diff, --staged or --worktree flag, and a label for it.
+func gitContextFiles() (string, []session.FileChange, error) {
+	switch {
+	case contextDiff != "":
+		files, err := getGitDiffFiles(contextDiff, false)
+		return contextDiff, files, err
+	case contextStaged:
+		files, err := getGitDiffFiles("", true)
+		return "staged changes", files, err
+	default:
+		files, err := getGitStatusFiles()
+		return "working tree changes", files, err
 	}
-	return outputContextMarkdown(sessionsDir, sessions, args)
 }
 
-func outputContextMarkdown(sessionsDir string, sessions []*session.Session, files []string) error {
-	for _, file := range files {
-		fmt.Printf("# Context: %s\n\n", file)
+// contextGroup is the context for one requested file, or for all the files
+// in a git diff.
+type contextGroup struct {
+	File        string
+	Pattern     string   // the argument that matched File, if it was not File itself
+	RenamedFrom []string // earlier paths of File
+	Diff        string   // the diff the group covers, instead of File
+	Files       []string // the files changed in Diff
+	Entries     []*contextEntry
+	Omitted     []contextOmission
+}
+
+// title names what the group is the context for.
+func (g *contextGroup) title() string {
+	if g.Diff != "" {
+		return g.Diff
+	}
+	return g.File
+}
+
+// contextEntry is a session that changed a requested file.
+type contextEntry struct {
+	File      string // the group's file, if it has one; changes may be to earlier names of it
+	Session   *session.Session
+	Changes   []session.FileChange // the session's changes to the group's files
+	Artifacts []*contextArtifact
+	Priority  float64
+	Level     budget.Level
+}
 
-		found := false
-		for _, s := range sessions {
+type contextArtifact struct {
+	Key      string
+	Ref      session.ArtifactRef // Path is the full key for an artifact of another session
+	Artifact *session.Artifact   // nil if the artifact could not be loaded
+	Body     string              // body to show, possibly truncated; empty if not shown
+	Level    budget.Level
+	Replaces []string // keys of the superseded artifacts this one is shown in place of
+	Weight   float64  // the statusWeight of Artifact
+}
+
+// contextOmission reports an entry or artifact body that was condensed,
+// truncated or left out to fit the token budget.
+type contextOmission struct {
+	Key    string `json:"key"`
+	Level  string `json:"level"`
+	Tokens int    `json:"tokens"`
+}
+
+// collectContext finds, for each file matched by args, the sessions that
+// changed it or a path it was renamed from, most recent first, and loads
+// their artifacts.
+func collectContext(st *store.Store, cfg *config.Config, sessions []*session.Session, args []string) []*contextGroup {
+	targets := resolveContextTargets(sessions, contextRenames(st, sessions), args)
+	var groups []*contextGroup
+	touched := make(map[string]int)
+	rank := make(map[*contextEntry]int)
+	for _, target := range targets {
+		g := &contextGroup{File: target.File, Pattern: target.Pattern, RenamedFrom: target.Names[1:]}
+		for i, s := range sessions {
 			for _, fc := range s.FilesChanged {
-				if fc.Path == file {
-					found = true
-					summary := s.Summary
-					if summary == "" {
-						summary = "(no summary)"
-					}
-					fmt.Printf("## %s — %s\n", s.SessionID, summary)
-					fmt.Printf("- **Action:** %s\n", fc.Action)
-					fmt.Printf("- **Change:** %s\n", fc.Summary)
-					if len(s.Tags) > 0 {
-						fmt.Printf("- **Tags:** %s\n", strings.Join(s.Tags, ", "))
-					}
-
-					for _, art := range s.Artifacts {
-						status := ""
-						a, err := loadArtifact(sessionsDir, s.SessionID, art.Path)
-						if err == nil {
-							status = a.Status
-						}
-						statusStr := ""
-						if status != "" {
-							statusStr = fmt.Sprintf(" (%s)", status)
-						}
-						fmt.Printf("- **%s:** %s%s\n", capitalize(art.Type), art.Path, statusStr)
-
-						if contextDeep && a != nil && a.Body != "" {
-							fmt.Printf("\n### %s\n\n%s\n\n", a.Title, a.Body)
-						}
-					}
-					fmt.Println()
-					break
+				if !slices.Contains(target.Names, fc.Path) {
+					continue
+				}
+				e := newContextEntry(st, cfg, s, target.File, []session.FileChange{fc})
+				g.Entries = append(g.Entries, e)
+				touched[s.SessionID]++
+				rank[e] = i
+				break
+			}
+		}
+		groups = append(groups, g)
+	}
+
+	for _, g := range groups {
+		for _, e := range g.Entries {
+			e.Priority = contextPriority(e, float64(touched[e.Session.SessionID])/float64(len(groups)), rank[e], len(sessions))
+		}
+	}
+	return groups
+}
+
+// collectDiffContext builds a single bundle for a set of changed files, as
+// reported by git. Each session that changed any of them, under its current
+// or an earlier name, is listed once with all of its matching changes.
+func collectDiffContext(st *store.Store, cfg *config.Config, sessions []*session.Session, label string, changed []session.FileChange) *contextGroup {
+	g := &contextGroup{Diff: label}
+	renamedFrom := contextRenames(st, sessions)
+	names := make(map[string]bool)
+	for _, fc := range changed {
+		g.Files = append(g.Files, fc.Path)
+		for _, name := range renameHistory(fc.Path, renamedFrom) {
+			names[name] = true
+		}
+		if fc.From != "" {
+			names[fc.From] = true
+		}
+	}
+
+	for i, s := range sessions {
+		var changes []session.FileChange
+		for _, fc := range s.FilesChanged {
+			if names[fc.Path] {
+				changes = append(changes, fc)
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		e := newContextEntry(st, cfg, s, "", changes)
+		e.Priority = contextPriority(e, float64(len(changes))/float64(len(changed)), i, len(sessions))
+		g.Entries = append(g.Entries, e)
+	}
+	return g
+}
+
+// newContextEntry loads the artifacts of a session for a context entry.
+func newContextEntry(st *store.Store, cfg *config.Config, s *session.Session, file string, changes []session.FileChange) *contextEntry {
+	e := &contextEntry{File: file, Session: s, Changes: changes, Level: budget.Full}
+	for _, ref := range s.Artifacts {
+		ca := &contextArtifact{Key: session.FormatArtifactKey(s.SessionID, ref.Path), Ref: ref, Level: budget.Full}
+		ca.Artifact, _ = st.Artifact(s.SessionID, ref.Path)
+		if ca.Artifact != nil {
+			ca.Weight = statusWeight(cfg, ca.Artifact.Type, ca.Artifact.Status)
+		}
+		e.Artifacts = append(e.Artifacts, ca)
+	}
+	return e
+}
+
+// showChainHeads replaces each superseded artifact in groups with the head
+// of its supersession chain. A head already listed in the group absorbs the
+// artifacts it replaces; otherwise it is loaded and shown in place of the
+// first of them in each entry.
+func showChainHeads(st *store.Store, cfg *config.Config, groups []*contextGroup) error {
+	heads, err := st.Heads()
+	if err != nil || len(heads) == 0 {
+		return err
+	}
+	for _, g := range groups {
+		listed := make(map[string]*contextArtifact)
+		for _, e := range g.Entries {
+			for _, ca := range e.Artifacts {
+				listed[ca.Key] = ca
+			}
+		}
+		for _, e := range g.Entries {
+			added := make(map[string]*contextArtifact)
+			var kept []*contextArtifact
+			for _, ca := range e.Artifacts {
+				head, ok := heads[ca.Key]
+				if !ok {
+					kept = append(kept, ca)
+					continue
+				}
+				if h := listed[head]; h != nil {
+					h.Replaces = append(h.Replaces, ca.Key)
+					continue
+				}
+				if h := added[head]; h != nil {
+					h.Replaces = append(h.Replaces, ca.Key)
+					continue
+				}
+				h := &contextArtifact{Key: head, Level: budget.Full, Replaces: []string{ca.Key}}
+				h.Artifact, _ = st.GetArtifact(head)
+				h.Ref.Path = head
+				if id, name, _ := session.ParseKey(head); id == e.Session.SessionID {
+					h.Ref.Path = name
+				}
+				if h.Artifact != nil {
+					h.Ref.Type, h.Ref.Summary = h.Artifact.Type, h.Artifact.Summary
+					h.Weight = statusWeight(cfg, h.Artifact.Type, h.Artifact.Status)
+				}
+				added[head] = h
+				kept = append(kept, h)
+			}
+			e.Artifacts = kept
+		}
+	}
+	return nil
+}
+
+// contextPriority scores an entry from the share of requested files its
+// session touched, its rank among all sessions (most recent first), and the
+// best status among its artifacts.
+func contextPriority(e *contextEntry, relevance float64, rank, total int) float64 {
+	recency := 1 - float64(rank)/float64(total)
+	best := 0.0
+	for _, ca := range e.Artifacts {
+		best = max(best, ca.Weight)
+	}
+	return 2*relevance + recency + 0.5*best
+}
+
+// contextTarget is a file to build context for.
+type contextTarget struct {
+	File    string
+	Pattern string   // the argument that matched File, if it was not File itself
+	Names   []string // File followed by the paths it was renamed from
+}
+
+// resolveContextTargets expands each argument into the recorded files it
+// matches. An argument is an exact path, a directory (with or without a
+// trailing slash) matching every file below it, or a glob in which * stays
+// within one path segment and ** crosses segments. Files that were renamed
+// are followed back through their earlier paths, and an earlier path that
+// is already covered by a matched file's history is not listed on its own.
+func resolveContextTargets(sessions []*session.Session, renamedFrom map[string][]string, args []string) []contextTarget {
+	known := make(map[string]bool)
+	for _, s := range sessions {
+		for _, fc := range s.FilesChanged {
+			known[fc.Path] = true
+		}
+	}
+	for p := range renamedFrom {
+		known[p] = true
+	}
+	paths := make([]string, 0, len(known))
+	for p := range known {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var targets []contextTarget
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		match := contextMatcher(arg)
+		var matched []string
+		for _, p := range paths {
+			if p != arg && match(p) {
+				matched = append(matched, p)
+			}
+		}
+		if known[arg] || len(matched) == 0 {
+			// Exact paths, and arguments matching nothing, get a group of their own.
+			matched = append([]string{arg}, matched...)
+		}
+
+		history := make(map[string][]string)
+		covered := make(map[string]bool)
+		for _, p := range matched {
+			history[p] = renameHistory(p, renamedFrom)
+			for _, old := range history[p][1:] {
+				covered[old] = true
+			}
+		}
+		for _, p := range matched {
+			if seen[p] || (covered[p] && p != arg) {
+				continue
+			}
+			seen[p] = true
+			t := contextTarget{File: p, Names: history[p]}
+			if p != arg {
+				t.Pattern = arg
+			}
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// contextMatcher returns a predicate for the paths an argument selects
+// besides itself: files below it as a directory, or matches of it as a glob.
+func contextMatcher(arg string) func(string) bool {
+	if strings.ContainsAny(arg, "*?[{") {
+		if g, err := glob.Compile(arg, '/'); err == nil {
+			return g.Match
+		}
+	}
+	dir := strings.TrimSuffix(arg, "/") + "/"
+	return func(p string) bool {
+		return strings.HasPrefix(p, dir)
+	}
+}
+
+// contextRenames returns the rename map of sessions, including the renames
+// recorded by 'sessions paths migrate'.
+func contextRenames(st *store.Store, sessions []*session.Session) map[string][]string {
+	renames, err := st.Renames()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	return renameMap(sessions, renames)
+}
+
+// renameMap maps each recorded path to the paths it was renamed from, both
+// in files_changed and in renames, which maps old paths to new ones.
+func renameMap(sessions []*session.Session, renames map[string]string) map[string][]string {
+	renamedFrom := make(map[string][]string)
+	add := func(cur, old string) {
+		if old != "" && old != cur && !slices.Contains(renamedFrom[cur], old) {
+			renamedFrom[cur] = append(renamedFrom[cur], old)
+		}
+	}
+	for _, s := range sessions {
+		for _, fc := range s.FilesChanged {
+			add(fc.Path, fc.From)
+		}
+	}
+	for cur, olds := range store.RenamedFrom(renames) {
+		for _, old := range olds {
+			add(cur, old)
+		}
+	}
+	return renamedFrom
+}
+
+// renameHistory returns path followed by every path it was renamed from,
+// most recent first.
+func renameHistory(path string, renamedFrom map[string][]string) []string {
+	names := []string{path}
+	for i := 0; i < len(names); i++ {
+		for _, old := range renamedFrom[names[i]] {
+			if !slices.Contains(names, old) {
+				names = append(names, old)
+			}
+		}
+	}
+	return names
+}
+
+// statusWeight ranks artifacts by how much their content can still be relied
+// on. Stale statuses weigh least. The other statuses of the artifact's type
+// weigh more the further along its lifecycle they are, from its initial
+// status to its final one, which weighs most. Undeclared statuses fall in
+// between.
+func statusWeight(cfg *config.Config, typ, status string) float64 {
+	if cfg.Stale(status) {
+		return 0.1
+	}
+	var lifecycle []string
+	for _, name := range cfg.StatusesFor(typ) {
+		if !cfg.Stale(name) {
+			lifecycle = append(lifecycle, name)
+		}
+	}
+	i := slices.Index(lifecycle, status)
+	switch {
+	case i < 0:
+		return 0.5
+	case len(lifecycle) == 1:
+		return 1
+	}
+	return 0.6 + 0.4*float64(i)/float64(len(lifecycle)-1)
+}
+
+// contextRenderer renders the parts of a bundle whose cost counts against the budget.
+type contextRenderer struct {
+	group func(g *contextGroup) string
+	entry func(e *contextEntry, condensed bool) string
+}
+
+// fitContext decides how much of each entry and artifact body to show. With
+// maxTokens zero everything is shown in full; otherwise entries are condensed
+// or omitted and bodies truncated, lowest priority first, and each group
+// records what was trimmed.
+func fitContext(groups []*contextGroup, deep bool, maxTokens int, r contextRenderer) {
+	type ref struct {
+		g *contextGroup
+		e *contextEntry
+		a *contextArtifact
+	}
+	limit := maxTokens
+	if limit == 0 {
+		limit = math.MaxInt
+	}
+	var items []budget.Item
+	var refs []ref
+	for _, g := range groups {
+		// File headings are always shown.
+		limit = max(limit-budget.Estimate(r.group(g)), 0)
+		for _, e := range g.Entries {
+			parent := len(items)
+			items = append(items, budget.Item{Priority: e.Priority, Parent: -1, Text: r.entry(e, false), Short: r.entry(e, true)})
+			refs = append(refs, ref{g: g, e: e})
+			for _, ca := range e.Artifacts {
+				if !deep || ca.Artifact == nil || ca.Artifact.Body == "" {
+					continue
 				}
+				// Bodies rank below every session heading of similar priority:
+				// knowing a session exists is worth more than detail on one.
+				items = append(items, budget.Item{
+					Priority:    e.Priority * ca.Weight / 2,
+					Parent:      parent,
+					Text:        ca.Artifact.Body,
+					Truncatable: true,
+				})
+				refs = append(refs, ref{g: g, e: e, a: ca})
 			}
 		}
+	}
+
+	choices := budget.Fit(items, limit)
 
-		if !found {
-			fmt.Printf("No sessions found for %s\n\n", file)
+	for i, c := range choices {
+		ref := refs[i]
+		if ref.a != nil {
+			ref.a.Level = c.Level
+			ref.a.Body = c.Text
+			if c.Level != budget.Full {
+				ref.g.Omitted = append(ref.g.Omitted, contextOmission{Key: ref.a.Key, Level: "body " + c.Level.String(), Tokens: budget.Estimate(items[i].Text)})
+			}
+		} else {
+			ref.e.Level = c.Level
+			if c.Level != budget.Full {
+				key := session.FormatSessionKey(ref.e.Session.SessionID)
+				ref.g.Omitted = append(ref.g.Omitted, contextOmission{Key: key, Level: c.Level.String(), Tokens: budget.Estimate(items[i].Text)})
+			}
 		}
 	}
+}
+
+func outputContextMarkdown(groups []*contextGroup) error {
+	fitContext(groups, contextDeep, contextMaxTokens, contextRenderer{
+		group: markdownContextGroup,
+		entry: markdownContextEntry,
+	})
+
+	var b strings.Builder
+	var omitted []string
+	for _, g := range groups {
+		b.WriteString(markdownContextGroup(g))
+		for _, e := range g.Entries {
+			switch e.Level {
+			case budget.Full:
+				writeMarkdownContextEntry(&b, e)
+			case budget.Condensed:
+				b.WriteString(markdownContextEntry(e, true))
+			}
+		}
+		for _, o := range g.Omitted {
+			omitted = append(omitted, fmt.Sprintf("- %s (%s): %s, ~%d tokens\n", o.Key, g.title(), o.Level, o.Tokens))
+		}
+	}
+	if len(omitted) > 0 {
+		fmt.Fprintf(&b, "# Omitted to fit %d tokens\n\n", contextMaxTokens)
+		for _, line := range omitted {
+			b.WriteString(line)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Print(b.String())
 	return nil
 }
 
+func markdownContextGroup(g *contextGroup) string {
+	s := fmt.Sprintf("# Context: %s\n\n", g.title())
+	if g.Diff != "" {
+		s += fmt.Sprintf("Changed files: %s\n\n", strings.Join(g.Files, ", "))
+	}
+	if g.Pattern != "" {
+		s += fmt.Sprintf("Matched by %s\n", g.Pattern)
+	}
+	if len(g.RenamedFrom) > 0 {
+		s += fmt.Sprintf("Renamed from %s\n", strings.Join(g.RenamedFrom, ", "))
+	}
+	if g.Pattern != "" || len(g.RenamedFrom) > 0 {
+		s += "\n"
+	}
+	if len(g.Entries) == 0 {
+		s += fmt.Sprintf("No sessions found for %s\n\n", g.title())
+	}
+	return s
+}
+
+// markdownContextEntry renders an entry without artifact bodies. Condensed
+// entries keep only the session heading and the change to the file, or the
+// changed paths for a diff.
+func markdownContextEntry(e *contextEntry, condensed bool) string {
+	if condensed && e.File == "" {
+		var paths []string
+		for _, fc := range e.Changes {
+			paths = append(paths, fc.Path)
+		}
+		return fmt.Sprintf("%s- **Changes:** %s\n\n", markdownContextHeading(e), strings.Join(paths, ", "))
+	}
+	if condensed {
+		fc := e.Changes[0]
+		return fmt.Sprintf("%s- **%s:** %s\n\n", markdownContextHeading(e), capitalize(fc.Action), fc.Summary)
+	}
+	var b strings.Builder
+	b.WriteString(markdownContextHeading(e))
+	b.WriteString(markdownContextDetails(e))
+	for _, ca := range e.Artifacts {
+		b.WriteString(markdownContextArtifact(ca))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// writeMarkdownContextEntry writes a full entry with the artifact bodies chosen by fitContext.
+func writeMarkdownContextEntry(b *strings.Builder, e *contextEntry) {
+	b.WriteString(markdownContextHeading(e))
+	b.WriteString(markdownContextDetails(e))
+	for _, ca := range e.Artifacts {
+		b.WriteString(markdownContextArtifact(ca))
+		if ca.Body != "" {
+			fmt.Fprintf(b, "\n### %s\n\n%s\n\n", ca.Artifact.Title, ca.Body)
+		}
+	}
+	b.WriteString("\n")
+}
+
+func markdownContextHeading(e *contextEntry) string {
+	summary := e.Session.Summary
+	if summary == "" {
+		summary = "(no summary)"
+	}
+	if e.Session.Archived {
+		summary += " (archived)"
+	}
+	return fmt.Sprintf("## %s — %s\n", e.Session.SessionID, summary)
+}
+
+func markdownContextDetails(e *contextEntry) string {
+	var s string
+	if e.File == "" {
+		s = "- **Changes:**\n"
+		for _, fc := range e.Changes {
+			s += fmt.Sprintf("  - `%s` (%s): %s\n", fc.Path, fc.Action, fc.Summary)
+		}
+	} else {
+		fc := e.Changes[0]
+		s = fmt.Sprintf("- **Action:** %s\n- **Change:** %s\n", fc.Action, fc.Summary)
+		if fc.From != "" {
+			s += fmt.Sprintf("- **Renamed from:** %s\n", fc.From)
+		}
+		if fc.Path != e.File {
+			s += fmt.Sprintf("- **Path:** %s\n", fc.Path)
+		}
+	}
+	if len(e.Session.Tags) > 0 {
+		s += fmt.Sprintf("- **Tags:** %s\n", strings.Join(e.Session.Tags, ", "))
+	}
+	return s
+}
+
+func markdownContextArtifact(ca *contextArtifact) string {
+	statusStr := ""
+	if ca.Artifact != nil && ca.Artifact.Status != "" {
+		statusStr = fmt.Sprintf(" (%s)", ca.Artifact.Status)
+	}
+	replaces := ""
+	if len(ca.Replaces) > 0 {
+		replaces = fmt.Sprintf(", replaces %s", strings.Join(ca.Replaces, ", "))
+	}
+	return fmt.Sprintf("- **%s:** %s%s%s\n", capitalize(ca.Ref.Type), ca.Ref.Path, statusStr, replaces)
+}
+
 type contextJSONOutput struct {
-	File     string               `json:"file"`
-	Sessions []contextJSONSession `json:"sessions"`
+	File        string               `json:"file,omitempty"`
+	Diff        string               `json:"diff,omitempty"`
+	Files       []string             `json:"files,omitempty"`
+	Pattern     string               `json:"pattern,omitempty"`
+	RenamedFrom []string             `json:"renamed_from,omitempty"`
+	Sessions    []contextJSONSession `json:"sessions"`
+	Omitted     []contextOmission    `json:"omitted,omitempty"`
 }
 
 type contextJSONSession struct {
 	SessionID   string                `json:"session_id"`
 	Timestamp   string                `json:"timestamp"`
 	Summary     string                `json:"summary"`
-	FileAction  string                `json:"file_action"`
-	FileSummary string                `json:"file_summary"`
+	FilePath    string                `json:"file_path,omitempty"`
+	FileFrom    string                `json:"file_from,omitempty"`
+	FileAction  string                `json:"file_action,omitempty"`
+	FileSummary string                `json:"file_summary,omitempty"`
+	Files       []session.FileChange  `json:"files,omitempty"`
 	Tags        []string              `json:"tags"`
 	Artifacts   []contextJSONArtifact `json:"artifacts,omitempty"`
+	Condensed   bool                  `json:"condensed,omitempty"`
+	Archived    bool                  `json:"archived,omitempty"`
 }
 
 type contextJSONArtifact struct {
-	Path    string `json:"path"`
-	Type    string `json:"type"`
-	Status  string `json:"status"`
-	Summary string `json:"summary"`
+	Path      string   `json:"path"`
+	Type      string   `json:"type"`
+	Status    string   `json:"status"`
+	Summary   string   `json:"summary"`
+	Replaces  []string `json:"replaces,omitempty"`
+	Body      string   `json:"body,omitempty"`
+	Truncated bool     `json:"truncated,omitempty"`
 }
 
-func outputContextJSON(sessionsDir string, sessions []*session.Session, files []string) error {
+func outputContextJSON(groups []*contextGroup) error {
+	outputs := buildContextJSON(groups, contextDeep, contextMaxTokens)
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if len(outputs) == 1 {
+		return enc.Encode(outputs[0])
+	}
+	return enc.Encode(outputs)
+}
+
+// buildContextJSON renders context groups as JSON values. With deep set,
+// artifact bodies are included; with maxTokens set, the output is fitted
+// into the budget as for markdown.
+func buildContextJSON(groups []*contextGroup, deep bool, maxTokens int) []contextJSONOutput {
+	fitContext(groups, deep, maxTokens, contextRenderer{
+		group: func(g *contextGroup) string {
+			return mustMarshal(contextJSONOutput{File: g.File, Diff: g.Diff, Files: g.Files, Pattern: g.Pattern, RenamedFrom: g.RenamedFrom, Sessions: []contextJSONSession{}})
+		},
+		entry: func(e *contextEntry, condensed bool) string {
+			return mustMarshal(contextJSONEntry(e, condensed))
+		},
+	})
+
 	var outputs []contextJSONOutput
-	for _, file := range files {
+	for _, g := range groups {
 		output := contextJSONOutput{
-			File:     file,
-			Sessions: []contextJSONSession{},
+			File:        g.File,
+			Diff:        g.Diff,
+			Files:       g.Files,
+			Pattern:     g.Pattern,
+			RenamedFrom: g.RenamedFrom,
+			Sessions:    []contextJSONSession{},
+			Omitted:     g.Omitted,
 		}
-
-		for _, s := range sessions {
-			for _, fc := range s.FilesChanged {
-				if fc.Path == file {
-					cs := contextJSONSession{
-						SessionID:   s.SessionID,
-						Timestamp:   s.Timestamp.Format("2006-01-02T15:04:05-07:00"),
-						Summary:     s.Summary,
-						FileAction:  fc.Action,
-						FileSummary: fc.Summary,
-						Tags:        s.Tags,
-					}
-
-					for _, art := range s.Artifacts {
-						ca := contextJSONArtifact{
-							Path:    art.Path,
-							Type:    art.Type,
-							Summary: art.Summary,
-						}
-						a, err := loadArtifact(sessionsDir, s.SessionID, art.Path)
-						if err == nil {
-							ca.Status = a.Status
-						}
-						cs.Artifacts = append(cs.Artifacts, ca)
-					}
-
-					output.Sessions = append(output.Sessions, cs)
-					break
+		for _, e := range g.Entries {
+			if e.Level == budget.Omitted {
+				continue
+			}
+			cs := contextJSONEntry(e, e.Level == budget.Condensed)
+			for i, ca := range e.Artifacts {
+				if i < len(cs.Artifacts) {
+					cs.Artifacts[i].Body = ca.Body
+					cs.Artifacts[i].Truncated = ca.Level == budget.Truncated
 				}
 			}
+			output.Sessions = append(output.Sessions, cs)
 		}
-
 		outputs = append(outputs, output)
 	}
+	return outputs
+}
 
-	enc := json.NewEncoder(os.Stdout)
-	enc.SetIndent("", "  ")
-	if len(outputs) == 1 {
-		return enc.Encode(outputs[0])
+// contextJSONEntry converts an entry without artifact bodies. Condensed
+// entries drop their artifacts.
+func contextJSONEntry(e *contextEntry, condensed bool) contextJSONSession {
+	s := e.Session
+	cs := contextJSONSession{
+		SessionID: s.SessionID,
+		Timestamp: s.Timestamp.Format("2006-01-02T15:04:05-07:00"),
+		Summary:   s.Summary,
+		Tags:      s.Tags,
+		Condensed: condensed,
+		Archived:  s.Archived,
 	}
-	return enc.Encode(outputs)
+	if e.File == "" {
+		cs.Files = e.Changes
+	} else {
+		fc := e.Changes[0]
+		cs.FileFrom, cs.FileAction, cs.FileSummary = fc.From, fc.Action, fc.Summary
+		if fc.Path != e.File {
+			cs.FilePath = fc.Path
+		}
+	}
+	if condensed {
+		return cs
+	}
+	for _, ca := range e.Artifacts {
+		ja := contextJSONArtifact{
+			Path:     ca.Ref.Path,
+			Type:     ca.Ref.Type,
+			Summary:  ca.Ref.Summary,
+			Replaces: ca.Replaces,
+		}
+		if ca.Artifact != nil {
+			ja.Status = ca.Artifact.Status
+		}
+		cs.Artifacts = append(cs.Artifacts, ja)
+	}
+	return cs
+}
+
+// mustMarshal encodes v for token estimation; the context types always encode.
+func mustMarshal(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
 }
 
 func capitalize(s string) string {
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glopal/sessions/internal/config"
+	"github.com/glopal/sessions/internal/session"
+	"github.com/glopal/sessions/pkg/store"
+)
+
+// writeTestSession writes a session file by hand so tests control its ID.
+func writeTestSession(t *testing.T, st *store.Store, id, frontmatter string) {
+	t.Helper()
+	path := session.ResolveSessionPath(st.Dir(), id)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := "---\ntimestamp: 2026-02-24T10:00:00Z\nsession_id: \"" + id + "\"\nsummary: Session " + id + "\n" + frontmatter + "\n---\n\nBody.\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContextBudget(t *testing.T) {
+	st := newTestStore(t)
+	files := "files_changed:\n  - path: loader.go\n    action: modified\n    summary: Change"
+	writeTestSession(t, st, "1771900000", files)
+	writeTestSession(t, st, "1771900100", files)
+	writeTestSession(t, st, "1771900200", files)
+	if err := st.CreateArtifact("1771900200", "adr.md", &session.Artifact{Title: "ADR", Type: "decision", Status: "accepted", Body: strings.Repeat("Because reasons. ", 100)}); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := st.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	full := buildContextJSON(collectContext(st, config.Default(), sessions, []string{"loader.go"}), true, 0)
+	if len(full[0].Sessions) != 3 || len(full[0].Omitted) != 0 {
+		t.Fatalf("unbudgeted context = %+v", full[0])
+	}
+
+	out := buildContextJSON(collectContext(st, config.Default(), sessions, []string{"loader.go"}), true, 300)[0]
+	if len(out.Omitted) == 0 {
+		t.Fatalf("budgeted context omitted nothing: %+v", out)
+	}
+	if len(out.Sessions) == 0 || out.Sessions[0].SessionID != "1771900200" {
+		t.Fatalf("most relevant session not kept first: %+v", out.Sessions)
+	}
+	if a := out.Sessions[0].Artifacts; len(a) != 1 || !a[0].Truncated {
+		t.Errorf("artifact body not truncated: %+v", a)
+	}
+	if got := mustMarshal(out); len(got) > 4*400 {
+		t.Errorf("budgeted context is %d bytes, want roughly 300 tokens", len(got))
+	}
+}
+
+func TestContextTargets(t *testing.T) {
+	st := newTestStore(t)
+	writeTestSession(t, st, "1771900000", "files_changed:\n  - path: internal/old/loader.go\n    action: added\n    summary: New")
+	writeTestSession(t, st, "1771900100", "files_changed:\n  - path: internal/csv/loader.go\n    from: internal/old/loader.go\n    action: renamed\n    summary: Move")
+	writeTestSession(t, st, "1771900200", "files_changed:\n  - path: internal/csv/reader.go\n    action: modified\n    summary: Fix\n  - path: cmd/root.go\n    action: modified\n    summary: Flag")
+
+	sessions, err := st.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args  []string
+		files []string
+	}{
+		{[]string{"internal/csv/loader.go"}, []string{"internal/csv/loader.go"}},
+		{[]string{"internal/csv/"}, []string{"internal/csv/loader.go", "internal/csv/reader.go"}},
+		{[]string{"internal"}, []string{"internal/csv/loader.go", "internal/csv/reader.go"}},
+		{[]string{"**/*.go", "cmd/root.go"}, []string{"cmd/root.go", "internal/csv/loader.go", "internal/csv/reader.go"}},
+		{[]string{"missing.go"}, []string{"missing.go"}},
+	}
+	for _, tt := range tests {
+		var files []string
+		for _, g := range collectContext(st, config.Default(), sessions, tt.args) {
+			files = append(files, g.File)
+		}
+		if strings.Join(files, " ") != strings.Join(tt.files, " ") {
+			t.Errorf("context %v groups = %v, want %v", tt.args, files, tt.files)
+		}
+	}
+
+	out := buildContextJSON(collectContext(st, config.Default(), sessions, []string{"internal/csv/loader.go"}), false, 0)[0]
+	if len(out.RenamedFrom) != 1 || out.RenamedFrom[0] != "internal/old/loader.go" {
+		t.Errorf("renamed_from = %v", out.RenamedFrom)
+	}
+	if len(out.Sessions) != 2 || out.Sessions[1].SessionID != "1771900000" || out.Sessions[1].FilePath != "internal/old/loader.go" {
+		t.Errorf("sessions = %+v, want the pre-rename session under its old path", out.Sessions)
+	}
+}
+
+func TestDiffContext(t *testing.T) {
+	st := newTestStore(t)
+	writeTestSession(t, st, "1771900000", "files_changed:\n  - path: a.go\n    action: added\n    summary: New")
+	writeTestSession(t, st, "1771900100", "files_changed:\n  - path: a.go\n    action: modified\n    summary: Fix\n  - path: b.go\n    action: modified\n    summary: Fix")
+	writeTestSession(t, st, "1771900200", "files_changed:\n  - path: other.go\n    action: modified\n    summary: Unrelated")
+
+	sessions, err := st.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := parseGitNameStatusOutput("M\ta.go\nR100\tb.go\tc.go\n")
+	g := collectDiffContext(st, config.Default(), sessions, "main...HEAD", changed)
+
+	if len(g.Entries) != 2 {
+		t.Fatalf("entries = %d, want each matching session once", len(g.Entries))
+	}
+	if e := g.Entries[0]; e.Session.SessionID != "1771900100" || len(e.Changes) != 2 {
+		t.Errorf("first entry = %s with %d changes, want 1771900100 with 2", e.Session.SessionID, len(e.Changes))
+	}
+	if g.Entries[0].Priority <= g.Entries[1].Priority {
+		t.Errorf("session touching both files ranked below one touching a single file")
+	}
+
+	out := buildContextJSON([]*contextGroup{g}, false, 0)[0]
+	if out.Diff != "main...HEAD" || strings.Join(out.Files, " ") != "a.go c.go" || len(out.Sessions[0].Files) != 2 {
+		t.Errorf("json = %+v", out)
+	}
+}
+
+func TestContextChainHeads(t *testing.T) {
+	st := newTestStore(t)
+	writeTestSession(t, st, "1771900000", "files_changed:\n  - path: loader.go\n    action: added\n    summary: New")
+	writeTestSession(t, st, "1771900100", "files_changed:\n  - path: other.go\n    action: added\n    summary: New")
+	for _, a := range []struct{ id, name, supersedes string }{
+		{"1771900000", "adr.md", ""},
+		{"1771900000", "notes.md", ""},
+		{"1771900100", "adr-v2.md", "1771900000/adr.md"},
+	} {
+		if err := st.CreateArtifact(a.id, a.name, &session.Artifact{Title: a.name, Type: "decision", Status: "accepted", Supersedes: a.supersedes}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sessions, err := st.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := collectContext(st, config.Default(), sessions, []string{"loader.go"})
+	if err := showChainHeads(st, config.Default(), groups); err != nil {
+		t.Fatal(err)
+	}
+	a := buildContextJSON(groups, false, 0)[0].Sessions[0].Artifacts
+	if len(a) != 2 || a[0].Path != "1771900100/adr-v2.md" || a[0].Status != "accepted" || strings.Join(a[0].Replaces, ",") != "1771900000/adr.md" {
+		t.Errorf("artifacts = %+v, want adr.md replaced by its head", a)
+	}
+
+	history := buildContextJSON(collectContext(st, config.Default(), sessions, []string{"loader.go"}), false, 0)[0].Sessions[0].Artifacts
+	if len(history) != 2 || history[0].Path != "adr.md" || history[0].Status != "superseded" {
+		t.Errorf("history artifacts = %+v", history)
+	}
+}
+
+func TestStatusWeight(t *testing.T) {
+	cfg, err := config.Parse([]byte(`
+statuses:
+  - name: proposed
+  - name: reviewed
+  - name: ratified
+  - name: withdrawn
+    stale: true
+types:
+  - name: rfc
+  - name: note
+    statuses: [reviewed, withdrawn]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		typ, status string
+		want        float64
+	}{
+		{"rfc", "proposed", 0.6},
+		{"rfc", "reviewed", 0.8},
+		{"rfc", "ratified", 1},
+		{"rfc", "withdrawn", 0.1},
+		{"note", "reviewed", 1},
+		{"rfc", "unknown", 0.5},
+	}
+	for _, tt := range tests {
+		if got := statusWeight(cfg, tt.typ, tt.status); got != tt.want {
+			t.Errorf("statusWeight(%s, %s) = %v, want %v", tt.typ, tt.status, got, tt.want)
+		}
+	}
+}
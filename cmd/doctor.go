@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/glopal/sessions/pkg/store"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Find and repair broken references between sessions and artifacts",
+	Long: `Scan the whole store for broken references and stray files:
+
+  unlisted-artifact      artifact files their session's artifacts list omits
+  missing-artifact       artifacts list entries whose file is gone
+  dangling-related       related_sessions entries naming no existing session
+  orphaned-artifact-dir  artifact directories whose session file is gone
+  empty-dir              directories with no files left in them
+
+Archived sessions and artifacts count as existing.
+
+With --repair, unlisted artifacts are registered in their session from their
+frontmatter, dead artifact and related_sessions entries are dropped, and
+empty directories are removed. Orphaned artifact directories are only
+reported: restore or recreate their session, or delete them by hand.
+
+Exits with status 3 if problems remain.`,
+	Args: cobra.NoArgs,
+	RunE: runDoctor,
+}
+
+var doctorRepair bool
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorRepair, "repair", false, "Repair the problems that can be repaired")
+	rootCmd.AddCommand(doctorCmd)
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	problems, err := st.Doctor(doctorRepair)
+	if err != nil {
+		return err
+	}
+	res := doctorResult{Problems: nonNil(problems)}
+	for _, p := range problems {
+		switch {
+		case p.Repaired:
+			res.Repaired++
+		case p.Repairable:
+			res.Repairable++
+			res.Remaining++
+		default:
+			res.Remaining++
+		}
+	}
+	err = writeResult(res, func() error {
+		printDoctorResult(res)
+		return nil
+	})
+	if err == nil && res.Remaining > 0 {
+		return errCheckFailed
+	}
+	return err
+}
+
+// doctorResult is the JSON result of doctor.
+type doctorResult struct {
+	Problems   []store.Problem `json:"problems"`
+	Repaired   int             `json:"repaired"`
+	Repairable int             `json:"repairable"`
+	Remaining  int             `json:"remaining"`
+}
+
+func (r doctorResult) items() any { return r.Problems }
+
+func printDoctorResult(res doctorResult) {
+	problems := res.Problems
+	if len(problems) == 0 {
+		fmt.Println("No problems found.")
+		return
+	}
+
+	for i, p := range problems {
+		if i == 0 || p.Kind != problems[i-1].Kind {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Println(p.Kind)
+		}
+		fmt.Printf("  %s: %s", p.Path, p.Detail)
+		switch {
+		case p.Repaired:
+			fmt.Print(" [repaired]")
+		case p.Repairable:
+			fmt.Print(" [repairable]")
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+
+	fmt.Printf("%s found", plural(len(problems), "problem"))
+	if res.Repaired > 0 {
+		fmt.Printf(", %d repaired", res.Repaired)
+	}
+	if res.Repairable > 0 {
+		fmt.Printf("; %d repairable with 'sessions doctor --repair'", res.Repairable)
+	}
+	fmt.Println()
+}
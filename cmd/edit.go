@@ -1,72 +1,661 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"slices"
+	"strings"
 
+	"github.com/glopal/sessions/internal/config"
 	"github.com/glopal/sessions/internal/parser"
-	"github.com/glopal/sessions/internal/root"
 	"github.com/glopal/sessions/internal/session"
+	"github.com/glopal/sessions/pkg/store"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var editCmd = &cobra.Command{
 	Use:   "edit <key>",
 	Short: "Edit session or artifact fields",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runEdit,
+	Long: `Edit session or artifact fields. All changes are validated and written
+together; if any is invalid, nothing is written.
+
+  sessions edit 1740422423 --add-tag auth --remove-tag wip
+  sessions edit 1740422423 --add-file 'internal/auth/token.go:modified:Retry on expiry'
+  sessions edit 1740422423/adr.md --status accepted --supersedes 1740000000/old-adr.md
+  sessions edit 1740422423 --body-from notes.md
+
+--add-file replaces an existing entry for the same path. --set assigns a
+top-level frontmatter field by name; values of list fields are JSON arrays
+or comma-separated, other values are taken literally:
+
+  sessions edit 1740422423 --set tags=auth,csv
+  sessions edit 1740422423 --set 'files_changed=[{"path":"a.go","action":"added","summary":"New"}]'
+
+--set is applied before the other flags. Sessions added to or removed from
+related_sessions are linked or unlinked as with 'sessions link', so both
+sides stay in step; git is recorded with 'sessions attach-commit' instead.
+
+With --interactive, the file is opened in $VISUAL or $EDITOR instead. The
+edited copy is validated when the editor exits; if it is invalid the editor
+is reopened with the errors at the top, and saving it unchanged cancels the
+edit. The original file is only replaced once the copy is valid.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEdit,
 }
 
-var editSummary string
+var (
+	editSummary     string
+	editSlug        string
+	editAddTags     []string
+	editRemoveTags  []string
+	editAddFiles    []string
+	editRemoveFile  []string
+	editStatus      string
+	editType        string
+	editTitle       string
+	editSupersedes  string
+	editBodyFrom    string
+	editSet         []string
+	editInteractive bool
+)
 
 func init() {
 	editCmd.Flags().StringVar(&editSummary, "summary", "", "Set the summary (max 150 chars)")
+	editCmd.Flags().StringVar(&editSlug, "slug", "", "Set the session slug (empty to clear)")
+	editCmd.Flags().StringSliceVar(&editAddTags, "add-tag", nil, "Add session tags")
+	editCmd.Flags().StringSliceVar(&editRemoveTags, "remove-tag", nil, "Remove session tags")
+	editCmd.Flags().StringArrayVar(&editAddFiles, "add-file", nil, "Add or replace a files_changed entry (path:action:summary)")
+	editCmd.Flags().StringArrayVar(&editRemoveFile, "remove-file", nil, "Remove the files_changed entry for a path")
+	editCmd.Flags().StringVar(&editStatus, "status", "", "Set the artifact status, as declared in .sessions/config.yaml")
+	editCmd.Flags().StringVar(&editType, "type", "", "Set the artifact type, as declared in .sessions/config.yaml")
+	editCmd.Flags().StringVar(&editTitle, "title", "", "Set the artifact title")
+	editCmd.Flags().StringVar(&editSupersedes, "supersedes", "", "Set the key of the artifact this one replaces (empty to clear)")
+	editCmd.Flags().StringVar(&editBodyFrom, "body-from", "", "Replace the body with the contents of a file (- for stdin)")
+	editCmd.Flags().StringArrayVar(&editSet, "set", nil, "Set a frontmatter field (field=value)")
+	editCmd.Flags().BoolVarP(&editInteractive, "interactive", "i", false, "Edit the file in $EDITOR")
 	rootCmd.AddCommand(editCmd)
 }
 
+// sessionOnlyEditFlags and artifactOnlyEditFlags apply to one kind of key.
+var (
+	sessionOnlyEditFlags  = []string{"slug", "add-tag", "remove-tag", "add-file", "remove-file"}
+	artifactOnlyEditFlags = []string{"status", "type", "title", "supersedes"}
+)
+
 func runEdit(cmd *cobra.Command, args []string) error {
-	sessionsDir, err := root.SessionsDir()
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	key, err := st.Resolve(args[0])
 	if err != nil {
 		return err
 	}
+	if editInteractive {
+		if changedEditFlags(cmd) > 1 {
+			return usageErrorf("--interactive cannot be combined with other edit flags")
+		}
+		changed, err := editFileInteractive(st, key)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return writeResult(editResult{Key: key}, func() error {
+				fmt.Printf("No changes made to %s\n", key)
+				return nil
+			})
+		}
+	} else if err := editKey(cmd, st, key); err != nil {
+		return err
+	}
+	return writeResult(editResult{Key: key, Changed: true}, func() error {
+		fmt.Printf("Updated %s\n", key)
+		return nil
+	})
+}
+
+// editResult is the JSON result of edit.
+type editResult struct {
+	Key     string `json:"key"`
+	Changed bool   `json:"changed"`
+}
+
+// changedEditFlags returns the number of edit's own flags set on cmd,
+// leaving out global ones such as --output.
+func changedEditFlags(cmd *cobra.Command) int {
+	n := 0
+	cmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed {
+			n++
+		}
+	})
+	return n
+}
+
+// editKey applies the edit flags set on cmd to the session or artifact at key.
+func editKey(cmd *cobra.Command, st *store.Store, key string) error {
+	sessionID, _, isArtifact := session.ParseKey(key)
+
+	if changedEditFlags(cmd) == 0 {
+		return usageErrorf("no fields specified; see 'sessions edit --help'")
+	}
+	notApplicable, kind := artifactOnlyEditFlags, "artifacts"
+	if isArtifact {
+		notApplicable, kind = sessionOnlyEditFlags, "sessions"
+	}
+	for _, name := range notApplicable {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s applies to %s only", name, kind)
+		}
+	}
 
-	if err := ensureSessionsDir(sessionsDir); err != nil {
+	var body *string
+	if cmd.Flags().Changed("body-from") {
+		b, err := readBodyFrom(editBodyFrom)
+		if err != nil {
+			return err
+		}
+		body = &b
+	}
+
+	if isArtifact {
+		cfg, err := loadConfig(st)
+		if err != nil {
+			return err
+		}
+		current, err := st.GetArtifact(key)
+		if err != nil {
+			return err
+		}
+		// Validation reads other artifacts, which cannot be done while
+		// UpdateArtifact holds the store lock, so the edit is checked first
+		// and only applied if the artifact has not changed since.
+		edited := *current
+		if err := editArtifact(cmd, st, cfg, key, &edited, body); err != nil {
+			return err
+		}
+		return st.UpdateArtifact(key, func(a *session.Artifact) error {
+			if *a != *current {
+				return fmt.Errorf("%s changed on disk while it was being edited", key)
+			}
+			*a = edited
+			return nil
+		})
+	}
+	var before, after []string
+	err := st.UpdateSession(sessionID, func(s *session.Session) error {
+		before = slices.Clone(s.RelatedSessions)
+		if err := editSession(cmd, s, body); err != nil {
+			return err
+		}
+		after = s.RelatedSessions
+		return checkRelatedSessions(st, before, after)
+	})
+	if err != nil {
 		return err
 	}
+	return syncRelatedSessions(st, sessionID, before, after)
+}
+
+// checkRelatedSessions checks that the sessions added to related_sessions
+// exist.
+func checkRelatedSessions(st *store.Store, before, after []string) error {
+	for _, id := range after {
+		if !slices.Contains(before, id) && !st.Exists(session.FormatSessionKey(id)) {
+			return fmt.Errorf("related_sessions: no such session %s", id)
+		}
+	}
+	return nil
+}
 
-	key := args[0]
+// syncRelatedSessions links and unlinks the sessions added to and removed
+// from the related_sessions of sessionID, so that each related session lists
+// it in turn, as 'sessions link' does.
+func syncRelatedSessions(st *store.Store, sessionID string, before, after []string) error {
+	for _, id := range after {
+		if !slices.Contains(before, id) {
+			if err := st.Link(sessionID, id); err != nil {
+				return err
+			}
+		}
+	}
+	for _, id := range before {
+		if !slices.Contains(after, id) {
+			if err := st.Unlink(sessionID, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
 
-	if !cmd.Flags().Changed("summary") {
-		return fmt.Errorf("no fields specified; use --summary to set a value")
+// editSession applies the edit flags to a session and validates the fields
+// they changed.
+func editSession(cmd *cobra.Command, s *session.Session, body *string) error {
+	before := *s
+	before.FilesChanged = slices.Clone(s.FilesChanged)
+	for _, assignment := range editSet {
+		if name, _, _ := strings.Cut(assignment, "="); name == "git" {
+			return fmt.Errorf("cannot --set %q; use 'sessions attach-commit' to record commits", name)
+		}
+	}
+	if err := setFields(s, editSet, "timestamp", "session_id", "artifacts", "archived", "git"); err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("summary") {
+		s.Summary = editSummary
+	}
+	if cmd.Flags().Changed("slug") {
+		s.Slug = editSlug
+	}
+	for _, tag := range editAddTags {
+		if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(s.Tags, tag) {
+			s.Tags = append(s.Tags, tag)
+		}
 	}
+	for _, tag := range editRemoveTags {
+		i := slices.Index(s.Tags, strings.TrimSpace(tag))
+		if i < 0 {
+			return fmt.Errorf("session has no tag %q", tag)
+		}
+		s.Tags = slices.Delete(s.Tags, i, i+1)
+	}
+	for _, spec := range editAddFiles {
+		fc, err := parseFileChangeSpec(spec)
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(s.FilesChanged, func(f session.FileChange) bool { return f.Path == fc.Path })
+		if i >= 0 {
+			s.FilesChanged[i] = fc
+		} else {
+			s.FilesChanged = append(s.FilesChanged, fc)
+		}
+	}
+	for _, path := range editRemoveFile {
+		i := slices.IndexFunc(s.FilesChanged, func(f session.FileChange) bool { return f.Path == path })
+		if i < 0 {
+			return fmt.Errorf("session has no files_changed entry for %q", path)
+		}
+		s.FilesChanged = slices.Delete(s.FilesChanged, i, i+1)
+	}
+	if body != nil {
+		s.Body = *body
+	}
+	return validateSessionEdit(&before, s)
+}
 
-	if len(editSummary) > session.MaxSummaryLength {
-		return fmt.Errorf("summary exceeds %d characters (%d given)", session.MaxSummaryLength, len(editSummary))
+// validateSessionEdit checks the fields of s that differ from before, so
+// that entries predating a rule can be kept while others are edited.
+func validateSessionEdit(before, s *session.Session) error {
+	if len(s.Summary) > session.MaxSummaryLength {
+		return fmt.Errorf("summary exceeds %d characters (%d given)", session.MaxSummaryLength, len(s.Summary))
+	}
+	if s.Slug != before.Slug && s.Slug != "" {
+		if err := session.ValidateSlug(s.Slug); err != nil {
+			return err
+		}
 	}
+	for i, f := range s.FilesChanged {
+		if slices.Contains(before.FilesChanged, f) {
+			continue
+		}
+		if f.Path == "" {
+			return fmt.Errorf("files_changed[%d]: path is required", i)
+		}
+		if !slices.Contains(session.FileActions, f.Action) {
+			return fmt.Errorf("files_changed[%d]: invalid action %q (expected %s)", i, f.Action, strings.Join(session.FileActions, ", "))
+		}
+	}
+	if slices.Contains(s.RelatedSessions, s.SessionID) {
+		return fmt.Errorf("related_sessions: a session cannot be related to itself")
+	}
+	return nil
+}
 
-	sessionID, artifactFile, isArtifact := session.ParseKey(key)
-	path := session.ResolveKeyToPath(sessionsDir, key)
+// editArtifact applies the edit flags to an artifact and validates the
+// fields they changed. An artifact whose type predates the known types can
+// still have its other fields edited.
+func editArtifact(cmd *cobra.Command, st *store.Store, cfg *config.Config, key string, a *session.Artifact, body *string) error {
+	before := *a
+	if err := setFields(a, editSet, "superseded_by"); err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("summary") {
+		a.Summary = editSummary
+	}
+	if cmd.Flags().Changed("status") {
+		a.Status = editStatus
+	}
+	if cmd.Flags().Changed("type") {
+		a.Type = editType
+	}
+	if cmd.Flags().Changed("title") {
+		a.Title = editTitle
+	}
+	if cmd.Flags().Changed("supersedes") {
+		a.Supersedes = editSupersedes
+	}
+	if body != nil {
+		a.Body = *body
+	}
+	return validateArtifactEdit(st, cfg, key, &before, a)
+}
 
+// validateArtifactEdit checks the fields of a that differ from before. Type
+// and status changes must be allowed by cfg, as must marking the artifact
+// named by a changed supersedes as superseded.
+func validateArtifactEdit(st *store.Store, cfg *config.Config, key string, before, a *session.Artifact) error {
+	if len(a.Summary) > session.MaxSummaryLength {
+		return fmt.Errorf("summary exceeds %d characters (%d given)", session.MaxSummaryLength, len(a.Summary))
+	}
+	if a.Title != before.Title && strings.TrimSpace(a.Title) == "" {
+		return fmt.Errorf("title must not be empty")
+	}
+	if a.Type != before.Type {
+		if err := cfg.CheckType(a.Type); err != nil {
+			return err
+		}
+	}
+	if a.Status != before.Status || a.Type != before.Type {
+		if err := cfg.CheckTransition(a.Type, before.Status, a.Status); err != nil {
+			return err
+		}
+	}
+	if a.Supersedes != before.Supersedes && a.Supersedes != "" {
+		if a.Supersedes == key {
+			return fmt.Errorf("an artifact cannot supersede itself")
+		}
+		if _, _, isArtifact := session.ParseKey(a.Supersedes); !isArtifact {
+			return fmt.Errorf("supersedes must be an artifact key (SESSION_ID/name.md), got %q", a.Supersedes)
+		}
+		if err := checkSupersedable(st, cfg, a.Supersedes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSupersedable checks that the artifact at key exists and that cfg
+// allows it to become superseded, reporting either failing as
+// store.ErrInvalid.
+func checkSupersedable(st *store.Store, cfg *config.Config, key string) error {
+	target, err := st.GetArtifact(key)
+	if errors.Is(err, store.ErrNotFound) {
+		return &store.Error{Kind: store.ErrInvalid, Err: fmt.Errorf("supersedes: no such artifact %s", key)}
+	}
+	if err != nil {
+		return err
+	}
+	if err := cfg.CheckTransition(target.Type, target.Status, store.SupersededStatus); err != nil {
+		return &store.Error{Kind: store.ErrInvalid, Err: fmt.Errorf("cannot supersede %s: %w", key, err)}
+	}
+	return nil
+}
+
+// editErrorPrefix marks the lines describing validation errors that are
+// added to the top of a file reopened in the editor.
+const editErrorPrefix = "# sessions-error: "
+
+// editFileInteractive edits a temporary copy of the file at key in the
+// user's editor until it is valid, then replaces the original with it. It
+// reports whether anything was changed. If the edit cannot be saved, the
+// copy is kept and its path included in the error.
+func editFileInteractive(st *store.Store, key string) (bool, error) {
+	original, _, err := st.ReadRaw(key)
+	if err != nil {
+		return false, err
+	}
+	f, err := os.CreateTemp("", "sessions-edit-*.md")
+	if err != nil {
+		return false, fmt.Errorf("creating temporary file: %w", err)
+	}
+	tmp := f.Name()
+	f.Close()
+	keep := false
+	defer func() {
+		if !keep {
+			os.Remove(tmp)
+		}
+	}()
+
+	content, previous := original, original
+	for {
+		if err := os.WriteFile(tmp, content, 0600); err != nil {
+			return false, fmt.Errorf("writing temporary file: %w", err)
+		}
+		if err := runEditor(tmp); err != nil {
+			return false, err
+		}
+		data, err := os.ReadFile(tmp)
+		if err != nil {
+			return false, fmt.Errorf("reading temporary file: %w", err)
+		}
+		edited := stripEditErrors(data)
+		if bytes.Equal(edited, original) {
+			return false, nil
+		}
+		if bytes.Equal(edited, previous) {
+			keep = true
+			return false, fmt.Errorf("edit cancelled; your version is in %s", tmp)
+		}
+
+		verr := validateEditedFile(st, key, original, edited)
+		if verr == nil {
+			verr = st.WriteRaw(key, edited)
+		}
+		if verr == nil {
+			return true, syncEditedRelated(st, key, original, edited)
+		}
+		if !errors.Is(verr, store.ErrInvalid) && !isEditValidationError(verr) {
+			keep = true
+			return false, fmt.Errorf("%w; your version is in %s", verr, tmp)
+		}
+		previous = edited
+		content = withEditErrors(edited, verr)
+	}
+}
+
+// editValidationError is a problem with an edited file that the user can fix
+// in the editor.
+type editValidationError struct{ error }
+
+func isEditValidationError(err error) bool {
+	var ve editValidationError
+	return errors.As(err, &ve)
+}
+
+// validateEditedFile parses an edited session or artifact file and applies
+// the same checks as the edit flags to the fields that changed.
+func validateEditedFile(st *store.Store, key string, original, edited []byte) error {
+	current, _, err := st.ReadRaw(key)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current, original) {
+		return fmt.Errorf("%s changed on disk while it was being edited", key)
+	}
+
+	sessionID, _, isArtifact := session.ParseKey(key)
 	if isArtifact {
-		a, err := parser.ParseArtifactFile(path)
+		cfg, err := loadConfig(st)
 		if err != nil {
-			return fmt.Errorf("loading artifact %s: %w", key, err)
+			return err
 		}
-		a.Summary = editSummary
-		if err := parser.WriteArtifactFile(path, a); err != nil {
-			return fmt.Errorf("writing artifact %s: %w", key, err)
+		after, err := parser.ParseArtifact(string(edited))
+		if err != nil {
+			return editValidationError{err}
 		}
-	} else {
-		_ = artifactFile // unused in session path
-		s, err := parser.ParseSessionFile(path)
+		before, err := parser.ParseArtifact(string(original))
 		if err != nil {
-			return fmt.Errorf("loading session %s: %w", sessionID, err)
+			before = &session.Artifact{}
 		}
-		s.Summary = editSummary
-		if err := parser.WriteSessionFile(path, s); err != nil {
-			return fmt.Errorf("writing session %s: %w", sessionID, err)
+		if err := validateArtifactEdit(st, cfg, key, before, after); err != nil {
+			return editValidationError{err}
 		}
+		return nil
 	}
 
-	fmt.Printf("Updated %s\n", key)
+	after, err := parser.ParseSession(string(edited))
+	if err != nil {
+		return editValidationError{err}
+	}
+	if after.SessionID != sessionID {
+		return editValidationError{fmt.Errorf("session_id must stay %q", sessionID)}
+	}
+	before, err := parser.ParseSession(string(original))
+	if err != nil {
+		before = &session.Session{}
+	}
+	if err := validateSessionEdit(before, after); err != nil {
+		return editValidationError{err}
+	}
+	if err := checkRelatedSessions(st, before.RelatedSessions, after.RelatedSessions); err != nil {
+		return editValidationError{err}
+	}
+	return nil
+}
+
+// syncEditedRelated applies the changes to related_sessions made in an
+// edited session file to the sessions added or removed.
+func syncEditedRelated(st *store.Store, key string, original, edited []byte) error {
+	sessionID, _, isArtifact := session.ParseKey(key)
+	if isArtifact {
+		return nil
+	}
+	after, err := parser.ParseSession(string(edited))
+	if err != nil {
+		return err
+	}
+	before, err := parser.ParseSession(string(original))
+	if err != nil {
+		before = &session.Session{}
+	}
+	return syncRelatedSessions(st, sessionID, before.RelatedSessions, after.RelatedSessions)
+}
+
+// runEditor opens path in $VISUAL or $EDITOR, falling back to vi.
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := strings.Fields(editor)
+	c := exec.Command(args[0], append(args[1:], path)...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("running editor %q: %w", editor, err)
+	}
+	return nil
+}
+
+// withEditErrors prepends err, one comment line per line of its message, to
+// the contents of an edited file.
+func withEditErrors(data []byte, err error) []byte {
+	var b bytes.Buffer
+	for _, line := range strings.Split(err.Error(), "\n") {
+		b.WriteString(editErrorPrefix + line + "\n")
+	}
+	b.WriteString(editErrorPrefix + "Fix the file and save it, or save it unchanged to cancel.\n")
+	b.Write(data)
+	return b.Bytes()
+}
+
+// stripEditErrors removes the error lines added by withEditErrors.
+func stripEditErrors(data []byte) []byte {
+	for bytes.HasPrefix(data, []byte(editErrorPrefix)) {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			return nil
+		}
+		data = data[i+1:]
+	}
+	return data
+}
+
+// parseFileChangeSpec parses a path:action:summary argument. The summary may
+// itself contain colons.
+func parseFileChangeSpec(spec string) (session.FileChange, error) {
+	parts := strings.SplitN(spec, ":", 3)
+	if len(parts) != 3 || parts[0] == "" {
+		return session.FileChange{}, fmt.Errorf("invalid --add-file %q (expected path:action:summary)", spec)
+	}
+	fc := session.FileChange{Path: parts[0], Action: parts[1], Summary: parts[2]}
+	if !slices.Contains(session.FileActions, fc.Action) {
+		return session.FileChange{}, fmt.Errorf("invalid --add-file %q: action must be one of %s", spec, strings.Join(session.FileActions, ", "))
+	}
+	return fc, nil
+}
+
+// readBodyFrom reads a replacement body from a file, or stdin for "-".
+func readBodyFrom(source string) (string, error) {
+	var data []byte
+	var err error
+	if source == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading body: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// setFields applies field=value assignments to a session or artifact by the
+// fields' JSON names. String fields take the value literally; other fields
+// take JSON, and lists also accept a comma-separated list of strings. Fields
+// in readOnly, and the body (use --body-from), cannot be set.
+func setFields(doc any, assignments []string, readOnly ...string) error {
+	kinds := make(map[string]reflect.Kind)
+	t := reflect.TypeOf(doc).Elem()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" && name != "body" && !slices.Contains(readOnly, name) {
+			kinds[name] = t.Field(i).Type.Kind()
+		}
+	}
+
+	patch := make(map[string]json.RawMessage)
+	for _, assignment := range assignments {
+		name, value, ok := strings.Cut(assignment, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("invalid --set %q (expected field=value)", assignment)
+		}
+		kind, settable := kinds[name]
+		if !settable {
+			return fmt.Errorf("cannot --set %q", name)
+		}
+		var raw []byte
+		switch {
+		case kind == reflect.String:
+			raw, _ = json.Marshal(value)
+		case kind == reflect.Slice && !strings.HasPrefix(strings.TrimSpace(value), "["):
+			raw, _ = json.Marshal(parseTags(value))
+		case json.Valid([]byte(value)):
+			raw = []byte(value)
+		default:
+			return fmt.Errorf("invalid --set %q: value must be JSON", assignment)
+		}
+		patch[name] = raw
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return fmt.Errorf("invalid --set: %w", err)
+	}
 	return nil
 }
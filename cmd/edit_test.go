@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glopal/sessions/internal/session"
+	"github.com/glopal/sessions/pkg/store"
+	"github.com/spf13/pflag"
+)
+
+// runTestEdit runs editKey with the given flags, resetting them afterwards.
+func runTestEdit(t *testing.T, st *store.Store, key string, flags ...string) error {
+	t.Helper()
+	fs := editCmd.Flags()
+	defer fs.VisitAll(func(f *pflag.Flag) {
+		if sv, ok := f.Value.(interface{ Replace([]string) error }); ok {
+			sv.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	})
+	for i := 0; i+1 < len(flags); i += 2 {
+		if err := fs.Set(flags[i], flags[i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return editKey(editCmd, st, key)
+}
+
+func TestEditSession(t *testing.T) {
+	st := newTestStore(t)
+	writeTestSession(t, st, "1771900000", "tags: [wip, csv]\nfiles_changed:\n  - path: a.go\n    action: added\n    summary: New")
+	writeTestSession(t, st, "1771900100", "")
+
+	err := runTestEdit(t, st, "1771900000",
+		"add-tag", "auth,csv",
+		"remove-tag", "wip",
+		"add-file", "a.go:modified:Fix: handle BOM",
+		"add-file", "b.go:added:New",
+		"set", "related_sessions=1771900100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := st.Get("1771900000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(s.Tags, ",") != "csv,auth" {
+		t.Errorf("tags = %v", s.Tags)
+	}
+	want := []session.FileChange{{Path: "a.go", Action: "modified", Summary: "Fix: handle BOM"}, {Path: "b.go", Action: "added", Summary: "New"}}
+	if len(s.FilesChanged) != 2 || s.FilesChanged[0] != want[0] || s.FilesChanged[1] != want[1] {
+		t.Errorf("files_changed = %+v", s.FilesChanged)
+	}
+	if len(s.RelatedSessions) != 1 || s.RelatedSessions[0] != "1771900100" {
+		t.Errorf("related_sessions = %v", s.RelatedSessions)
+	}
+	if other, err := st.Get("1771900100"); err != nil || len(other.RelatedSessions) != 1 || other.RelatedSessions[0] != "1771900000" {
+		t.Errorf("related session not linked back: %v, %v", other, err)
+	}
+
+	if err := runTestEdit(t, st, "1771900000", "set", "related_sessions=[]"); err != nil {
+		t.Fatal(err)
+	}
+	if other, err := st.Get("1771900100"); err != nil || len(other.RelatedSessions) != 0 {
+		t.Errorf("related session not unlinked: %v, %v", other, err)
+	}
+
+	writeTestSession(t, st, "1771900200", "related_sessions: [\"1700000000\"]")
+	if err := runTestEdit(t, st, "1771900200", "set", "related_sessions=[]"); err != nil {
+		t.Fatalf("unlinking a missing session: %v", err)
+	}
+	if s, err := st.Get("1771900200"); err != nil || len(s.RelatedSessions) != 0 {
+		t.Errorf("dangling related session not removed: %v, %v", s, err)
+	}
+
+	for _, flags := range [][]string{
+		{"add-file", "c.go:moved:Oops"},
+		{"remove-file", "missing.go"},
+		{"remove-tag", "missing"},
+		{"set", "session_id=1"},
+		{"set", "related_sessions=1771999999"},
+		{"set", `git={"branch":"main"}`},
+		{"status", "accepted"},
+		{"summary", strings.Repeat("x", session.MaxSummaryLength+1)},
+	} {
+		if err := runTestEdit(t, st, "1771900000", flags...); err == nil {
+			t.Errorf("edit %v succeeded, want an error", flags)
+		}
+	}
+}
+
+func TestEditArtifact(t *testing.T) {
+	st := newTestStore(t)
+	writeTestSession(t, st, "1771900000", "")
+	for _, name := range []string{"old.md", "new.md"} {
+		if err := st.CreateArtifact("1771900000", name, &session.Artifact{Title: "ADR", Type: "decision", Status: "draft"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	key := "1771900000/new.md"
+	if err := runTestEdit(t, st, key, "status", "accepted", "supersedes", "1771900000/old.md", "set", "summary=Why: because"); err != nil {
+		t.Fatal(err)
+	}
+	a, err := st.GetArtifact(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Status != "accepted" || a.Supersedes != "1771900000/old.md" || a.Summary != "Why: because" {
+		t.Errorf("artifact = %+v", a)
+	}
+
+	for _, flags := range [][]string{
+		{"status", "done"},
+		{"status", "draft"},
+		{"type", "notes"},
+		{"title", " "},
+		{"supersedes", key},
+		{"supersedes", "1771900000/missing.md"},
+		{"add-tag", "x"},
+	} {
+		if err := runTestEdit(t, st, key, flags...); err == nil {
+			t.Errorf("edit %v succeeded, want an error", flags)
+		}
+	}
+}
+
+func TestEditInteractive(t *testing.T) {
+	st := newTestStore(t)
+	writeTestSession(t, st, "1771900000", "tags: [csv]")
+
+	// The editor first breaks the summary, then fixes it once it sees the error.
+	editor := filepath.Join(t.TempDir(), "editor.sh")
+	script := `#!/bin/sh
+if grep -q '^# sessions-error:' "$1"; then
+	sed -i 's/^summary: .*/summary: Fixed/' "$1"
+else
+	sed -i 's/^summary: .*/summary: ` + strings.Repeat("x", session.MaxSummaryLength+1) + `/' "$1"
+fi
+`
+	if err := os.WriteFile(editor, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", editor)
+
+	changed, err := editFileInteractive(st, "1771900000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("edit reported no changes")
+	}
+	s, err := st.Get("1771900000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Summary != "Fixed" || len(s.Tags) != 1 {
+		t.Errorf("session = %+v", s)
+	}
+	data, _, err := st.ReadRaw("1771900000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sessions-error") {
+		t.Errorf("error comments saved into the file:\n%s", data)
+	}
+
+	// An editor that changes nothing leaves the file alone.
+	t.Setenv("EDITOR", "true")
+	if changed, err := editFileInteractive(st, "1771900000"); err != nil || changed {
+		t.Errorf("unchanged edit = %v, %v", changed, err)
+	}
+}
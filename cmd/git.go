@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/glopal/sessions/internal/session"
+)
+
+// getGitStatusFiles runs git status --porcelain and parses the output.
+func getGitStatusFiles() ([]session.FileChange, error) {
+	out, err := exec.Command("git", "status", "--porcelain").Output()
+	if err != nil {
+		return nil, fmt.Errorf("running git status: %w", gitError(err))
+	}
+	return parseGitStatusOutput(string(out)), nil
+}
+
+// parseGitStatusOutput parses the output of git status --porcelain into FileChanges.
+func parseGitStatusOutput(output string) []session.FileChange {
+	var files []session.FileChange
+	for _, line := range strings.Split(output, "\n") {
+		if len(line) < 4 {
+			continue
+		}
+		// Porcelain format: XY PATH or XY PATH -> NEWPATH
+		xy := line[:2]
+		path := strings.TrimSpace(line[3:])
+		if path == "" {
+			continue
+		}
+
+		var action, from string
+		switch {
+		case xy == "??":
+			action = "added"
+		case strings.ContainsRune(xy, 'D'):
+			action = "deleted"
+		case strings.ContainsRune(xy, 'A'):
+			action = "added"
+		case strings.ContainsRune(xy, 'R'):
+			action = "renamed"
+			// Renames show as "old -> new"
+			if idx := strings.Index(path, " -> "); idx >= 0 {
+				from = path[:idx]
+				path = path[idx+4:]
+			}
+		case strings.ContainsRune(xy, 'M'):
+			action = "modified"
+		default:
+			action = "modified"
+		}
+
+		files = append(files, session.FileChange{
+			Path:    path,
+			Action:  action,
+			Summary: "TODO",
+			From:    from,
+		})
+	}
+	return files
+}
+
+// getGitDiffFiles runs git diff --name-status against rev, or against the
+// index with staged set, and parses the output.
+func getGitDiffFiles(rev string, staged bool) ([]session.FileChange, error) {
+	if strings.HasPrefix(rev, "-") {
+		return nil, fmt.Errorf("invalid revision %q", rev)
+	}
+	args := []string{"diff", "--name-status", "-M"}
+	if staged {
+		args = append(args, "--cached")
+	}
+	if rev != "" {
+		args = append(args, rev)
+	}
+	args = append(args, "--")
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("running git diff: %w", gitError(err))
+	}
+	return parseGitNameStatusOutput(string(out)), nil
+}
+
+// parseGitNameStatusOutput parses the output of git diff --name-status into FileChanges.
+func parseGitNameStatusOutput(output string) []session.FileChange {
+	var files []session.FileChange
+	for _, line := range strings.Split(output, "\n") {
+		// Name-status format: X\tPATH, or R<score>\tOLD\tNEW for renames and copies
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 || fields[0] == "" {
+			continue
+		}
+
+		var action, from string
+		path := fields[1]
+		switch fields[0][0] {
+		case 'A':
+			action = "added"
+		case 'D':
+			action = "deleted"
+		case 'R':
+			action = "renamed"
+			if len(fields) > 2 {
+				from, path = fields[1], fields[2]
+			}
+		case 'C':
+			action = "added"
+			if len(fields) > 2 {
+				path = fields[2]
+			}
+		default:
+			action = "modified"
+		}
+
+		files = append(files, session.FileChange{
+			Path:    path,
+			Action:  action,
+			Summary: "TODO",
+			From:    from,
+		})
+	}
+	return files
+}
+
+// getGitRenames returns the renames in the history of HEAD of the repository
+// at dir, mapping each old path to the path it was last renamed to.
+func getGitRenames(dir string) (map[string]string, error) {
+	out, err := gitOutput("-C", dir, "log", "--reverse", "-M", "--diff-filter=R", "--name-status", "--format=", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("reading git history: %w", err)
+	}
+	return parseGitRenames(out), nil
+}
+
+// parseGitRenames collects the renames in name-status output listed oldest
+// first, so that a later rename of the same path wins.
+func parseGitRenames(output string) map[string]string {
+	renames := make(map[string]string)
+	for _, fc := range parseGitNameStatusOutput(output) {
+		if fc.Action == "renamed" && fc.From != "" {
+			renames[fc.From] = fc.Path
+		}
+	}
+	return renames
+}
+
+// gitError adds git's own message to a failed command's error.
+func gitError(err error) error {
+	var ee *exec.ExitError
+	if errors.As(err, &ee) && len(ee.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(ee.Stderr)))
+	}
+	return err
+}
+
+// withoutSessionsDir drops changes to the .sessions/ directory: the memory
+// itself is not part of the code being worked on.
+func withoutSessionsDir(files []session.FileChange) []session.FileChange {
+	return slices.DeleteFunc(files, func(fc session.FileChange) bool {
+		return fc.Path == ".sessions" || strings.HasPrefix(fc.Path, ".sessions/")
+	})
+}
+
+// gitOutput runs git with args and returns its trimmed standard output.
+func gitOutput(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return "", gitError(err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// getGitInfo captures HEAD, the current branch, the author identity and
+// whether there are uncommitted changes. It returns nil outside a git
+// repository. Parts that cannot be determined, such as the branch of a
+// detached HEAD, are left empty.
+func getGitInfo() *session.GitInfo {
+	if _, err := gitOutput("rev-parse", "--git-dir"); err != nil {
+		return nil
+	}
+	g := &session.GitInfo{}
+	g.Commit, _ = gitOutput("rev-parse", "--verify", "--quiet", "HEAD")
+	g.Branch, _ = gitOutput("symbolic-ref", "--short", "--quiet", "HEAD")
+	if ident, err := gitOutput("var", "GIT_AUTHOR_IDENT"); err == nil {
+		// "Name <email> timestamp zone"
+		if i := strings.LastIndexByte(ident, '>'); i >= 0 {
+			ident = ident[:i+1]
+		}
+		g.Author = ident
+	}
+	if files, err := getGitStatusFiles(); err == nil {
+		g.Dirty = len(withoutSessionsDir(files)) > 0
+	}
+	return g
+}
+
+// resolveGitCommit resolves a ref or abbreviated hash to a full commit hash.
+// If git cannot resolve it, for example because it names a commit from
+// another clone, rev is returned unchanged.
+func resolveGitCommit(rev string) string {
+	if rev == "" {
+		return ""
+	}
+	if sha, err := gitOutput("rev-parse", "--verify", "--quiet", "--end-of-options", rev+"^{commit}"); err == nil {
+		return sha
+	}
+	return rev
+}
+
+// shortSHA abbreviates a commit hash for display.
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/glopal/sessions/internal/session"
+)
+
+func TestParseGitStatusOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []session.FileChange
+	}{
+		{"empty output", "", nil},
+		{
+			"modified file",
+			" M cmd/new.go\n",
+			[]session.FileChange{{Path: "cmd/new.go", Action: "modified", Summary: "TODO"}},
+		},
+		{
+			"added file (staged)",
+			"A  cmd/new.go\n",
+			[]session.FileChange{{Path: "cmd/new.go", Action: "added", Summary: "TODO"}},
+		},
+		{
+			"deleted file",
+			" D old.go\n",
+			[]session.FileChange{{Path: "old.go", Action: "deleted", Summary: "TODO"}},
+		},
+		{
+			"untracked file",
+			"?? newfile.go\n",
+			[]session.FileChange{{Path: "newfile.go", Action: "added", Summary: "TODO"}},
+		},
+		{
+			"renamed file",
+			"R  old.go -> new.go\n",
+			[]session.FileChange{{Path: "new.go", Action: "renamed", Summary: "TODO", From: "old.go"}},
+		},
+		{
+			"mixed status",
+			" M cmd/new.go\n?? sessions-new-spec.md\nA  internal/foo.go\n D removed.go\n",
+			[]session.FileChange{
+				{Path: "cmd/new.go", Action: "modified", Summary: "TODO"},
+				{Path: "sessions-new-spec.md", Action: "added", Summary: "TODO"},
+				{Path: "internal/foo.go", Action: "added", Summary: "TODO"},
+				{Path: "removed.go", Action: "deleted", Summary: "TODO"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseGitStatusOutput(tt.output)
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseGitStatusOutput() returned %d files, want %d\ngot: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i].Path != tt.want[i].Path {
+					t.Errorf("file[%d].Path = %q, want %q", i, got[i].Path, tt.want[i].Path)
+				}
+				if got[i].Action != tt.want[i].Action {
+					t.Errorf("file[%d].Action = %q, want %q", i, got[i].Action, tt.want[i].Action)
+				}
+				if got[i].Summary != tt.want[i].Summary {
+					t.Errorf("file[%d].Summary = %q, want %q", i, got[i].Summary, tt.want[i].Summary)
+				}
+				if got[i].From != tt.want[i].From {
+					t.Errorf("file[%d].From = %q, want %q", i, got[i].From, tt.want[i].From)
+				}
+			}
+		})
+	}
+}
+
+func TestParseGitNameStatusOutput(t *testing.T) {
+	output := "M\tcmd/root.go\nA\tcmd/git.go\nD\told.go\nR087\tinternal/a.go\tinternal/b.go\nC100\tsrc.go\tcopy.go\nT\tlink\n"
+	want := []session.FileChange{
+		{Path: "cmd/root.go", Action: "modified"},
+		{Path: "cmd/git.go", Action: "added"},
+		{Path: "old.go", Action: "deleted"},
+		{Path: "internal/b.go", Action: "renamed", From: "internal/a.go"},
+		{Path: "copy.go", Action: "added"},
+		{Path: "link", Action: "modified"},
+	}
+	got := parseGitNameStatusOutput(output)
+	if len(got) != len(want) {
+		t.Fatalf("parseGitNameStatusOutput() returned %d files, want %d\ngot: %+v", len(got), len(want), got)
+	}
+	for i := range got {
+		if got[i].Path != want[i].Path || got[i].Action != want[i].Action || got[i].From != want[i].From {
+			t.Errorf("file[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
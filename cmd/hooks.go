@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/glopal/sessions/pkg/store"
+	"github.com/spf13/cobra"
+)
+
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "Manage git hooks that record commits in sessions",
+	Long: `Manage git hooks that record commits in sessions.
+
+'sessions hooks install' writes three hooks into the repository's hooks
+directory (see 'git rev-parse --git-path hooks'):
+
+  prepare-commit-msg  Adds a "Session: <id>" trailer naming the current session
+                      to a message given with -m, -F or the like.
+  commit-msg          Adds the trailer to a message written in the editor, once
+                      it has been saved. An empty message is left alone, so that
+                      aborting the commit still works.
+  post-commit         Attaches the new commit to the session in its trailer, or
+                      to the current session. If there is none, a stub session
+                      is created as by 'sessions new --empty'.
+
+The current session is the most recent one whose latest recorded commit is
+HEAD, that is, the session the work being committed continues.
+
+Existing hooks are kept and run first: they are renamed with a
+` + "`" + chainedHookSuffix + "`" + ` suffix, and restored by 'sessions hooks uninstall'.
+The hooks call 'sessions' from PATH and never fail a commit.`,
+}
+
+var hooksInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the managed git hooks",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := gitHooksDir()
+		if err != nil {
+			return err
+		}
+		actions, err := installHooks(dir)
+		if err != nil {
+			return err
+		}
+		return printHookActions(actions)
+	},
+}
+
+var hooksUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove the managed git hooks and restore chained ones",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := gitHooksDir()
+		if err != nil {
+			return err
+		}
+		actions, err := uninstallHooks(dir)
+		if err != nil {
+			return err
+		}
+		return printHookActions(actions)
+	},
+}
+
+var hooksRunCmd = &cobra.Command{
+	Use:    "run <hook> [args...]",
+	Short:  "Run a managed hook (called by git)",
+	Hidden: true,
+	Args:   cobra.MinimumNArgs(1),
+	RunE:   runHook,
+}
+
+func init() {
+	hooksCmd.AddCommand(hooksInstallCmd, hooksUninstallCmd, hooksRunCmd)
+	rootCmd.AddCommand(hooksCmd)
+}
+
+// managedHooks are the hooks installed by 'sessions hooks install'.
+var managedHooks = []string{"prepare-commit-msg", "commit-msg", "post-commit"}
+
+// hookMarker identifies a hook script written by 'sessions hooks install'.
+const hookMarker = "# Managed by 'sessions hooks install'"
+
+// chainedHookSuffix is appended to the name of a pre-existing hook that a
+// managed hook runs before its own work.
+const chainedHookSuffix = ".sessions-chained"
+
+// hookScript returns the managed script for a hook.
+func hookScript(name string) string {
+	return `#!/bin/sh
+` + hookMarker + `; remove with 'sessions hooks uninstall'.
+chained="$(dirname "$0")/` + name + chainedHookSuffix + `"
+if [ -x "$chained" ]; then
+	"$chained" "$@" || exit $?
+fi
+if command -v sessions >/dev/null 2>&1; then
+	sessions hooks run ` + name + ` "$@"
+fi
+exit 0
+`
+}
+
+// gitHooksDir returns the directory git runs hooks from.
+func gitHooksDir() (string, error) {
+	dir, err := gitOutput("rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", fmt.Errorf("finding git hooks directory: %w", err)
+	}
+	return dir, nil
+}
+
+// isManagedHook reports whether the hook at path was written by 'sessions hooks install'.
+func isManagedHook(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(string(data), hookMarker), nil
+}
+
+// hookAction is something hooks install or uninstall did to a hook.
+type hookAction struct {
+	Hook string `json:"hook"`
+	// Action is one of chained (an existing hook was moved aside to run
+	// first), installed, removed, restored (a chained hook was moved back)
+	// and skipped (a hook not managed by sessions was left alone).
+	Action  string `json:"action"`
+	Message string `json:"message"`
+}
+
+// hooksResult is the JSON result of hooks install and uninstall.
+type hooksResult struct {
+	Actions []hookAction `json:"actions"`
+}
+
+func (r hooksResult) items() any { return r.Actions }
+
+func printHookActions(actions []hookAction) error {
+	return writeResult(hooksResult{Actions: nonNil(actions)}, func() error {
+		for _, a := range actions {
+			fmt.Println(a.Message)
+		}
+		return nil
+	})
+}
+
+// installHooks writes the managed hooks into dir, moving any existing hook
+// aside so the managed one can chain to it, and returns what it did.
+func installHooks(dir string) ([]hookAction, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating hooks directory: %w", err)
+	}
+	var actions []hookAction
+	for _, name := range managedHooks {
+		path := filepath.Join(dir, name)
+		chained := path + chainedHookSuffix
+		managed, err := isManagedHook(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return actions, fmt.Errorf("reading %s hook: %w", name, err)
+		case !managed:
+			if _, err := os.Stat(chained); err == nil {
+				return actions, fmt.Errorf("cannot chain existing %s hook: %s already exists", name, chained)
+			}
+			if err := os.Rename(path, chained); err != nil {
+				return actions, fmt.Errorf("moving existing %s hook: %w", name, err)
+			}
+			actions = append(actions, hookAction{name, "chained", fmt.Sprintf("Moved existing %s hook to %s; it will run first", name, filepath.Base(chained))})
+		}
+		if err := os.WriteFile(path, []byte(hookScript(name)), 0755); err != nil {
+			return actions, fmt.Errorf("writing %s hook: %w", name, err)
+		}
+		// WriteFile keeps the mode of an existing file.
+		if err := os.Chmod(path, 0755); err != nil {
+			return actions, fmt.Errorf("writing %s hook: %w", name, err)
+		}
+		actions = append(actions, hookAction{name, "installed", fmt.Sprintf("Installed %s hook", name)})
+	}
+	return actions, nil
+}
+
+// uninstallHooks removes the managed hooks from dir and restores the hooks
+// they chained to, and returns what it did. Hooks not written by sessions
+// are left alone.
+func uninstallHooks(dir string) ([]hookAction, error) {
+	var actions []hookAction
+	for _, name := range managedHooks {
+		path := filepath.Join(dir, name)
+		managed, err := isManagedHook(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return actions, fmt.Errorf("reading %s hook: %w", name, err)
+		}
+		if !managed {
+			actions = append(actions, hookAction{name, "skipped", fmt.Sprintf("Skipped %s hook: not managed by sessions", name)})
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return actions, fmt.Errorf("removing %s hook: %w", name, err)
+		}
+		chained := path + chainedHookSuffix
+		if _, err := os.Stat(chained); err == nil {
+			if err := os.Rename(chained, path); err != nil {
+				return actions, fmt.Errorf("restoring %s hook: %w", name, err)
+			}
+			actions = append(actions, hookAction{name, "restored", fmt.Sprintf("Removed %s hook and restored the previous one", name)})
+			continue
+		}
+		actions = append(actions, hookAction{name, "removed", fmt.Sprintf("Removed %s hook", name)})
+	}
+	return actions, nil
+}
+
+// runHook runs a managed hook. Failures are reported as warnings: a hook must
+// never stop a commit.
+func runHook(cmd *cobra.Command, args []string) error {
+	st, err := openStore()
+	if err != nil {
+		// Not a project using sessions.
+		return nil
+	}
+
+	switch args[0] {
+	case "prepare-commit-msg", "commit-msg":
+		err = sessionTrailerHook(st, args[1:])
+	case "post-commit":
+		err = postCommitHook(st)
+	default:
+		return fmt.Errorf("unknown hook %q", args[0])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sessions: warning: %s hook: %v\n", args[0], err)
+	}
+	return nil
+}
+
+// sessionTrailerHook adds a Session trailer naming the current session to
+// the commit message file. prepare-commit-msg runs it before the editor is
+// opened and commit-msg after it is closed, so a message written in the
+// editor gets the trailer too. An existing Session trailer, as on an amended
+// commit or one added by the earlier hook, is kept.
+func sessionTrailerHook(st *store.Store, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing commit message file")
+	}
+	msgFile := args[0]
+	data, err := os.ReadFile(msgFile)
+	if err != nil {
+		return err
+	}
+	if commitMessageEmpty(string(data)) {
+		// Still to be written in the editor, or left empty to abort the
+		// commit, which a trailer alone would stop git from doing.
+		return nil
+	}
+
+	head, _ := gitOutput("rev-parse", "--verify", "--quiet", "HEAD")
+	id, err := currentSession(st, head)
+	if err != nil || id == "" {
+		return err
+	}
+	_, err = gitOutput("interpret-trailers", "--in-place", "--if-exists", "doNothing", "--trailer", "Session: "+id, msgFile)
+	return err
+}
+
+// postCommitHook attaches HEAD to the session named by its Session trailer,
+// or else to the current session, creating a stub session if there is none.
+func postCommitHook(st *store.Store) error {
+	sha, err := gitOutput("rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	trailers, err := gitOutput("log", "-1", "--format=%(trailers:key=Session,valueonly)", sha)
+	if err != nil {
+		return err
+	}
+	id, _, _ := strings.Cut(trailers, "\n")
+	id = strings.TrimSpace(id)
+
+	if id == "" {
+		parent, _ := gitOutput("rev-parse", "--verify", "--quiet", sha+"^")
+		id, err = currentSession(st, parent)
+		if err != nil {
+			return err
+		}
+	}
+	if id == "" {
+		s, err := createStubSession(st, "", "", nil)
+		if err != nil {
+			return err
+		}
+		id = s.SessionID
+		fmt.Fprintf(os.Stderr, "sessions: created session %s for commit %s; describe it with 'sessions edit %s --summary ...'\n", id, shortSHA(sha), id)
+	}
+
+	_, err = st.AttachCommit(id, sha)
+	return err
+}
+
+// currentSession returns the most recent session whose latest recorded
+// commit is head, or "" if there is none. head is empty before the first
+// commit.
+func currentSession(st *store.Store, head string) (string, error) {
+	sessions, err := st.List()
+	if err != nil {
+		return "", err
+	}
+	for _, s := range sessions {
+		if s.Git == nil {
+			continue
+		}
+		latest := s.Git.Commit
+		if n := len(s.Git.Commits); n > 0 {
+			latest = s.Git.Commits[n-1]
+		}
+		if latest == head {
+			return s.SessionID, nil
+		}
+	}
+	return "", nil
+}
+
+// commitMessageEmpty reports whether a commit message has no content besides
+// comments, meaning it is still to be written in the editor or, after it,
+// that the commit is being aborted.
+func commitMessageEmpty(msg string) bool {
+	for _, line := range strings.Split(msg, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
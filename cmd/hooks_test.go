@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInstallHooksChainsExisting(t *testing.T) {
+	dir := t.TempDir()
+	existing := "#!/bin/sh\necho mine\n"
+	if err := os.WriteFile(filepath.Join(dir, "post-commit"), []byte(existing), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Installing twice must not chain the managed hook to itself.
+	for range 2 {
+		if _, err := installHooks(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range managedHooks {
+		if managed, err := isManagedHook(filepath.Join(dir, name)); err != nil || !managed {
+			t.Errorf("%s hook not installed: %v", name, err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "post-commit"+chainedHookSuffix))
+	if err != nil || string(data) != existing {
+		t.Fatalf("chained hook = %q, %v; want the original", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "prepare-commit-msg"+chainedHookSuffix)); !os.IsNotExist(err) {
+		t.Errorf("chained a hook that did not exist: %v", err)
+	}
+
+	if _, err := uninstallHooks(dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "post-commit"))
+	if err != nil || string(data) != existing {
+		t.Errorf("post-commit after uninstall = %q, %v; want the original restored", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "prepare-commit-msg")); !os.IsNotExist(err) {
+		t.Errorf("prepare-commit-msg not removed: %v", err)
+	}
+}
+
+func TestCurrentSession(t *testing.T) {
+	st := newTestStore(t)
+	writeTestSession(t, st, "1771900000", "git:\n  commit: aaaa1111\n  commits: [bbbb2222]")
+	writeTestSession(t, st, "1771900100", "git:\n  commit: bbbb2222")
+	writeTestSession(t, st, "1771900200", "tags: [nogit]")
+
+	for head, want := range map[string]string{"bbbb2222": "1771900100", "aaaa1111": "", "cccc3333": ""} {
+		got, err := currentSession(st, head)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("currentSession(%s) = %q, want %q", head, got, want)
+		}
+	}
+}
+
+func TestSessionTrailerHook(t *testing.T) {
+	st := newTestStore(t)
+	// Outside a repository there is no HEAD, as before the first commit.
+	t.Chdir(filepath.Dir(st.Dir()))
+	writeTestSession(t, st, "1771900000", "git:\n  branch: main")
+
+	for msg, want := range map[string]string{
+		"Fix loader\n": "Fix loader\n\nSession: 1771900000\n",
+		"Fix loader\n\n# Please enter the commit\n": "Fix loader\n\nSession: 1771900000\n",
+		"Fix loader\n\nSession: 1771000000\n":       "Fix loader\n\nSession: 1771000000\n",
+		"\n# Please enter the commit\n":             "\n# Please enter the commit\n",
+	} {
+		path := filepath.Join(t.TempDir(), "COMMIT_EDITMSG")
+		if err := os.WriteFile(path, []byte(msg), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := sessionTrailerHook(st, []string{path}); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(path)
+		if got := string(data); !strings.HasPrefix(got, want) {
+			t.Errorf("message %q = %q, want %q", msg, got, want)
+		}
+	}
+}
+
+func TestCommitMessageEmpty(t *testing.T) {
+	if !commitMessageEmpty("\n# Please enter the commit message\n#\n") {
+		t.Error("comment-only message not empty")
+	}
+	if commitMessageEmpty("Fix loader\n\n# Please enter the commit message\n") {
+		t.Error("message with a subject reported empty")
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/glopal/sessions/internal/config"
 	"github.com/glopal/sessions/internal/root"
+	"github.com/glopal/sessions/pkg/store"
 	"github.com/spf13/cobra"
 )
 
@@ -25,8 +27,10 @@ var initCmd = &cobra.Command{
 		sessionsDir := filepath.Join(projectRoot, ".sessions")
 
 		if info, err := os.Stat(sessionsDir); err == nil && info.IsDir() {
-			fmt.Println(".sessions/ directory already exists")
-			return nil
+			return writeResult(initResult{Path: sessionsDir}, func() error {
+				fmt.Println(".sessions/ directory already exists")
+				return nil
+			})
 		}
 
 		// Create top-level and subdirectories
@@ -41,12 +45,27 @@ var initCmd = &cobra.Command{
 		if err := os.WriteFile(gitkeep, []byte{}, 0644); err != nil {
 			return fmt.Errorf("creating .gitkeep: %w", err)
 		}
+		if err := store.EnsureGitignored(sessionsDir); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(sessionsDir, config.FileName), config.DefaultYAML, 0644); err != nil {
+			return fmt.Errorf("creating %s: %w", config.FileName, err)
+		}
 
-		fmt.Printf("Initialized .sessions/ directory at %s\n", sessionsDir)
-		return nil
+		return writeResult(initResult{Path: sessionsDir, Created: true}, func() error {
+			fmt.Printf("Initialized .sessions/ directory at %s\n", sessionsDir)
+			return nil
+		})
 	},
 }
 
+// initResult is the JSON result of init. Created is false if the .sessions/
+// directory already existed.
+type initResult struct {
+	Path    string `json:"path"`
+	Created bool   `json:"created"`
+}
+
 func init() {
 	rootCmd.AddCommand(initCmd)
 }
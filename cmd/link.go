@@ -2,11 +2,8 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
-	"github.com/glopal/sessions/internal/parser"
-	"github.com/glopal/sessions/internal/root"
-	"github.com/glopal/sessions/internal/session"
+	"github.com/glopal/sessions/pkg/store"
 	"github.com/spf13/cobra"
 )
 
@@ -24,126 +21,46 @@ func init() {
 }
 
 func runLink(cmd *cobra.Command, args []string) error {
-	sessionsDir, err := root.SessionsDir()
+	st, err := openStore()
 	if err != nil {
 		return err
 	}
 
-	if err := ensureSessionsDir(sessionsDir); err != nil {
-		return err
-	}
-
 	if linkAuto {
-		return autoLink(sessionsDir)
+		return autoLink(st)
 	}
 
 	if len(args) != 2 {
-		return fmt.Errorf("provide exactly two session IDs, or use --auto")
+		return usageErrorf("provide exactly two session IDs, or use --auto")
 	}
 
-	return manualLink(sessionsDir, args[0], args[1])
+	return manualLink(st, args[0], args[1])
 }
 
-func manualLink(sessionsDir, id1, id2 string) error {
-	file1 := session.ResolveSessionPath(sessionsDir, id1)
-	file2 := session.ResolveSessionPath(sessionsDir, id2)
-
-	s1, err := parser.ParseSessionFile(file1)
-	if err != nil {
-		return fmt.Errorf("parsing session %s: %w", id1, err)
-	}
-	s2, err := parser.ParseSessionFile(file2)
-	if err != nil {
-		return fmt.Errorf("parsing session %s: %w", id2, err)
-	}
-
-	addRelated(s1, id2)
-	addRelated(s2, id1)
-
-	if err := parser.WriteSessionFile(file1, s1); err != nil {
-		return fmt.Errorf("writing session %s: %w", id1, err)
-	}
-	if err := parser.WriteSessionFile(file2, s2); err != nil {
-		return fmt.Errorf("writing session %s: %w", id2, err)
+func manualLink(st *store.Store, id1, id2 string) error {
+	if err := st.Link(id1, id2); err != nil {
+		return err
 	}
-
-	fmt.Printf("Linked %s <-> %s\n", id1, id2)
-	return nil
+	return writeResult(linkResult{Linked: []string{id1, id2}}, func() error {
+		fmt.Printf("Linked %s <-> %s\n", id1, id2)
+		return nil
+	})
 }
 
-func autoLink(sessionsDir string) error {
-	sessions, err := loadAllSessions(sessionsDir)
+func autoLink(st *store.Store) error {
+	count, err := st.AutoLink()
 	if err != nil {
 		return err
 	}
-
-	// Build file -> session ID map
-	fileMap := make(map[string][]string)
-	for _, s := range sessions {
-		for _, f := range s.FilesChanged {
-			fileMap[f.Path] = append(fileMap[f.Path], s.SessionID)
-		}
-	}
-
-	// Find sessions that share files
-	links := make(map[string]map[string]bool)
-	for _, ids := range fileMap {
-		if len(ids) < 2 {
-			continue
-		}
-		for i := 0; i < len(ids); i++ {
-			for j := i + 1; j < len(ids); j++ {
-				if links[ids[i]] == nil {
-					links[ids[i]] = make(map[string]bool)
-				}
-				if links[ids[j]] == nil {
-					links[ids[j]] = make(map[string]bool)
-				}
-				links[ids[i]][ids[j]] = true
-				links[ids[j]][ids[i]] = true
-			}
-		}
-	}
-
-	// Build session map for quick lookup
-	sessionMap := make(map[string]*session.Session)
-	for _, s := range sessions {
-		sessionMap[s.SessionID] = s
-	}
-
-	// Apply links
-	count := 0
-	for id, related := range links {
-		s := sessionMap[id]
-		if s == nil {
-			continue
-		}
-		changed := false
-		for relID := range related {
-			if addRelated(s, relID) {
-				changed = true
-			}
-		}
-		if changed {
-			file := session.ResolveSessionPath(sessionsDir, id)
-			if err := parser.WriteSessionFile(file, s); err != nil {
-				fmt.Fprintf(os.Stderr, "warning: failed to update %s: %v\n", id, err)
-				continue
-			}
-			count++
-		}
-	}
-
-	fmt.Printf("Auto-linked %d sessions\n", count)
-	return nil
+	return writeResult(linkResult{AutoLinked: count}, func() error {
+		fmt.Printf("Auto-linked %d sessions\n", count)
+		return nil
+	})
 }
 
-func addRelated(s *session.Session, id string) bool {
-	for _, existing := range s.RelatedSessions {
-		if existing == id {
-			return false
-		}
-	}
-	s.RelatedSessions = append(s.RelatedSessions, id)
-	return true
+// linkResult is the JSON result of link: the two sessions linked, or with
+// --auto the number of sessions auto-linked.
+type linkResult struct {
+	Linked     []string `json:"linked,omitempty"`
+	AutoLinked int      `json:"auto_linked"`
 }
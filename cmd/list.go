@@ -2,80 +2,149 @@ package cmd
 
 import (
 	"fmt"
-	"os"
+	"slices"
 	"strings"
+	"time"
 
-	"github.com/glopal/sessions/internal/root"
+	"github.com/glopal/sessions/internal/session"
 	"github.com/spf13/cobra"
 )
 
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all sessions, optionally filtered",
+	Long:  "List sessions, optionally filtered by tag.\n\n" + pageHelp,
 	RunE:  runList,
 }
 
 var (
-	listTag     string
-	listVerbose bool
+	listTag      string
+	listVerbose  bool
+	listArchived bool
+	listPage     pageFlags
 )
 
+// listSorts are the orders list can sort sessions in.
+var listSorts = []string{sortTimestamp, sortFiles, sortArtifacts}
+
 func init() {
 	listCmd.Flags().StringVar(&listTag, "tag", "", "Filter by tag")
 	listCmd.Flags().BoolVar(&listVerbose, "verbose", false, "Show file counts")
+	listCmd.Flags().BoolVar(&listArchived, "include-archived", false, "Include archived sessions")
+	listPage.register(listCmd, listSorts)
 	rootCmd.AddCommand(listCmd)
 }
 
 func runList(cmd *cobra.Command, args []string) error {
-	sessionsDir, err := root.SessionsDir()
-	if err != nil {
+	if err := listPage.check(listSorts); err != nil {
 		return err
 	}
-
-	if err := ensureSessionsDir(sessionsDir); err != nil {
+	st, err := openStore()
+	if err != nil {
 		return err
 	}
 
-	sessions, err := loadAllSessions(sessionsDir)
+	list := st.List
+	if listArchived {
+		list = st.ListAll
+	}
+	sessions, err := list()
 	if err != nil {
 		return err
 	}
 
-	if len(sessions) == 0 {
-		fmt.Println("No sessions found.")
-		os.Exit(2)
+	var listed []*session.Session
+	for _, s := range sessions {
+		if listTag == "" || slices.Contains(s.Tags, listTag) {
+			listed = append(listed, s)
+		}
 	}
 
-	for _, s := range sessions {
-		// Tag filter
-		if listTag != "" {
-			found := false
-			for _, t := range s.Tags {
-				if t == listTag {
-					found = true
-					break
-				}
-			}
-			if !found {
-				continue
+	listed, next, err := paginate(listed, func(s *session.Session) *session.Session { return s }, &listPage, nil)
+	if err != nil {
+		return err
+	}
+	entries := make([]listEntry, 0, len(listed))
+	for _, s := range listed {
+		entries = append(entries, newListEntry(s))
+	}
+	res := listResult{NextCursor: next}
+	if res.Sessions, err = pageItems(entries, listPage.fields); err != nil {
+		return err
+	}
+	err = writeResult(res, func() error {
+		if len(listed) == 0 {
+			fmt.Println("No sessions found.")
+		}
+		if !printProjected(res.Sessions) {
+			for _, s := range listed {
+				fmt.Println(listLine(s))
 			}
 		}
+		printNextCursor(next)
+		return nil
+	})
+	if err == nil && len(listed) == 0 {
+		return errNoResults
+	}
+	return err
+}
 
-		summary := s.Summary
-		if summary == "" {
-			summary = "(no summary)"
-		}
-		line := fmt.Sprintf("%s  %s", s.SessionID, summary)
+// listLine formats a session for the text output of list.
+func listLine(s *session.Session) string {
+	summary := s.Summary
+	if summary == "" {
+		summary = "(no summary)"
+	}
+	if s.Archived {
+		summary += " (archived)"
+	}
+	id := s.SessionID
+	if s.Slug != "" {
+		id += " (" + s.Slug + ")"
+	}
+	line := fmt.Sprintf("%s  %s", id, summary)
 
-		if listVerbose {
-			line += fmt.Sprintf("  [files: %d, artifacts: %d]", len(s.FilesChanged), len(s.Artifacts))
-		}
+	if listVerbose {
+		line += fmt.Sprintf("  [files: %d, artifacts: %d]", len(s.FilesChanged), len(s.Artifacts))
+	}
 
-		if len(s.Tags) > 0 {
-			line += fmt.Sprintf("  [%s]", strings.Join(s.Tags, ", "))
-		}
+	if len(s.Tags) > 0 {
+		line += fmt.Sprintf("  [%s]", strings.Join(s.Tags, ", "))
+	}
+	return line
+}
+
+// listResult is the JSON result of list. Sessions holds listEntry values,
+// or their projections with --fields.
+type listResult struct {
+	Sessions   any    `json:"sessions"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func (r listResult) items() any         { return r.Sessions }
+func (r listResult) nextCursor() string { return r.NextCursor }
+
+type listEntry struct {
+	SessionID string    `json:"session_id"`
+	Slug      string    `json:"slug,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Summary   string    `json:"summary"`
+	Tags      []string  `json:"tags"`
+	Files     int       `json:"files"`
+	Artifacts int       `json:"artifacts"`
+	Archived  bool      `json:"archived,omitempty"`
+}
 
-		fmt.Println(line)
+func newListEntry(s *session.Session) listEntry {
+	return listEntry{
+		SessionID: s.SessionID,
+		Slug:      s.Slug,
+		Timestamp: s.Timestamp,
+		Summary:   s.Summary,
+		Tags:      nonNil(s.Tags),
+		Files:     len(s.FilesChanged),
+		Artifacts: len(s.Artifacts),
+		Archived:  s.Archived,
 	}
-	return nil
 }
@@ -0,0 +1,441 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/glopal/sessions/internal/mcp"
+	"github.com/glopal/sessions/internal/session"
+	"github.com/glopal/sessions/pkg/store"
+	"github.com/spf13/cobra"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve sessions as Model Context Protocol tools over stdio",
+	Long: `Run a Model Context Protocol (MCP) server on stdin/stdout.
+
+Tools: query, context, new_session, create_artifact, edit, link, status, validate.
+Resources: every session and artifact, addressed as sessions://<key>
+(e.g. sessions://1771953023 or sessions://1771953023/sessions-cli-spec.md).`,
+	Args:        cobra.NoArgs,
+	RunE:        runMCP,
+	Annotations: map[string]string{noEnvelopeAnnotation: "true"},
+}
+
+func init() {
+	rootCmd.AddCommand(mcpCmd)
+}
+
+// mcpURIPrefix is the URI scheme for session and artifact resources.
+const mcpURIPrefix = "sessions://"
+
+func runMCP(cmd *cobra.Command, args []string) error {
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	return newMCPServer(st).Serve(os.Stdin, os.Stdout)
+}
+
+// newMCPServer builds an MCP server whose tools operate on st.
+func newMCPServer(st *store.Store) *mcp.Server {
+	srv := &mcp.Server{
+		Name:    "sessions",
+		Version: "0.1.0",
+		Instructions: "Session memory for this repository. Use `context` before editing a file to learn its history, " +
+			"`query` to find past sessions, and `new_session` at the end of a task to record what changed and why.",
+		Resources: &mcpResources{store: st},
+	}
+	for _, t := range mcpTools(st) {
+		srv.AddTool(t)
+	}
+	return srv
+}
+
+func mcpTools(st *store.Store) []mcp.Tool {
+	return []mcp.Tool{
+		{
+			Name:        "query",
+			Description: "Search sessions with filters and/or a boolean expression such as `tag:auth AND (file:internal/** OR artifact:decision) AND NOT tag:spike`. With `search`, also returns relevance-ranked hits across sessions and artifacts.",
+			InputSchema: schemaObject(map[string]any{
+				"expression":    schemaString("Boolean query expression over tag:, file:, artifact:, status:, after:, before:, date:, commit:, branch:, summary:, body:, text:"),
+				"file":          schemaString("Changed file path (exact or glob)"),
+				"tag":           schemaString("Session tag"),
+				"artifact_type": schemaString("Artifact type"),
+				"after":         schemaString("Sessions on or after date (YYYY-MM-DD)"),
+				"before":        schemaString("Sessions on or before date (YYYY-MM-DD)"),
+				"commit":        schemaString("Full or abbreviated hash of a commit recorded on the session"),
+				"branch":        schemaString("Git branch the session was recorded on"),
+				"search":        schemaString("Full-text search; supports \"phrases\" and prefix*"),
+				"limit":         map[string]any{"type": "integer", "minimum": 0, "description": "Maximum number of results (0 = no limit)"},
+			}),
+			Handler: func(raw json.RawMessage) (any, error) {
+				var args struct {
+					Expression   string `json:"expression"`
+					File         string `json:"file"`
+					Tag          string `json:"tag"`
+					ArtifactType string `json:"artifact_type"`
+					After        string `json:"after"`
+					Before       string `json:"before"`
+					Commit       string `json:"commit"`
+					Branch       string `json:"branch"`
+					Search       string `json:"search"`
+					Limit        int    `json:"limit"`
+				}
+				if err := decodeArgs(raw, &args); err != nil {
+					return nil, err
+				}
+				q := store.Query{
+					File:         args.File,
+					Tag:          args.Tag,
+					ArtifactType: args.ArtifactType,
+					After:        args.After,
+					Before:       args.Before,
+					Commit:       args.Commit,
+					Branch:       args.Branch,
+					Search:       args.Search,
+					Expr:         args.Expression,
+				}
+				results, hits, err := st.Query(q)
+				if err != nil {
+					return nil, err
+				}
+				if args.Limit > 0 {
+					results = results[:min(args.Limit, len(results))]
+					hits = hits[:min(args.Limit, len(hits))]
+				}
+				out := map[string]any{"sessions": nonNil(queryJSONResults(results))}
+				if q.Search != "" {
+					out["hits"] = nonNil(rankedJSONResults(hits))
+				}
+				return out, nil
+			},
+		},
+		{
+			Name:        "context",
+			Description: "Build a context bundle for files: every session that changed them, with artifacts and their status. Set deep to include artifact bodies.",
+			InputSchema: schemaObject(map[string]any{
+				"files":      map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "minItems": 1, "description": "File paths relative to the project root"},
+				"deep":       map[string]any{"type": "boolean", "description": "Include artifact bodies"},
+				"max_tokens": map[string]any{"type": "integer", "minimum": 0, "description": "Fit the bundle into about this many tokens, condensing or omitting low-priority sessions (0 = no limit)"},
+				"history":    map[string]any{"type": "boolean", "description": "Show superseded artifacts instead of the head of their supersession chain"},
+			}, "files"),
+			Handler: func(raw json.RawMessage) (any, error) {
+				var args struct {
+					Files     []string `json:"files"`
+					Deep      bool     `json:"deep"`
+					MaxTokens int      `json:"max_tokens"`
+					History   bool     `json:"history"`
+				}
+				if err := decodeArgs(raw, &args); err != nil {
+					return nil, err
+				}
+				if len(args.Files) == 0 {
+					return nil, fmt.Errorf("files is required")
+				}
+				sessions, err := st.List()
+				if err != nil {
+					return nil, err
+				}
+				if args.MaxTokens < 0 {
+					return nil, fmt.Errorf("max_tokens must not be negative")
+				}
+				cfg, err := loadConfig(st)
+				if err != nil {
+					return nil, err
+				}
+				groups := collectContext(st, cfg, sessions, args.Files)
+				if !args.History {
+					if err := showChainHeads(st, cfg, groups); err != nil {
+						return nil, err
+					}
+				}
+				return map[string]any{"files": buildContextJSON(groups, args.Deep, args.MaxTokens)}, nil
+			},
+		},
+		{
+			Name:        "new_session",
+			Description: "Record a new session. timestamp, session_id, artifacts and related_sessions are set by the tool.",
+			InputSchema: schemaObject(map[string]any{
+				"slug":     schemaString("Optional short name to refer to the session by, e.g. csv-loader"),
+				"template": schemaString("Optional session template the body starts from when none is given, e.g. bugfix, feature or incident"),
+				"summary":  schemaString(fmt.Sprintf("One-line summary, at most %d characters", session.MaxSummaryLength)),
+				"tags":     map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				"files_changed": map[string]any{
+					"type": "array",
+					"items": schemaObject(map[string]any{
+						"path":    schemaString("Path relative to the project root"),
+						"action":  map[string]any{"type": "string", "enum": []string{"added", "modified", "deleted", "renamed"}},
+						"summary": schemaString("What changed in this file"),
+						"from":    schemaString("Previous path, for renamed files"),
+					}, "path", "action"),
+				},
+				"body": schemaString("Markdown body, e.g. ## Key Decisions and ## Open Questions sections"),
+			}, "summary"),
+			Handler: func(raw json.RawMessage) (any, error) {
+				var in session.Session
+				if err := decodeArgs(raw, &in); err != nil {
+					return nil, err
+				}
+				if in.Body == "" || in.Template != "" {
+					body, err := sessionBody(st.Dir(), in.Template, &in)
+					if err != nil {
+						return nil, err
+					}
+					if in.Body == "" {
+						in.Body = body
+					}
+				}
+				s, err := st.CreateSession(&in)
+				if err != nil {
+					return nil, err
+				}
+				path, err := st.Path(session.FormatSessionKey(s.SessionID))
+				if err != nil {
+					return nil, err
+				}
+				return map[string]any{"session_id": s.SessionID, "path": path}, nil
+			},
+		},
+		{
+			Name:        "create_artifact",
+			Description: "Attach a markdown artifact (decision, analysis, investigation, architecture, debug-log) to a session, defaulting to the most recent session.",
+			InputSchema: schemaObject(map[string]any{
+				"name":       schemaString("Artifact file name, e.g. token-refresh-adr (.md is added)"),
+				"session":    schemaString("Session ID or slug to attach to (default: most recent)"),
+				"title":      schemaString("Title (default: derived from name)"),
+				"type":       schemaString("Artifact type (default: analysis)"),
+				"summary":    schemaString(fmt.Sprintf("One-line summary, at most %d characters", session.MaxSummaryLength)),
+				"status":     schemaString("Status, e.g. draft or accepted (default: the first status of the type in .sessions/config.yaml)"),
+				"supersedes": schemaString("Key of the artifact this one replaces"),
+				"body":       schemaString("Markdown body"),
+			}, "name", "body"),
+			Handler: func(raw json.RawMessage) (any, error) {
+				var args struct {
+					Name    string `json:"name"`
+					Session string `json:"session"`
+					session.Artifact
+				}
+				if err := decodeArgs(raw, &args); err != nil {
+					return nil, err
+				}
+				if args.Name == "" || strings.ContainsAny(args.Name, `/\`) || strings.HasPrefix(args.Name, ".") {
+					return nil, fmt.Errorf("name must be a plain file name")
+				}
+				sessionID := args.Session
+				if sessionID == "" {
+					var err error
+					if sessionID, err = st.MostRecent(); err != nil {
+						return nil, err
+					}
+				}
+				name := ensureMD(args.Name)
+				a := args.Artifact
+				if a.Title == "" {
+					a.Title = titleFromName(name)
+				}
+				if a.Type == "" {
+					a.Type = "analysis"
+				}
+				path, err := createArtifact(st, sessionID, name, &a)
+				if err != nil {
+					return nil, err
+				}
+				return map[string]any{"key": session.FormatArtifactKey(sessionID, name), "path": path}, nil
+			},
+		},
+		{
+			Name:        "edit",
+			Description: "Set the summary of a session (key: ID) or artifact (key: ID/file.md).",
+			InputSchema: schemaObject(map[string]any{
+				"key":     schemaString("Session or artifact key"),
+				"summary": schemaString(fmt.Sprintf("New summary, at most %d characters", session.MaxSummaryLength)),
+			}, "key", "summary"),
+			Handler: func(raw json.RawMessage) (any, error) {
+				var args struct {
+					Key     string `json:"key"`
+					Summary string `json:"summary"`
+				}
+				if err := decodeArgs(raw, &args); err != nil {
+					return nil, err
+				}
+				if err := st.SetSummary(args.Key, args.Summary); err != nil {
+					return nil, err
+				}
+				return map[string]any{"updated": args.Key}, nil
+			},
+		},
+		{
+			Name:        "link",
+			Description: "Link two sessions as related, or with auto set, link all sessions that changed a common file.",
+			InputSchema: schemaObject(map[string]any{
+				"session1": schemaString("First session ID or slug"),
+				"session2": schemaString("Second session ID or slug"),
+				"auto":     map[string]any{"type": "boolean", "description": "Auto-link sessions that share files"},
+			}),
+			Handler: func(raw json.RawMessage) (any, error) {
+				var args struct {
+					Session1 string `json:"session1"`
+					Session2 string `json:"session2"`
+					Auto     bool   `json:"auto"`
+				}
+				if err := decodeArgs(raw, &args); err != nil {
+					return nil, err
+				}
+				if args.Auto {
+					count, err := st.AutoLink()
+					if err != nil {
+						return nil, err
+					}
+					return map[string]any{"updated": count}, nil
+				}
+				if args.Session1 == "" || args.Session2 == "" {
+					return nil, fmt.Errorf("provide session1 and session2, or set auto")
+				}
+				if err := st.Link(args.Session1, args.Session2); err != nil {
+					return nil, err
+				}
+				return map[string]any{"linked": []string{args.Session1, args.Session2}}, nil
+			},
+		},
+		{
+			Name:        "status",
+			Description: "List artifacts with their status, optionally only superseded or deprecated ones.",
+			InputSchema: schemaObject(map[string]any{
+				"artifact_type": schemaString("Filter by artifact type"),
+				"stale":         map[string]any{"type": "boolean", "description": "Only artifacts in a stale status, such as superseded or deprecated"},
+			}),
+			Handler: func(raw json.RawMessage) (any, error) {
+				var args struct {
+					ArtifactType string `json:"artifact_type"`
+					Stale        bool   `json:"stale"`
+				}
+				if err := decodeArgs(raw, &args); err != nil {
+					return nil, err
+				}
+				entries, err := collectArtifactStatuses(st, args.ArtifactType, args.Stale)
+				if err != nil {
+					return nil, err
+				}
+				return map[string]any{"artifacts": nonNil(entries)}, nil
+			},
+		},
+		{
+			Name:        "validate",
+			Description: "Validate the given keys, or every session and artifact, against the rules of 'sessions validate'. paths also checks that files_changed paths still exist. valid is false if any issue is an error.",
+			InputSchema: schemaObject(map[string]any{
+				"keys":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				"paths": map[string]any{"type": "boolean"},
+			}),
+			Handler: func(raw json.RawMessage) (any, error) {
+				var args struct {
+					Keys  []string `json:"keys"`
+					Paths bool     `json:"paths"`
+				}
+				if err := decodeArgs(raw, &args); err != nil {
+					return nil, err
+				}
+				issues, err := collectValidationIssues(st, args.Keys, args.Paths)
+				if err != nil {
+					return nil, err
+				}
+				return newValidateResult(issues, nil), nil
+			},
+		},
+	}
+}
+
+// decodeArgs strictly decodes tool arguments so typos in field names surface as errors.
+func decodeArgs(raw json.RawMessage, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+func schemaObject(props map[string]any, required ...string) map[string]any {
+	s := map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func schemaString(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
+
+// nonNil returns an empty slice for nil so JSON output has [] rather than null.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+// mcpResources exposes sessions and artifacts as sessions://<key> resources.
+type mcpResources struct {
+	store *store.Store
+}
+
+func (r *mcpResources) Resources() ([]mcp.Resource, error) {
+	sessions, err := r.store.List()
+	if err != nil {
+		return nil, err
+	}
+	var resources []mcp.Resource
+	for _, s := range sessions {
+		key := session.FormatSessionKey(s.SessionID)
+		resources = append(resources, mcp.Resource{
+			URI:         mcpURIPrefix + key,
+			Name:        key,
+			Description: s.Summary,
+			MimeType:    "text/markdown",
+		})
+		for _, art := range s.Artifacts {
+			key := session.FormatArtifactKey(s.SessionID, art.Path)
+			resources = append(resources, mcp.Resource{
+				URI:         mcpURIPrefix + key,
+				Name:        key,
+				Description: art.Summary,
+				MimeType:    "text/markdown",
+			})
+		}
+	}
+	return resources, nil
+}
+
+func (r *mcpResources) Templates() []mcp.ResourceTemplate {
+	return []mcp.ResourceTemplate{
+		{URITemplate: mcpURIPrefix + "{session_id}", Name: "session", Description: "A session file", MimeType: "text/markdown"},
+		{URITemplate: mcpURIPrefix + "{session_id}/{artifact}", Name: "artifact", Description: "An artifact attached to a session", MimeType: "text/markdown"},
+	}
+}
+
+func (r *mcpResources) Read(uri string) (*mcp.ResourceContents, error) {
+	key, ok := strings.CutPrefix(uri, mcpURIPrefix)
+	if !ok || key == "" {
+		return nil, mcp.ErrResourceNotFound
+	}
+	// Keys come from the client; the store refuses any outside the sessions directory.
+	data, _, err := r.store.ReadRaw(key)
+	if errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrInvalid) {
+		return nil, mcp.ErrResourceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mcp.ResourceContents{URI: uri, MimeType: "text/markdown", Text: string(data)}, nil
+}
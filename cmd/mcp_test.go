@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glopal/sessions/pkg/store"
+)
+
+// newTestSessionsDir creates an empty .sessions/ layout in a temp directory.
+func newTestSessionsDir(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), ".sessions")
+	for _, sub := range []string{"sessions", "artifacts"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// newTestStore opens a store over a fresh .sessions/ layout.
+func newTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	st, err := store.Open(newTestSessionsDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+// callTool invokes an MCP tool and decodes its structured result.
+func callTool(t *testing.T, st *store.Store, name, args string) (map[string]any, bool) {
+	t.Helper()
+	msg := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"` + name + `","arguments":` + args + `}}`
+	resp := newMCPServer(st).HandleMessage([]byte(msg))
+	data, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Result struct {
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+			StructuredContent map[string]any `json:"structuredContent"`
+			IsError           bool           `json:"isError"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Result.IsError {
+		return map[string]any{"error": out.Result.Content[0].Text}, false
+	}
+	return out.Result.StructuredContent, true
+}
+
+func TestMCPTools(t *testing.T) {
+	st := newTestStore(t)
+
+	res, ok := callTool(t, st, "new_session", `{
+		"summary": "Add token refresh",
+		"tags": ["auth"],
+		"files_changed": [{"path": "internal/auth/token.go", "action": "modified", "summary": "Retry on expiry"}]
+	}`)
+	if !ok {
+		t.Fatalf("new_session failed: %v", res)
+	}
+	sessionID := res["session_id"].(string)
+
+	res, ok = callTool(t, st, "create_artifact", `{"name": "refresh-adr", "type": "decision", "status": "accepted", "summary": "Why retries", "body": "Retry once."}`)
+	if !ok {
+		t.Fatalf("create_artifact failed: %v", res)
+	}
+	if res["key"] != sessionID+"/refresh-adr.md" {
+		t.Errorf("key = %v, want %s/refresh-adr.md", res["key"], sessionID)
+	}
+
+	res, ok = callTool(t, st, "query", `{"expression": "tag:auth AND status:accepted"}`)
+	if !ok {
+		t.Fatalf("query failed: %v", res)
+	}
+	if got := len(res["sessions"].([]any)); got != 1 {
+		t.Errorf("query returned %d sessions, want 1", got)
+	}
+
+	res, ok = callTool(t, st, "context", `{"files": ["internal/auth/token.go"], "deep": true}`)
+	if !ok {
+		t.Fatalf("context failed: %v", res)
+	}
+	if !strings.Contains(mustJSON(t, res), "Retry once.") {
+		t.Errorf("deep context missing artifact body: %v", res)
+	}
+
+	res, ok = callTool(t, st, "edit", `{"key": "`+sessionID+`", "summary": "`+strings.Repeat("x", 200)+`"}`)
+	if ok {
+		t.Errorf("edit with long summary succeeded: %v", res)
+	}
+	for _, tc := range []struct{ tool, args, want string }{
+		{"new_session", `{"summary": "` + strings.Repeat("x", 200) + `"}`, "summary exceeds"},
+		{"new_session", `{"summary": "x", "files_changed": [{"path": "a.go", "action": "moved"}]}`, `invalid action "moved"`},
+		{"new_session", `{"summary": "x", "files_changed": [{"action": "added"}]}`, "path is required"},
+		{"create_artifact", `{"name": "long", "summary": "` + strings.Repeat("x", 300) + `", "body": "x"}`, "summary exceeds"},
+		{"create_artifact", `{"name": ".hidden", "body": "x"}`, "plain file name"},
+	} {
+		res, ok := callTool(t, st, tc.tool, tc.args)
+		if msg, _ := res["error"].(string); ok || !strings.Contains(msg, tc.want) {
+			t.Errorf("%s %s = %v, want an error containing %q", tc.tool, tc.args, res, tc.want)
+		}
+	}
+
+	res, ok = callTool(t, st, "query", `{"tagz": "auth"}`)
+	if ok || !strings.Contains(res["error"].(string), "tagz") {
+		t.Errorf("unknown argument not rejected: %v", res)
+	}
+}
+
+func TestMCPResources(t *testing.T) {
+	st := newTestStore(t)
+	res, ok := callTool(t, st, "new_session", `{"summary": "First"}`)
+	if !ok {
+		t.Fatalf("new_session failed: %v", res)
+	}
+	sessionID := res["session_id"].(string)
+
+	r := &mcpResources{store: st}
+	list, err := r.Resources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].URI != "sessions://"+sessionID {
+		t.Fatalf("Resources() = %+v", list)
+	}
+
+	c, err := r.Read(list[0].URI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(c.Text, "summary: First") {
+		t.Errorf("resource text = %q", c.Text)
+	}
+
+	if _, err := r.Read("sessions://../../etc/passwd"); err == nil {
+		t.Error("read outside the sessions directory succeeded")
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
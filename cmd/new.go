@@ -4,14 +4,12 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/glopal/sessions/internal/parser"
-	"github.com/glopal/sessions/internal/root"
 	"github.com/glopal/sessions/internal/session"
+	"github.com/glopal/sessions/internal/templates"
+	"github.com/glopal/sessions/pkg/store"
 	"github.com/spf13/cobra"
 )
 
@@ -24,22 +22,52 @@ var newCmd = &cobra.Command{
   sessions new --empty      Create an empty stub session file
   sessions new <<SESS       Read session content from stdin and write file
   ...
-  SESS`,
+  SESS
+
+Sessions are named by their creation time in Unix seconds, with a ".N"
+suffix when several are created in the same second. --slug also gives the
+session a short name that any command taking a session key accepts in place
+of the ID, e.g. 'sessions edit csv-loader' or 'csv-loader/adr.md'.
+
+--template picks the body a session starts from: the built-in bugfix,
+feature and incident templates, or any .sessions/templates/sessions/NAME.md.
+A file there named after a built-in template replaces it, and default.md
+replaces the body used without --template. Templates use Go text/template
+syntax with the fields .Slug, .Tags, .Files (the working tree's changed
+files, with .Path and .Action), .Branch, .Date (YYYY-MM-DD) and .Now, and
+may start with a frontmatter block:
+
+  ---
+  description: Production incident write-up
+  required: [Impact, Root Cause]
+  ---
+
+Sessions record the template they were started from, and 'sessions validate'
+flags required "## " sections they left missing, empty or as scaffold text.`,
 	RunE: runNew,
 }
 
 var (
-	newTags  string
-	newEmpty bool
+	newTags     string
+	newSlug     string
+	newTemplate string
+	newEmpty    bool
 )
 
 func init() {
 	newCmd.Flags().StringVar(&newTags, "tags", "", "Comma-separated tags")
+	newCmd.Flags().StringVar(&newSlug, "slug", "", "Short name to refer to the session by, e.g. csv-loader")
+	newCmd.Flags().StringVar(&newTemplate, "template", "", "Session template to start from, e.g. bugfix, feature or incident")
 	newCmd.Flags().BoolVar(&newEmpty, "empty", false, "Create an empty stub session file")
 	rootCmd.AddCommand(newCmd)
 }
 
 func runNew(cmd *cobra.Command, args []string) error {
+	if newSlug != "" {
+		if err := session.ValidateSlug(newSlug); err != nil {
+			return err
+		}
+	}
 	if newEmpty {
 		return runNewEmpty()
 	}
@@ -60,25 +88,45 @@ func isStdinPiped() bool {
 
 // runNewEmpty creates a stub session file with empty summary and optional tags.
 func runNewEmpty() error {
-	sessionsDir, err := root.SessionsDir()
+	st, err := openStore()
 	if err != nil {
 		return err
 	}
-	if err := ensureSessionsDir(sessionsDir); err != nil {
+
+	s, err := createStubSession(st, newSlug, newTemplate, parseTags(newTags))
+	if err != nil {
 		return err
 	}
+	return printSessionPath(st, s)
+}
 
-	now := time.Now()
-	tags := parseTags(newTags)
-	s := buildSystemSession(now, tags)
-	s.Body = defaultBody()
+// createStubSession creates a session with an empty summary, to be filled in
+// later, recording the current git state. Its body is rendered from the
+// named session template, or the default one if name is empty.
+func createStubSession(st *store.Store, slug, name string, tags []string) (*session.Session, error) {
+	s := &session.Session{
+		Slug:     slug,
+		Tags:     tags,
+		Template: name,
+		Git:      getGitInfo(),
+	}
+	body, err := sessionBody(st.Dir(), name, s)
+	if err != nil {
+		return nil, err
+	}
+	s.Body = body
+	return st.CreateSession(s)
+}
 
-	path, err := writeSession(sessionsDir, now, s)
+// sessionBody renders the body a new session s starts from with the named
+// session template of the store at sessionsDir, or the default one if name
+// is empty.
+func sessionBody(sessionsDir, name string, s *session.Session) (string, error) {
+	t, err := templates.LoadSession(sessionsDir, name)
 	if err != nil {
-		return err
+		return "", err
 	}
-	fmt.Println(path)
-	return nil
+	return t.Render(templates.SessionData(s))
 }
 
 // runNewTemplate prints a HEREDOC template to stdout with git status files.
@@ -91,20 +139,44 @@ func runNewTemplate() error {
 		files = nil
 	}
 
-	template := buildHeredocTemplate(tags, files)
-	fmt.Print(template)
-	return nil
+	// Outside a store only the built-in templates are available.
+	var sessionsDir string
+	if st, err := openStore(); err == nil {
+		sessionsDir = st.Dir()
+	}
+	body, err := sessionBody(sessionsDir, newTemplate, &session.Session{
+		Slug:         newSlug,
+		Tags:         tags,
+		FilesChanged: files,
+		Git:          getGitInfo(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return printTemplate(buildHeredocTemplate(newSlug, newTemplate, tags, files, body))
+}
+
+// templateResult is the JSON result of new and artifact when they print a
+// template to fill in rather than writing a file.
+type templateResult struct {
+	Template string `json:"template"`
+}
+
+// printTemplate prints a HEREDOC template to fill in.
+func printTemplate(template string) error {
+	return writeResult(templateResult{Template: template}, func() error {
+		fmt.Print(template)
+		return nil
+	})
 }
 
 // runNewFromStdin reads session content from stdin, parses it, and writes a file.
 func runNewFromStdin() error {
-	sessionsDir, err := root.SessionsDir()
+	st, err := openStore()
 	if err != nil {
 		return err
 	}
-	if err := ensureSessionsDir(sessionsDir); err != nil {
-		return err
-	}
 
 	data, err := io.ReadAll(os.Stdin)
 	if err != nil {
@@ -118,25 +190,52 @@ func runNewFromStdin() error {
 	if err != nil {
 		return fmt.Errorf("parsing stdin: %w", err)
 	}
+	stdinSession.Tags = mergeTags(parseTags(newTags), stdinSession.Tags)
+	if newSlug != "" {
+		stdinSession.Slug = newSlug
+	}
+	if newTemplate != "" {
+		stdinSession.Template = newTemplate
+	}
+	if stdinSession.Template != "" {
+		if _, err := templates.LoadSession(st.Dir(), stdinSession.Template); err != nil {
+			return err
+		}
+	}
+	if stdinSession.Git == nil {
+		stdinSession.Git = getGitInfo()
+	}
 
-	now := time.Now()
-	flagTags := parseTags(newTags)
-	mergedTags := mergeTags(flagTags, stdinSession.Tags)
+	s, err := st.CreateSession(stdinSession)
+	if err != nil {
+		return err
+	}
+	return printSessionPath(st, s)
+}
 
-	// System-managed fields — always set by CLI
-	s := buildSystemSession(now, mergedTags)
+// createdResult is the JSON result of new and artifact: the key and file
+// path of the session or artifact written.
+type createdResult struct {
+	Key  string `json:"key"`
+	Path string `json:"path"`
+}
 
-	// Claude-settable fields — copied from parsed stdin
-	s.Summary = stdinSession.Summary
-	s.FilesChanged = stdinSession.FilesChanged
-	s.Body = stdinSession.Body
+// printCreated prints the file path of a newly created session or artifact.
+func printCreated(key, path string) error {
+	return writeResult(createdResult{Key: key, Path: path}, func() error {
+		fmt.Println(path)
+		return nil
+	})
+}
 
-	path, err := writeSession(sessionsDir, now, s)
+// printSessionPath prints the file path of a newly created session.
+func printSessionPath(st *store.Store, s *session.Session) error {
+	key := session.FormatSessionKey(s.SessionID)
+	path, err := st.Path(key)
 	if err != nil {
 		return err
 	}
-	fmt.Println(path)
-	return nil
+	return printCreated(key, path)
 }
 
 // parseTags splits a comma-separated tag string into a slice, trimming whitespace.
@@ -177,103 +276,21 @@ func mergeTags(flagTags, stdinTags []string) []string {
 	return merged
 }
 
-// buildSystemSession creates a Session with system-managed fields populated.
-func buildSystemSession(now time.Time, tags []string) *session.Session {
-	return &session.Session{
-		Timestamp:       now,
-		SessionID:       fmt.Sprintf("%d", now.Unix()),
-		Tags:            tags,
-		Artifacts:       []session.ArtifactRef{},
-		RelatedSessions: []string{},
-	}
-}
-
-// writeSession writes a session file to the appropriate year-month subdirectory.
-func writeSession(sessionsDir string, now time.Time, s *session.Session) (string, error) {
-	yearMonth := now.UTC().Format("2006-01")
-	sessionSubDir := filepath.Join(sessionsDir, "sessions", yearMonth)
-	if err := os.MkdirAll(sessionSubDir, 0755); err != nil {
-		return "", fmt.Errorf("creating session subdirectory: %w", err)
-	}
-
-	filePath := filepath.Join(sessionSubDir, s.SessionID+".md")
-	if err := parser.WriteSessionFile(filePath, s); err != nil {
-		return "", fmt.Errorf("writing session file: %w", err)
-	}
-	return filePath, nil
-}
-
-// defaultBody returns the scaffold body template for new sessions.
-func defaultBody() string {
-	return `## Key Decisions
-
-- Decision 1 and rationale.
-
-## Open Questions
-
-- Anything unresolved that future sessions should be aware of.`
-}
-
-// getGitStatusFiles runs git status --porcelain and parses the output.
-func getGitStatusFiles() ([]session.FileChange, error) {
-	out, err := exec.Command("git", "status", "--porcelain").Output()
-	if err != nil {
-		return nil, fmt.Errorf("running git status: %w", err)
-	}
-	return parseGitStatusOutput(string(out)), nil
-}
-
-// parseGitStatusOutput parses the output of git status --porcelain into FileChanges.
-func parseGitStatusOutput(output string) []session.FileChange {
-	var files []session.FileChange
-	for _, line := range strings.Split(output, "\n") {
-		if len(line) < 4 {
-			continue
-		}
-		// Porcelain format: XY PATH or XY PATH -> NEWPATH
-		xy := line[:2]
-		path := strings.TrimSpace(line[3:])
-		if path == "" {
-			continue
-		}
-
-		var action string
-		switch {
-		case xy == "??":
-			action = "added"
-		case strings.ContainsRune(xy, 'D'):
-			action = "deleted"
-		case strings.ContainsRune(xy, 'A'):
-			action = "added"
-		case strings.ContainsRune(xy, 'R'):
-			action = "renamed"
-			// Renames show as "old -> new"
-			if idx := strings.Index(path, " -> "); idx >= 0 {
-				path = path[idx+4:]
-			}
-		case strings.ContainsRune(xy, 'M'):
-			action = "modified"
-		default:
-			action = "modified"
-		}
-
-		files = append(files, session.FileChange{
-			Path:    path,
-			Action:  action,
-			Summary: "TODO",
-		})
-	}
-	return files
-}
-
-// buildHeredocTemplate builds the HEREDOC template string for stdout.
-func buildHeredocTemplate(tags []string, files []session.FileChange) string {
+// buildHeredocTemplate builds the HEREDOC template string for stdout, with
+// body rendered from the session template called name.
+func buildHeredocTemplate(slug, name string, tags []string, files []session.FileChange, body string) string {
 	var b strings.Builder
 
 	b.WriteString("Run the following command with an updated HEREDOC.\n\n")
 	b.WriteString("sessions new <<SESS\n")
 	b.WriteString("---\n")
+	if slug != "" {
+		fmt.Fprintf(&b, "slug: %s\n", slug)
+	}
 	b.WriteString("summary: \"\"\n")
+	if name != "" {
+		fmt.Fprintf(&b, "template: %s\n", name)
+	}
 
 	// Tags
 	if len(tags) == 0 {
@@ -294,17 +311,15 @@ func buildHeredocTemplate(tags []string, files []session.FileChange) string {
 			fmt.Fprintf(&b, "  - path: %s\n", f.Path)
 			fmt.Fprintf(&b, "    action: %s\n", f.Action)
 			fmt.Fprintf(&b, "    summary: %s\n", f.Summary)
+			if f.From != "" {
+				fmt.Fprintf(&b, "    from: %s\n", f.From)
+			}
 		}
 	}
 
 	b.WriteString("---\n\n")
-	b.WriteString("## Overview\n\n")
-	b.WriteString("An overview of the session's purpose and scope.\n\n")
-	b.WriteString("## Key Decisions\n\n")
-	b.WriteString("- Decision 1 and rationale.\n\n")
-	b.WriteString("## Open Questions\n\n")
-	b.WriteString("- Anything unresolved that future sessions should be aware of.\n")
-	b.WriteString("SESS\n")
+	b.WriteString(body)
+	b.WriteString("\nSESS\n")
 
 	return b.String()
 }
@@ -55,73 +55,9 @@ func TestMergeTags(t *testing.T) {
 	}
 }
 
-func TestParseGitStatusOutput(t *testing.T) {
-	tests := []struct {
-		name   string
-		output string
-		want   []session.FileChange
-	}{
-		{"empty output", "", nil},
-		{
-			"modified file",
-			" M cmd/new.go\n",
-			[]session.FileChange{{Path: "cmd/new.go", Action: "modified", Summary: "TODO"}},
-		},
-		{
-			"added file (staged)",
-			"A  cmd/new.go\n",
-			[]session.FileChange{{Path: "cmd/new.go", Action: "added", Summary: "TODO"}},
-		},
-		{
-			"deleted file",
-			" D old.go\n",
-			[]session.FileChange{{Path: "old.go", Action: "deleted", Summary: "TODO"}},
-		},
-		{
-			"untracked file",
-			"?? newfile.go\n",
-			[]session.FileChange{{Path: "newfile.go", Action: "added", Summary: "TODO"}},
-		},
-		{
-			"renamed file",
-			"R  old.go -> new.go\n",
-			[]session.FileChange{{Path: "new.go", Action: "renamed", Summary: "TODO"}},
-		},
-		{
-			"mixed status",
-			" M cmd/new.go\n?? sessions-new-spec.md\nA  internal/foo.go\n D removed.go\n",
-			[]session.FileChange{
-				{Path: "cmd/new.go", Action: "modified", Summary: "TODO"},
-				{Path: "sessions-new-spec.md", Action: "added", Summary: "TODO"},
-				{Path: "internal/foo.go", Action: "added", Summary: "TODO"},
-				{Path: "removed.go", Action: "deleted", Summary: "TODO"},
-			},
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := parseGitStatusOutput(tt.output)
-			if len(got) != len(tt.want) {
-				t.Fatalf("parseGitStatusOutput() returned %d files, want %d\ngot: %+v", len(got), len(tt.want), got)
-			}
-			for i := range got {
-				if got[i].Path != tt.want[i].Path {
-					t.Errorf("file[%d].Path = %q, want %q", i, got[i].Path, tt.want[i].Path)
-				}
-				if got[i].Action != tt.want[i].Action {
-					t.Errorf("file[%d].Action = %q, want %q", i, got[i].Action, tt.want[i].Action)
-				}
-				if got[i].Summary != tt.want[i].Summary {
-					t.Errorf("file[%d].Summary = %q, want %q", i, got[i].Summary, tt.want[i].Summary)
-				}
-			}
-		})
-	}
-}
-
 func TestBuildHeredocTemplate(t *testing.T) {
 	t.Run("empty tags and files", func(t *testing.T) {
-		out := buildHeredocTemplate(nil, nil)
+		out := buildHeredocTemplate("", "", nil, nil, "## Overview")
 		if !strings.Contains(out, "tags: []") {
 			t.Error("expected 'tags: []' in output")
 		}
@@ -141,7 +77,7 @@ func TestBuildHeredocTemplate(t *testing.T) {
 		files := []session.FileChange{
 			{Path: "cmd/new.go", Action: "modified", Summary: "TODO"},
 		}
-		out := buildHeredocTemplate(tags, files)
+		out := buildHeredocTemplate("", "", tags, files, "## Overview")
 		if !strings.Contains(out, "  - refactor") {
 			t.Error("expected tag 'refactor' in output")
 		}
@@ -164,7 +100,11 @@ func TestRoundTrip(t *testing.T) {
 		{Path: "cmd/new.go", Action: "modified", Summary: "TODO"},
 		{Path: "readme.md", Action: "added", Summary: "TODO"},
 	}
-	template := buildHeredocTemplate(tags, files)
+	body, err := sessionBody(t.TempDir(), "", &session.Session{Tags: tags, FilesChanged: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := buildHeredocTemplate("", "", tags, files, body)
 
 	// Extract content between "sessions new <<SESS\n" and "\nSESS\n"
 	startMarker := "sessions new <<SESS\n"
@@ -210,6 +150,43 @@ func TestRoundTrip(t *testing.T) {
 	}
 }
 
+func TestRoundTripTemplate(t *testing.T) {
+	// As TestRoundTrip, for sessions new --template bugfix.
+	files := []session.FileChange{{Path: "cmd/new.go", Action: "modified", Summary: "TODO"}}
+	body, err := sessionBody(t.TempDir(), "bugfix", &session.Session{FilesChanged: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := buildHeredocTemplate("", "bugfix", nil, files, body)
+	_, content, _ := strings.Cut(template, "sessions new <<SESS\n")
+	content, _, found := strings.Cut(content, "\nSESS\n")
+	if !found {
+		t.Fatal("could not find the HEREDOC in template")
+	}
+	s, err := parser.ParseSession(content)
+	if err != nil {
+		t.Fatalf("ParseSession failed: %v", err)
+	}
+	if s.Template != "bugfix" {
+		t.Errorf("Template = %q, want bugfix", s.Template)
+	}
+	if !strings.Contains(s.Body, "## Root Cause") {
+		t.Error("Body should contain '## Root Cause'")
+	}
+
+	st := newTestStore(t)
+	stub, err := createStubSession(st, "", "bugfix", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stub.Template != "bugfix" || !strings.Contains(stub.Body, "## Symptoms") {
+		t.Errorf("stub session = %+v, want the bugfix template", stub)
+	}
+	if _, err := createStubSession(st, "", "postmortem", nil); err == nil {
+		t.Error("stub session created from an unknown template")
+	}
+}
+
 // tagsEqual compares two string slices, treating nil and empty as equal.
 func tagsEqual(a, b []string) bool {
 	if len(a) == 0 && len(b) == 0 {
@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/glopal/sessions/pkg/store"
+	"github.com/spf13/cobra"
+)
+
+// Output formats selected with --output.
+const (
+	outputText   = "text"
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+)
+
+// schemaVersion is the version of the JSON envelope and of the results in
+// it. It changes only when a field is removed or changes meaning; fields may
+// be added without a new version.
+const schemaVersion = 1
+
+// Exit statuses.
+const (
+	exitOK        = 0
+	exitFailure   = 1 // the command failed
+	exitNoResults = 2 // list, query or status matched nothing
+	exitInvalid   = 3 // validate found errors, or doctor left problems unrepaired
+	exitUsage     = 4 // invalid flags or arguments
+)
+
+// Error codes reported in JSON output.
+const (
+	codeError     = "error"
+	codeNotFound  = "not_found"
+	codeExists    = "exists"
+	codeInvalid   = "invalid"
+	codeUsage     = "usage"
+	codeNoResults = "no_results"
+	codeFailed    = "check_failed"
+)
+
+const outputHelp = `Output:
+  --output text    human-readable text (the default)
+  --output json    one JSON envelope:
+                     {"schema_version": 1, "command": "list", "ok": true, "data": {...}}
+                   or, on failure,
+                     {"schema_version": 1, "command": "list", "ok": false,
+                      "error": {"code": "not_found", "message": "...", "exit_code": 1}}
+  --output ndjson  one envelope per line: one per item for commands that
+                   return a list, such as list, query and status, else one;
+                   when another page follows, a last envelope whose data is
+                   {"next_cursor": "..."}
+
+Exit status:
+  0  success
+  1  the command failed
+  2  list, query or status matched nothing
+  3  validate found errors, or doctor left problems unrepaired
+  4  invalid flags or arguments
+`
+
+var outputFormat string
+
+// stdout receives results, and the error envelope in JSON output.
+var stdout io.Writer = os.Stdout
+
+// command is the path of the command being run below the root, such as
+// "paths migrate", and resultWritten whether it has written its result.
+var (
+	command       string
+	resultWritten bool
+)
+
+// noEnvelopeAnnotation marks commands that speak their own protocol on
+// stdout and so never get an envelope.
+const noEnvelopeAnnotation = "no-envelope"
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", outputText, "Output format: text, json or ndjson")
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &statusError{status: exitUsage, code: codeUsage, err: err}
+	})
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		command = commandName(cmd)
+		switch outputFormat {
+		case outputText, outputJSON, outputNDJSON:
+			return nil
+		}
+		f := outputFormat
+		outputFormat = outputText
+		return usageErrorf("invalid output format %q (expected text, json or ndjson)", f)
+	}
+}
+
+// commandName returns the path of cmd below the root command.
+func commandName(cmd *cobra.Command) string {
+	return strings.TrimPrefix(strings.TrimPrefix(cmd.CommandPath(), rootCmd.Name()), " ")
+}
+
+// statusError is an error that ends the command with a given exit status
+// and, in JSON output, error code. A statusError without err ends the
+// command silently, its result having been written.
+type statusError struct {
+	status int
+	code   string
+	err    error
+}
+
+func (e *statusError) Error() string {
+	if e.err == nil {
+		return e.code
+	}
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error { return e.err }
+
+// usageErrorf reports invalid flags or arguments.
+func usageErrorf(format string, args ...any) error {
+	return &statusError{status: exitUsage, code: codeUsage, err: fmt.Errorf(format, args...)}
+}
+
+// errNoResults ends a command whose (empty) result has been written.
+var errNoResults = &statusError{status: exitNoResults, code: codeNoResults}
+
+// errCheckFailed ends validate or doctor when problems remain.
+var errCheckFailed = &statusError{status: exitInvalid, code: codeFailed}
+
+// jsonOutput reports whether results are written as JSON.
+func jsonOutput() bool {
+	return outputFormat == outputJSON || outputFormat == outputNDJSON
+}
+
+// itemList is implemented by results that are lists, which ndjson output
+// writes one item per line.
+type itemList interface {
+	items() any
+}
+
+// pagedList is implemented by lists that may be one page of many. When
+// another page follows, ndjson output ends with an envelope whose data is
+// {"next_cursor": ...}.
+type pagedList interface {
+	itemList
+	nextCursor() string
+}
+
+// envelope wraps every result and error in JSON output.
+type envelope struct {
+	SchemaVersion int        `json:"schema_version"`
+	Command       string     `json:"command"`
+	OK            bool       `json:"ok"`
+	Data          any        `json:"data,omitempty"`
+	Error         *errorInfo `json:"error,omitempty"`
+}
+
+type errorInfo struct {
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// writeResult writes the result of the command: by calling text in text
+// output, or as the data of an envelope in JSON output.
+func writeResult(data any, text func() error) error {
+	resultWritten = true
+	switch outputFormat {
+	case outputJSON:
+		return writeEnvelope(envelope{SchemaVersion: schemaVersion, Command: command, OK: true, Data: data})
+	case outputNDJSON:
+		list, ok := data.(itemList)
+		if !ok {
+			return writeEnvelope(envelope{SchemaVersion: schemaVersion, Command: command, OK: true, Data: data})
+		}
+		items := reflect.ValueOf(list.items())
+		for i := 0; i < items.Len(); i++ {
+			if err := writeEnvelope(envelope{SchemaVersion: schemaVersion, Command: command, OK: true, Data: items.Index(i).Interface()}); err != nil {
+				return err
+			}
+		}
+		if paged, ok := data.(pagedList); ok && paged.nextCursor() != "" {
+			return writeEnvelope(envelope{SchemaVersion: schemaVersion, Command: command, OK: true, Data: map[string]string{"next_cursor": paged.nextCursor()}})
+		}
+		return nil
+	}
+	return text()
+}
+
+func writeEnvelope(e envelope) error {
+	enc := json.NewEncoder(stdout)
+	if outputFormat == outputJSON {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(e)
+}
+
+// execute runs the command line args and returns the exit status. Errors are
+// printed to stderr, or as an error envelope in JSON output.
+func execute(args []string) int {
+	wrapArgs.Do(func() { usageArgs(rootCmd) })
+	command, resultWritten = "", false
+	rootCmd.SetArgs(args)
+	cmd, err := rootCmd.ExecuteC()
+	parsed := command != ""
+	if !parsed {
+		command = commandName(cmd)
+	}
+	if err == nil {
+		if jsonOutput() && !resultWritten && wantsEnvelope(cmd) {
+			if err := writeResult(nil, nil); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return exitFailure
+			}
+		}
+		return exitOK
+	}
+
+	if !parsed {
+		// Parsing stopped, perhaps at a bad flag before reaching --output.
+		outputFormat = outputFlag(args)
+	}
+	status, code := exitFailure, codeError
+	var se *statusError
+	switch {
+	case errors.As(err, &se):
+		status, code = se.status, se.code
+		if se.err == nil {
+			return status
+		}
+	case errors.Is(err, store.ErrNotFound):
+		code = codeNotFound
+	case errors.Is(err, store.ErrExists):
+		code = codeExists
+	case errors.Is(err, store.ErrInvalid):
+		code = codeInvalid
+	}
+	if jsonOutput() {
+		writeEnvelope(envelope{SchemaVersion: schemaVersion, Command: command, Error: &errorInfo{Code: code, Message: err.Error(), ExitCode: status}})
+		return status
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	if status == exitUsage {
+		fmt.Fprintf(os.Stderr, "Run '%s --help' for usage.\n", cmd.CommandPath())
+	}
+	return status
+}
+
+// outputFlag returns the output format args ask for, if it is a valid one,
+// and text otherwise.
+func outputFlag(args []string) string {
+	format := outputText
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		f, ok := strings.CutPrefix(arg, "--output=")
+		if !ok && arg == "--output" && i+1 < len(args) {
+			f, ok = args[i+1], true
+		}
+		if ok && (f == outputJSON || f == outputNDJSON || f == outputText) {
+			format = f
+		}
+	}
+	return format
+}
+
+// wantsEnvelope reports whether cmd, having run without writing a result,
+// still gets an envelope in JSON output. Help, completion and commands
+// speaking their own protocol do not.
+func wantsEnvelope(cmd *cobra.Command) bool {
+	if !cmd.Runnable() || cmd.Annotations[noEnvelopeAnnotation] != "" {
+		return false
+	}
+	if help, _ := cmd.Flags().GetBool("help"); help {
+		return false
+	}
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Name() == "help" || c.Name() == "completion" {
+			return false
+		}
+	}
+	return true
+}
+
+var wrapArgs sync.Once
+
+// usageArgs wraps the argument validation of cmd and its subcommands so that
+// invalid arguments exit with exitUsage.
+func usageArgs(cmd *cobra.Command) {
+	if validate := cmd.Args; validate != nil {
+		cmd.Args = func(c *cobra.Command, args []string) error {
+			if err := validate(c, args); err != nil {
+				return &statusError{status: exitUsage, code: codeUsage, err: err}
+			}
+			return nil
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		usageArgs(sub)
+	}
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// executeJSON runs a command line with JSON output and returns its exit
+// status and the envelopes it wrote.
+func executeJSON(t *testing.T, args ...string) (int, []map[string]any) {
+	t.Helper()
+	var buf bytes.Buffer
+	stdout = &buf
+	defer func() { stdout, outputFormat = os.Stdout, outputText }()
+
+	status := execute(args)
+	var envelopes []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var e map[string]any
+		if err := dec.Decode(&e); err != nil {
+			t.Fatalf("%v: invalid JSON output: %v\n%s", args, err, buf.String())
+		}
+		if e["schema_version"] != float64(schemaVersion) {
+			t.Errorf("%v: schema_version = %v", args, e["schema_version"])
+		}
+		envelopes = append(envelopes, e)
+	}
+	return status, envelopes
+}
+
+func TestExecuteOutput(t *testing.T) {
+	st := newTestStore(t)
+	t.Chdir(filepath.Dir(st.Dir()))
+
+	status, out := executeJSON(t, "list", "--output", "json")
+	if status != exitNoResults || len(out) != 1 || out[0]["ok"] != true || mustMarshal(out[0]["data"]) != `{"sessions":[]}` {
+		t.Errorf("empty list = %d, %v", status, out)
+	}
+
+	writeTestSession(t, st, "1771900000", "tags: [csv]")
+	writeTestSession(t, st, "1771900100", "tags: [csv]")
+	status, out = executeJSON(t, "list", "--output", "ndjson")
+	if status != exitOK || len(out) != 2 || out[0]["command"] != "list" {
+		t.Fatalf("ndjson list = %d, %v", status, out)
+	}
+	if data := out[1]["data"].(map[string]any); data["session_id"] != "1771900000" {
+		t.Errorf("second ndjson item = %v", data)
+	}
+
+	writeTestSession(t, st, "1771900200", "files_changed:\n  - path: a.go\n    action: shredded\n    summary: Gone")
+	status, out = executeJSON(t, "validate", "1771900200", "--output", "json")
+	if status != exitInvalid || len(out) != 1 || out[0]["ok"] != true || out[0]["data"].(map[string]any)["valid"] != false {
+		t.Errorf("failing validate = %d, %v", status, out)
+	}
+
+	tests := []struct {
+		args   []string
+		status int
+		code   string
+	}{
+		{[]string{"rm", "1771999999"}, exitFailure, codeNotFound},
+		{[]string{"link", "1771900000"}, exitUsage, codeUsage},
+		{[]string{"archive"}, exitUsage, codeUsage},
+		{[]string{"query", "--bogus"}, exitUsage, codeUsage},
+	}
+	for _, tt := range tests {
+		status, out := executeJSON(t, append(tt.args, "--output=json")...)
+		if status != tt.status || len(out) != 1 || out[0]["ok"] != false {
+			t.Errorf("%v = %d, %v; want status %d and an error envelope", tt.args, status, out, tt.status)
+			continue
+		}
+		e := out[0]["error"].(map[string]any)
+		if e["code"] != tt.code || e["exit_code"] != float64(tt.status) || !strings.Contains(out[0]["command"].(string), tt.args[0]) {
+			t.Errorf("%v error = %v, want code %s", tt.args, out[0], tt.code)
+		}
+	}
+}
+
+func TestFormatJSONWritesToStdout(t *testing.T) {
+	st := newTestStore(t)
+	t.Chdir(filepath.Dir(st.Dir()))
+	writeTestSession(t, st, "1771900000", "tags: [csv]\nfiles_changed:\n  - path: a.go\n    action: added\n    summary: New")
+	defer func() {
+		queryFormat, querySearch, contextFormat, validateFormat = "text", "", "markdown", "text"
+	}()
+
+	for _, args := range [][]string{
+		{"query", "--format", "json"},
+		{"query", "--search", "csv", "--format", "json"},
+		{"context", "a.go", "--format", "json"},
+		{"validate", "1771900000", "--format", "json"},
+	} {
+		var buf bytes.Buffer
+		stdout = &buf
+		execute(args)
+		stdout = os.Stdout
+		if !json.Valid(buf.Bytes()) || buf.Len() == 0 {
+			t.Errorf("%v wrote %q to stdout, want JSON", args, buf.String())
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package cmd
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/glopal/sessions/internal/session"
+	"github.com/spf13/cobra"
+)
+
+// Session orders for --sort. Every order but relevance puts the largest
+// first, ties going to the most recent session.
+const (
+	sortTimestamp = "timestamp"
+	sortFiles     = "files"
+	sortArtifacts = "artifacts"
+	sortRelevance = "relevance" // best full-text score, query --search only
+)
+
+// pageFlags are the sorting, paging and field selection flags of list and
+// query.
+type pageFlags struct {
+	sort    string
+	reverse bool
+	limit   int
+	offset  int
+	cursor  string
+	fields  []string
+}
+
+func (p *pageFlags) register(cmd *cobra.Command, sorts []string) {
+	cmd.Flags().StringVar(&p.sort, "sort", sortTimestamp, "Order sessions by "+strings.Join(sorts, ", "))
+	cmd.Flags().BoolVar(&p.reverse, "reverse", false, "Reverse the order")
+	cmd.Flags().IntVar(&p.limit, "limit", 0, "Show at most N sessions, and a cursor for the next page")
+	cmd.Flags().IntVar(&p.offset, "offset", 0, "Skip the first N sessions")
+	cmd.Flags().StringVar(&p.cursor, "cursor", "", "Continue from the next_cursor of a previous page")
+	cmd.Flags().StringSliceVar(&p.fields, "fields", nil, "Output only these comma-separated fields, e.g. session_id,summary,tags")
+}
+
+const pageHelp = `Sessions are listed most recent first. --sort orders them by the number
+of files changed or artifacts instead, most first, and --reverse turns any
+order around.
+
+--limit N shows one page of N sessions. When more follow, JSON output
+carries a next_cursor (ndjson ends with an envelope whose data is
+{"next_cursor": ...}, and text output prints it to stderr); pass it to
+--cursor to get the next page.
+Cursors stay valid as sessions are added. --offset N skips N sessions
+instead.
+
+--fields picks the fields of each session to output, in order, such as
+session_id,summary,tags. Text output then prints them tab-separated.`
+
+// check rejects flags that make no sense together, or a sort order not
+// among sorts.
+func (p *pageFlags) check(sorts []string) error {
+	if !slices.Contains(sorts, p.sort) {
+		return usageErrorf("invalid sort %q (expected %s)", p.sort, strings.Join(sorts, ", "))
+	}
+	if p.limit < 0 || p.offset < 0 {
+		return usageErrorf("--limit and --offset must not be negative")
+	}
+	if p.cursor != "" && p.offset > 0 {
+		return usageErrorf("--cursor and --offset are mutually exclusive")
+	}
+	return nil
+}
+
+// pageCursor is the position after the last session of a page, in the
+// order it was listed in. It is passed around base64-encoded.
+type pageCursor struct {
+	Sort    string  `json:"s"`
+	Reverse bool    `json:"r,omitempty"`
+	N       float64 `json:"n,omitempty"`
+	T       int64   `json:"t"`
+	ID      string  `json:"id"`
+}
+
+func (c pageCursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func parsePageCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil || c.ID == "" {
+		return c, usageErrorf("invalid cursor %q", s)
+	}
+	return c, nil
+}
+
+// compare orders cursors descending, or ascending if reverse is set.
+func (c pageCursor) compare(o pageCursor) int {
+	n := cmp.Or(cmp.Compare(o.N, c.N), cmp.Compare(o.T, c.T), strings.Compare(o.ID, c.ID))
+	if c.Reverse {
+		return -n
+	}
+	return n
+}
+
+// paginate sorts items, the sessions of which are given by sessionOf, and
+// returns the page selected by p, with the cursor of the next page if there
+// is one. scores holds each session's relevance for sortRelevance.
+func paginate[T any](items []T, sessionOf func(T) *session.Session, p *pageFlags, scores map[string]float64) ([]T, string, error) {
+	key := func(s *session.Session) pageCursor {
+		c := pageCursor{Sort: p.sort, Reverse: p.reverse, T: s.Timestamp.UnixNano(), ID: s.SessionID}
+		switch p.sort {
+		case sortFiles:
+			c.N = float64(len(s.FilesChanged))
+		case sortArtifacts:
+			c.N = float64(len(s.Artifacts))
+		case sortRelevance:
+			c.N = scores[s.SessionID]
+		}
+		return c
+	}
+	items = slices.Clone(items)
+	slices.SortStableFunc(items, func(a, b T) int { return key(sessionOf(a)).compare(key(sessionOf(b))) })
+
+	if p.cursor != "" {
+		after, err := parsePageCursor(p.cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if after.Sort != p.sort || after.Reverse != p.reverse {
+			return nil, "", usageErrorf("--cursor belongs to a listing with a different --sort or --reverse")
+		}
+		i, _ := slices.BinarySearchFunc(items, after, func(item T, after pageCursor) int {
+			if c := key(sessionOf(item)).compare(after); c != 0 {
+				return c
+			}
+			return -1 // the session the cursor was taken at goes before it
+		})
+		items = items[i:]
+	}
+	items = items[min(p.offset, len(items)):]
+
+	if p.limit == 0 || len(items) <= p.limit {
+		return items, "", nil
+	}
+	items = items[:p.limit]
+	return items, key(sessionOf(items[len(items)-1])).String(), nil
+}
+
+// projection is an item cut down to the fields picked with --fields. It
+// marshals them in the order they were picked.
+type projection struct {
+	fields []string
+	values map[string]json.RawMessage
+}
+
+func (p projection) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range p.fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(f)
+		b.Write(name)
+		b.WriteByte(':')
+		if v, ok := p.values[f]; ok {
+			b.Write(v)
+		} else {
+			b.WriteString("null")
+		}
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// text formats the picked fields tab-separated, lists comma-separated.
+func (p projection) text() string {
+	cols := make([]string, len(p.fields))
+	for i, f := range p.fields {
+		var v any
+		json.Unmarshal(p.values[f], &v)
+		cols[i] = fieldText(v)
+	}
+	return strings.Join(cols, "\t")
+}
+
+func fieldText(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = fieldText(e)
+		}
+		return strings.Join(parts, ", ")
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// project cuts items, a slice of structs, down to fields, which must name
+// JSON fields of the struct.
+func project[T any](items []T, fields []string) ([]projection, error) {
+	known := jsonFields(reflect.TypeFor[T]())
+	for _, f := range fields {
+		if !slices.Contains(known, f) {
+			return nil, usageErrorf("unknown field %q (expected one of %s)", f, strings.Join(known, ", "))
+		}
+	}
+	out := make([]projection, 0, len(items))
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(data, &values); err != nil {
+			return nil, err
+		}
+		out = append(out, projection{fields: fields, values: values})
+	}
+	return out, nil
+}
+
+// pageItems returns items as they go in a result: cut down to fields if any
+// are given.
+func pageItems[T any](items []T, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return items, nil
+	}
+	return project(items, fields)
+}
+
+// printProjected prints items cut down with --fields one per line, and
+// reports whether they were.
+func printProjected(items any) bool {
+	ps, ok := items.([]projection)
+	for _, p := range ps {
+		fmt.Println(p.text())
+	}
+	return ok
+}
+
+// jsonFields returns the JSON names of the fields of struct type t.
+func jsonFields(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// printNextCursor tells text output how to get the next page.
+func printNextCursor(next string) {
+	if next != "" {
+		fmt.Fprintf(os.Stderr, "more sessions follow: --cursor %s\n", next)
+	}
+}
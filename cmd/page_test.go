@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestListPages(t *testing.T) {
+	st := newTestStore(t)
+	t.Chdir(filepath.Dir(st.Dir()))
+	writeTestSession(t, st, "1771900000", "tags: [csv]\nfiles_changed:\n  - path: a.go\n    action: added\n    summary: A\n  - path: b.go\n    action: added\n    summary: B")
+	writeTestSession(t, st, "1771900100", "tags: [csv]")
+	writeTestSession(t, st, "1771900200", "tags: [csv]\nfiles_changed:\n  - path: a.go\n    action: modified\n    summary: A")
+
+	list := func(args ...string) (int, []string, map[string]any) {
+		t.Helper()
+		defer func() { listPage = pageFlags{sort: sortTimestamp} }()
+		status, out := executeJSON(t, append([]string{"list", "--output", "json"}, args...)...)
+		if len(out) != 1 {
+			t.Fatalf("list %v = %d, %v", args, status, out)
+		}
+		data, _ := out[0]["data"].(map[string]any)
+		var ids []string
+		sessions, _ := data["sessions"].([]any)
+		for _, s := range sessions {
+			ids = append(ids, s.(map[string]any)["session_id"].(string))
+		}
+		return status, ids, data
+	}
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{"1771900200", "1771900100", "1771900000"}},
+		{[]string{"--reverse"}, []string{"1771900000", "1771900100", "1771900200"}},
+		{[]string{"--sort", "files"}, []string{"1771900000", "1771900200", "1771900100"}},
+		{[]string{"--sort", "files", "--reverse"}, []string{"1771900100", "1771900200", "1771900000"}},
+		{[]string{"--offset", "1", "--limit", "1"}, []string{"1771900100"}},
+		{[]string{"--offset", "5"}, nil},
+	}
+	for _, tt := range tests {
+		if _, ids, _ := list(tt.args...); !slices.Equal(ids, tt.want) {
+			t.Errorf("list %v = %v, want %v", tt.args, ids, tt.want)
+		}
+	}
+
+	var got []string
+	args := []string{"--sort", "files", "--limit", "2"}
+	for page := 0; page < 3; page++ {
+		_, ids, data := list(args...)
+		got = append(got, ids...)
+		next, _ := data["next_cursor"].(string)
+		if next == "" {
+			break
+		}
+		args = []string{"--sort", "files", "--limit", "2", "--cursor", next}
+	}
+	if want := []string{"1771900000", "1771900200", "1771900100"}; !slices.Equal(got, want) {
+		t.Errorf("pages = %v, want %v", got, want)
+	}
+
+	_, _, data := list("--fields", "summary,session_id", "--limit", "1")
+	if s := mustMarshal(data["sessions"]); s != `[{"session_id":"1771900200","summary":"Session 1771900200"}]` {
+		t.Errorf("--fields sessions = %s", s)
+	}
+	ps, err := project([]listEntry{{SessionID: "1771900000", Tags: []string{"a", "b"}}}, []string{"tags", "session_id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := mustMarshal(ps); s != `[{"tags":["a","b"],"session_id":"1771900000"}]` {
+		t.Errorf("projection = %s, want the fields in the order given", s)
+	}
+	if s := ps[0].text(); s != "a, b\t1771900000" {
+		t.Errorf("projection text = %q", s)
+	}
+
+	for _, args := range [][]string{
+		{"--fields", "bogus"},
+		{"--sort", "relevance"},
+		{"--cursor", "nonsense"},
+		{"--cursor", pageCursor{Sort: sortFiles, ID: "1771900000"}.String()},
+		{"--offset", "1", "--cursor", pageCursor{Sort: sortTimestamp, ID: "1771900000"}.String()},
+	} {
+		if status, _, _ := list(args...); status != exitUsage {
+			t.Errorf("list %v = %d, want %d", args, status, exitUsage)
+		}
+	}
+}
+
+func TestQuerySortRelevance(t *testing.T) {
+	st := newTestStore(t)
+	t.Chdir(filepath.Dir(st.Dir()))
+	writeTestSession(t, st, "1771900000", "tags: [parser]")
+	writeTestSession(t, st, "1771900100", "tags: [csv]")
+	defer func() { queryPage, querySearch = pageFlags{sort: sortTimestamp}, "" }()
+
+	status, out := executeJSON(t, "query", "--sort", "relevance", "--output", "json")
+	if status != exitUsage {
+		t.Errorf("--sort relevance without --search = %d, %v", status, out)
+	}
+	status, out = executeJSON(t, "query", "--search", "parser", "--sort", "relevance", "--fields", "session_id", "--output", "ndjson")
+	if status != exitOK || len(out) != 1 || mustMarshal(out[0]["data"]) != `{"session_id":"1771900000"}` {
+		t.Errorf("query --sort relevance = %d, %v", status, out)
+	}
+}
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/glopal/sessions/pkg/store"
+	"github.com/spf13/cobra"
+)
+
+var pathsCmd = &cobra.Command{
+	Use:   "paths",
+	Short: "Keep files_changed paths in step with renames",
+	Long: `Keep the paths recorded in files_changed in step with renames made outside
+of sessions, so that 'sessions context' and 'sessions query --file' still find
+the history of a file under its current name.
+
+'sessions validate --paths' lists the recorded paths that no longer exist.`,
+}
+
+var pathsMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Follow renames in git history for paths that no longer exist",
+	Long: `Look up each files_changed path that no longer exists in the working tree
+in the renames of git history, following a file through every rename to its
+current name.
+
+By default the renames found are recorded as aliases in .sessions/` + store.PathsFileName + `,
+leaving the sessions as written: context and query then treat the old path as
+an earlier name of the current one. With --rewrite, the files_changed entries
+are changed to the current path instead.
+
+Paths that were deleted, or renamed to a file since deleted, are reported as
+untraced. Use --dry-run to see what would change.`,
+	Args: cobra.NoArgs,
+	RunE: runPathsMigrate,
+}
+
+var (
+	pathsDryRun  bool
+	pathsRewrite bool
+)
+
+func init() {
+	pathsMigrateCmd.Flags().BoolVar(&pathsDryRun, "dry-run", false, "Show what would change without changing anything")
+	pathsMigrateCmd.Flags().BoolVar(&pathsRewrite, "rewrite", false, "Rewrite files_changed paths instead of recording aliases")
+	pathsCmd.AddCommand(pathsMigrateCmd)
+	rootCmd.AddCommand(pathsCmd)
+}
+
+// pathMigration is the outcome of tracing the recorded paths that no longer
+// exist through renames.
+type pathMigration struct {
+	// Renames maps each traced path to its current name.
+	Renames map[string]string
+	// Sessions lists, for each traced path, the sessions recording it.
+	Sessions map[string][]string
+	Untraced []untracedPath
+}
+
+type untracedPath struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+func runPathsMigrate(cmd *cobra.Command, args []string) error {
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	workTree := filepath.Dir(st.Dir())
+	renames, err := getGitRenames(workTree)
+	if err != nil {
+		return err
+	}
+	recorded, err := st.Renames()
+	if err != nil {
+		return err
+	}
+	sessions, err := st.ListAll()
+	if err != nil {
+		return err
+	}
+
+	m := planPathMigration(sessions, renames, recorded, workTree, pathsRewrite)
+	res := pathsMigrateResult{Renames: []pathRename{}, Untraced: nonNil(m.Untraced), DryRun: pathsDryRun, Rewrite: pathsRewrite}
+	for _, old := range slices.Sorted(maps.Keys(m.Renames)) {
+		res.Renames = append(res.Renames, pathRename{From: old, To: m.Renames[old], Sessions: m.Sessions[old]})
+	}
+
+	var werr error
+	switch {
+	case len(m.Renames) == 0 || pathsDryRun:
+	case pathsRewrite:
+		res.Rewritten, werr = rewritePaths(st, m)
+	default:
+		werr = st.AddRenames(m.Renames)
+	}
+	if werr != nil && !pathsRewrite {
+		return werr
+	}
+	if err := writeResult(res, func() error {
+		printPathsMigrateResult(res)
+		return nil
+	}); err != nil {
+		return err
+	}
+	return werr
+}
+
+// pathsMigrateResult is the JSON result of paths migrate. Rewritten is the
+// number of sessions rewritten with --rewrite.
+type pathsMigrateResult struct {
+	Renames   []pathRename   `json:"renames"`
+	Untraced  []untracedPath `json:"untraced"`
+	DryRun    bool           `json:"dry_run"`
+	Rewrite   bool           `json:"rewrite"`
+	Rewritten int            `json:"rewritten,omitempty"`
+}
+
+type pathRename struct {
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	Sessions []string `json:"sessions"`
+}
+
+func printPathsMigrateResult(res pathsMigrateResult) {
+	if len(res.Renames) == 0 && len(res.Untraced) == 0 {
+		fmt.Println("Nothing to migrate.")
+		return
+	}
+	if len(res.Renames) > 0 {
+		fmt.Println("Renamed:")
+		for _, r := range res.Renames {
+			fmt.Printf("  %s -> %s (%s)\n", r.From, r.To, plural(len(r.Sessions), "session"))
+		}
+	}
+	if len(res.Untraced) > 0 {
+		fmt.Println("Untraced:")
+		for _, u := range res.Untraced {
+			fmt.Printf("  %s: %s\n", u.Path, u.Reason)
+		}
+	}
+	if len(res.Renames) == 0 {
+		return
+	}
+
+	fmt.Println()
+	switch {
+	case res.DryRun:
+		fmt.Println("Dry run: nothing written.")
+	case res.Rewrite:
+		fmt.Printf("Rewrote %s.\n", plural(res.Rewritten, "session"))
+	default:
+		fmt.Printf("Recorded %s in .sessions/%s.\n", plural(len(res.Renames), "rename"), store.PathsFileName)
+	}
+}
+
+// planPathMigration traces the non-deleted paths recorded by sessions that do
+// not exist below workTree through renames, which maps old paths to new ones
+// as found in git history. Paths already traced by recorded, the aliases in
+// .sessions/paths.yaml, are left out unless rewrite is set.
+func planPathMigration(sessions []*store.Session, renames, recorded map[string]string, workTree string, rewrite bool) *pathMigration {
+	exists := func(p string) bool {
+		_, err := os.Stat(filepath.Join(workTree, filepath.FromSlash(p)))
+		return err == nil
+	}
+	all := maps.Clone(renames)
+	maps.Copy(all, recorded)
+
+	m := &pathMigration{Renames: make(map[string]string), Sessions: make(map[string][]string)}
+	untraced := make(map[string]bool)
+	for _, s := range sessions {
+		for _, fc := range s.FilesChanged {
+			p := fc.Path
+			if fc.Action == "deleted" || p == "" || untraced[p] || exists(p) {
+				continue
+			}
+			if _, ok := m.Renames[p]; ok {
+				if !slices.Contains(m.Sessions[p], s.SessionID) {
+					m.Sessions[p] = append(m.Sessions[p], s.SessionID)
+				}
+				continue
+			}
+			cur := store.FollowRenames(all, p)
+			switch {
+			case cur != p && exists(cur):
+				if store.FollowRenames(recorded, p) == cur && !rewrite {
+					continue
+				}
+				m.Renames[p] = cur
+				m.Sessions[p] = []string{s.SessionID}
+			case cur != p:
+				untraced[p] = true
+				m.Untraced = append(m.Untraced, untracedPath{p, "renamed to " + cur + ", which no longer exists"})
+			default:
+				untraced[p] = true
+				m.Untraced = append(m.Untraced, untracedPath{p, "not renamed in git history; deleted?"})
+			}
+		}
+	}
+	slices.SortFunc(m.Untraced, func(a, b untracedPath) int { return strings.Compare(a.Path, b.Path) })
+	return m
+}
+
+// rewritePaths changes the traced paths in the files_changed of the sessions
+// recording them to their current names, returning the number of sessions
+// rewritten.
+func rewritePaths(st *store.Store, m *pathMigration) (int, error) {
+	var ids []string
+	for _, sessions := range m.Sessions {
+		for _, id := range sessions {
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	slices.Sort(ids)
+	n := 0
+	for _, id := range ids {
+		err := st.UpdateSession(id, func(s *store.Session) error {
+			for i, fc := range s.FilesChanged {
+				if cur, ok := m.Renames[fc.Path]; ok && fc.Action != "deleted" {
+					s.FilesChanged[i].Path = cur
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return n, fmt.Errorf("rewriting %s: %w", id, err)
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/glopal/sessions/internal/config"
+	"github.com/glopal/sessions/pkg/store"
+)
+
+func TestPathsMigrate(t *testing.T) {
+	st := newTestStore(t)
+	workTree := filepath.Dir(st.Dir())
+	for _, p := range []string{"internal/csv/reader.go", "main.go"} {
+		path := filepath.Join(workTree, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestSession(t, st, "1771900000", "files_changed:\n  - path: lib/reader.go\n    action: added\n    summary: New\n  - path: main.go\n    action: modified\n    summary: Wire")
+	writeTestSession(t, st, "1771900100", "files_changed:\n  - path: lib/reader.go\n    action: modified\n    summary: Fix\n  - path: lib/writer.go\n    action: modified\n    summary: Fix")
+	writeTestSession(t, st, "1771900200", "files_changed:\n  - path: scratch.go\n    action: added\n    summary: Try\n  - path: old.go\n    action: deleted\n    summary: Drop")
+
+	sessions, err := st.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	renames := parseGitRenames("R100\tlib/reader.go\tinternal/reader.go\n\nR100\tlib/writer.go\tinternal/writer.go\nR091\tinternal/reader.go\tinternal/csv/reader.go\n")
+
+	m := planPathMigration(sessions, renames, nil, workTree, false)
+	if want := map[string]string{"lib/reader.go": "internal/csv/reader.go"}; !maps.Equal(m.Renames, want) {
+		t.Errorf("renames = %v, want %v", m.Renames, want)
+	}
+	if got := m.Sessions["lib/reader.go"]; len(got) != 2 {
+		t.Errorf("sessions of lib/reader.go = %v, want both", got)
+	}
+	want := []untracedPath{
+		{"lib/writer.go", "renamed to internal/writer.go, which no longer exists"},
+		{"scratch.go", "not renamed in git history; deleted?"},
+	}
+	if !slices.Equal(m.Untraced, want) {
+		t.Errorf("untraced = %v, want %v", m.Untraced, want)
+	}
+
+	if err := st.AddRenames(m.Renames); err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := st.Renames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := planPathMigration(sessions, renames, recorded, workTree, false); len(m.Renames) != 0 {
+		t.Errorf("recorded renames planned again: %v", m.Renames)
+	}
+
+	groups := collectContext(st, config.Default(), sessions, []string{"internal/csv/reader.go"})
+	if len(groups) != 1 || len(groups[0].Entries) != 2 || !slices.Equal(groups[0].RenamedFrom, []string{"lib/reader.go"}) {
+		t.Errorf("context does not follow the recorded rename: %+v", groups)
+	}
+	matches, _, err := st.Query(store.Query{File: "internal/csv/*.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 || !slices.Equal(matches[0].MatchedFiles, []string{"lib/reader.go"}) {
+		t.Errorf("query --file does not follow the recorded rename: %d matches", len(matches))
+	}
+
+	m = planPathMigration(sessions, renames, recorded, workTree, true)
+	if n, err := rewritePaths(st, m); err != nil || n != 2 {
+		t.Fatalf("rewritePaths() = %d, %v", n, err)
+	}
+	s, err := st.Get("1771900100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.FilesChanged[0].Path != "internal/csv/reader.go" || s.FilesChanged[1].Path != "lib/writer.go" {
+		t.Errorf("files_changed after rewrite = %+v", s.FilesChanged)
+	}
+}
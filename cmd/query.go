@@ -3,20 +3,45 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
-	"github.com/gobwas/glob"
-	"github.com/glopal/sessions/internal/root"
-	"github.com/glopal/sessions/internal/session"
+	"github.com/glopal/sessions/pkg/store"
 	"github.com/spf13/cobra"
 )
 
 var queryCmd = &cobra.Command{
-	Use:   "query",
+	Use:   "query [expression]",
 	Short: "Search sessions by various criteria",
-	RunE:  runQuery,
+	Long: `Search sessions by flags, a boolean query expression, or both (ANDed).
+
+An expression combines field:value terms with AND, OR, NOT and parentheses.
+Adjacent terms are ANDed; bare words and quoted phrases search text.
+
+  sessions query 'tag:auth AND (file:internal/** OR artifact:decision) AND NOT tag:spike'
+  sessions query 'status:accepted after:2026-01-01 "token refresh"'
+
+Fields:
+  tag:       session tag (glob)
+  file:      changed file path (glob)
+  artifact:  artifact type
+  status:    artifact status
+  after:     sessions on or after a date (YYYY-MM-DD)
+  before:    sessions on or before a date (YYYY-MM-DD)
+  date:      sessions on a date (YYYY-MM-DD)
+  commit:    commit hash recorded on the session (4+ characters)
+  branch:    git branch the session was recorded on (glob)
+  summary:   text in the session summary
+  body:      text in the session body
+  text:      text in the summary, body, file summaries or tags (default)
+
+` + pageHelp + `
+
+With --search, --sort relevance orders sessions by their best full-text
+score. --rank lists the scored hits themselves, best first; it pages with
+--limit and --offset only.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runQuery,
 }
 
 var (
@@ -25,180 +50,121 @@ var (
 	queryArtifactType string
 	queryAfter        string
 	queryBefore       string
+	queryCommit       string
+	queryBranch       string
 	querySearch       string
-	queryLimit        int
+	queryRank         bool
+	queryPage         pageFlags
 	queryFormat       string
+	queryArchived     bool
 )
 
+// querySorts are the orders query can sort sessions in.
+var querySorts = []string{sortTimestamp, sortFiles, sortArtifacts, sortRelevance}
+
 func init() {
 	queryCmd.Flags().StringVar(&queryFile, "file", "", "Filter by file path (exact or glob)")
 	queryCmd.Flags().StringVar(&queryTag, "tag", "", "Filter by tag")
 	queryCmd.Flags().StringVar(&queryArtifactType, "artifact-type", "", "Filter by artifact type")
 	queryCmd.Flags().StringVar(&queryAfter, "after", "", "Filter sessions after date (YYYY-MM-DD)")
 	queryCmd.Flags().StringVar(&queryBefore, "before", "", "Filter sessions before date (YYYY-MM-DD)")
-	queryCmd.Flags().StringVar(&querySearch, "search", "", "Full-text search across session bodies")
-	queryCmd.Flags().IntVar(&queryLimit, "limit", 0, "Limit number of results")
+	queryCmd.Flags().StringVar(&queryCommit, "commit", "", "Filter by a commit recorded on the session (hash or ref)")
+	queryCmd.Flags().StringVar(&queryBranch, "branch", "", "Filter by the git branch the session was recorded on")
+	queryCmd.Flags().StringVar(&querySearch, "search", "", "Full-text search across sessions and artifacts (\"phrases\", prefix*)")
+	queryCmd.Flags().BoolVar(&queryRank, "rank", false, "Order --search results by relevance and show snippets")
+	queryCmd.Flags().BoolVar(&queryArchived, "include-archived", false, "Include archived sessions")
+	queryPage.register(queryCmd, querySorts)
 	queryCmd.Flags().StringVar(&queryFormat, "format", "text", "Output format: text or json")
+	queryCmd.Flags().MarkDeprecated("format", "use --output json")
 	rootCmd.AddCommand(queryCmd)
 }
 
 func runQuery(cmd *cobra.Command, args []string) error {
-	sessionsDir, err := root.SessionsDir()
+	st, err := openStore()
 	if err != nil {
 		return err
 	}
 
-	if err := ensureSessionsDir(sessionsDir); err != nil {
-		return err
+	if queryRank && querySearch == "" {
+		return usageErrorf("--rank requires --search")
 	}
-
-	sessions, err := loadAllSessions(sessionsDir)
-	if err != nil {
+	if err := queryPage.check(querySorts); err != nil {
 		return err
 	}
-
-	var results []*queryResult
-	for _, s := range sessions {
-		r := matchSession(s)
-		if r != nil {
-			results = append(results, r)
-		}
+	if queryPage.sort == sortRelevance && querySearch == "" {
+		return usageErrorf("--sort relevance requires --search")
 	}
-
-	if len(results) == 0 {
-		fmt.Println("No matching sessions found.")
-		os.Exit(2)
+	if queryRank && (cmd.Flags().Changed("sort") || queryPage.reverse || queryPage.cursor != "") {
+		return usageErrorf("--rank orders hits by score; page them with --limit and --offset")
 	}
 
-	if queryLimit > 0 && len(results) > queryLimit {
-		results = results[:queryLimit]
-	}
+	q := store.Query{
+		File:         queryFile,
+		Tag:          queryTag,
+		ArtifactType: queryArtifactType,
+		After:        queryAfter,
+		Before:       queryBefore,
+		Commit:       resolveGitCommit(queryCommit),
+		Branch:       queryBranch,
+		Search:       querySearch,
 
-	if queryFormat == "json" {
-		return outputQueryJSON(results)
+		IncludeArchived: queryArchived,
+	}
+	if len(args) == 1 {
+		q.Expr = args[0]
 	}
-	return outputQueryText(results)
-}
-
-type queryResult struct {
-	Session          *session.Session
-	MatchedFiles     []string
-	MatchedTags      []string
-	MatchedArtifacts []string
-}
 
-func matchSession(s *session.Session) *queryResult {
-	r := &queryResult{Session: s}
-	matched := true
-
-	// File filter
-	if queryFile != "" {
-		matched = false
-		g, err := glob.Compile(queryFile)
-		if err != nil {
-			// Fall back to exact match
-			for _, f := range s.FilesChanged {
-				if f.Path == queryFile {
-					r.MatchedFiles = append(r.MatchedFiles, f.Path)
-					matched = true
-				}
-			}
-		} else {
-			for _, f := range s.FilesChanged {
-				if g.Match(f.Path) {
-					r.MatchedFiles = append(r.MatchedFiles, f.Path)
-					matched = true
-				}
-			}
-		}
-		if !matched {
-			return nil
-		}
+	results, hits, err := st.Query(q)
+	if err != nil {
+		return err
 	}
 
-	// Tag filter
-	if queryTag != "" {
-		found := false
-		for _, t := range s.Tags {
-			if t == queryTag {
-				r.MatchedTags = append(r.MatchedTags, t)
-				found = true
-			}
-		}
-		if !found {
-			return nil
-		}
+	if queryRank {
+		return outputRanked(hits)
 	}
 
-	// Artifact type filter
-	if queryArtifactType != "" {
-		found := false
-		for _, a := range s.Artifacts {
-			if a.Type == queryArtifactType {
-				r.MatchedArtifacts = append(r.MatchedArtifacts, a.Path)
-				found = true
-			}
-		}
-		if !found {
-			return nil
-		}
+	scores := make(map[string]float64)
+	for _, h := range hits {
+		scores[h.SessionID] = max(scores[h.SessionID], h.Score)
+	}
+	results, next, err := paginate(results, func(m *store.Match) *store.Session { return m.Session }, &queryPage, scores)
+	if err != nil {
+		return err
+	}
+	res := queryResult{NextCursor: next}
+	if res.Results, err = pageItems(nonNil(queryJSONResults(results)), queryPage.fields); err != nil {
+		return err
 	}
 
-	// Date filters
-	if queryAfter != "" {
-		afterDate, err := parseDateStr(queryAfter)
-		if err == nil && s.Timestamp.Before(afterDate) {
+	err = writeResult(res, func() error {
+		if len(results) == 0 {
+			fmt.Println("No matching sessions found.")
 			return nil
 		}
-	}
-
-	if queryBefore != "" {
-		beforeDate, err := parseDateStr(queryBefore)
-		if err == nil {
-			// Add a day to make "before" inclusive of the date
-			endOfDay := beforeDate.AddDate(0, 0, 1)
-			if s.Timestamp.After(endOfDay) || s.Timestamp.Equal(endOfDay) {
-				return nil
-			}
+		defer printNextCursor(next)
+		if printProjected(res.Results) {
+			return nil
 		}
-	}
-
-	// Full-text search
-	if querySearch != "" {
-		searchLower := strings.ToLower(querySearch)
-		bodyLower := strings.ToLower(s.Body)
-		if !strings.Contains(bodyLower, searchLower) {
-			// Also search in file summaries and tags
-			found := false
-			for _, f := range s.FilesChanged {
-				if strings.Contains(strings.ToLower(f.Summary), searchLower) {
-					found = true
-					break
-				}
-			}
-			if !found {
-				return nil
-			}
+		if queryFormat == "json" {
+			return outputQueryJSON(results)
 		}
+		return outputQueryText(results)
+	})
+	if err == nil && len(results) == 0 {
+		return errNoResults
 	}
-
-	return r
-}
-
-func parseDateStr(dateStr string) (time.Time, error) {
-	t, err := time.Parse("2006-01-02", dateStr)
-	if err != nil {
-		return time.Time{}, fmt.Errorf("invalid date format %q (expected YYYY-MM-DD): %w", dateStr, err)
-	}
-	return t, nil
+	return err
 }
 
-func outputQueryText(results []*queryResult) error {
+func outputQueryText(results []*store.Match) error {
 	for _, r := range results {
 		summary := r.Session.Summary
 		if summary == "" {
 			summary = "(no summary)"
 		}
+		if r.Session.Archived {
+			summary += " (archived)"
+		}
 		line := fmt.Sprintf("%s  %s", r.Session.SessionID, summary)
 
 		var extras []string
@@ -219,26 +185,46 @@ func outputQueryText(results []*queryResult) error {
 	return nil
 }
 
+// queryResult is the JSON result of query. Results holds queryJSONResult
+// values, or their projections with --fields.
+type queryResult struct {
+	Results    any    `json:"results"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func (r queryResult) items() any         { return r.Results }
+func (r queryResult) nextCursor() string { return r.NextCursor }
+
 type queryJSONResult struct {
-	SessionID string   `json:"session_id"`
-	Summary   string   `json:"summary"`
-	Tags      []string `json:"tags"`
-	Files     []string `json:"matched_files,omitempty"`
-	Artifacts []string `json:"matched_artifacts,omitempty"`
+	SessionID string    `json:"session_id"`
+	Slug      string    `json:"slug,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Summary   string    `json:"summary"`
+	Tags      []string  `json:"tags"`
+	Files     []string  `json:"matched_files,omitempty"`
+	Artifacts []string  `json:"matched_artifacts,omitempty"`
+	Archived  bool      `json:"archived,omitempty"`
+}
+
+func outputQueryJSON(results []*store.Match) error {
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(queryJSONResults(results))
 }
 
-func outputQueryJSON(results []*queryResult) error {
+func queryJSONResults(results []*store.Match) []queryJSONResult {
 	var jsonResults []queryJSONResult
 	for _, r := range results {
 		jsonResults = append(jsonResults, queryJSONResult{
 			SessionID: r.Session.SessionID,
+			Slug:      r.Session.Slug,
+			Timestamp: r.Session.Timestamp,
 			Summary:   r.Session.Summary,
 			Tags:      r.Session.Tags,
 			Files:     r.MatchedFiles,
 			Artifacts: r.MatchedArtifacts,
+			Archived:  r.Session.Archived,
 		})
 	}
-	enc := json.NewEncoder(os.Stdout)
-	enc.SetIndent("", "  ")
-	return enc.Encode(jsonResults)
+	return jsonResults
 }
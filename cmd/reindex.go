@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/glopal/sessions/pkg/store"
+	"github.com/spf13/cobra"
+)
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Rebuild the session index from scratch",
+	Long: `Rebuild .sessions/index.json by re-parsing every session and artifact file.
+
+The index is normally kept up to date automatically; use this after editing
+files by hand if results look stale, or to recover from a corrupt index.`,
+	RunE: runReindex,
+}
+
+func init() {
+	rootCmd.AddCommand(reindexCmd)
+}
+
+func runReindex(cmd *cobra.Command, args []string) error {
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	sessions, artifacts, err := st.Rebuild()
+	if err != nil {
+		return err
+	}
+	if err := store.EnsureGitignored(st.Dir()); err != nil {
+		return err
+	}
+
+	failures, err := st.Failures()
+	if err != nil {
+		return err
+	}
+	for _, f := range failures {
+		fmt.Fprintf(os.Stderr, "warning: skipping %s: %s\n", f.Path, f.Err)
+	}
+	return writeResult(reindexResult{Sessions: sessions, Artifacts: artifacts, Skipped: len(failures)}, func() error {
+		fmt.Printf("Indexed %d sessions and %d artifacts\n", sessions, artifacts)
+		return nil
+	})
+}
+
+// reindexResult is the JSON result of reindex: the number of files indexed,
+// and of those skipped because they could not be parsed.
+type reindexResult struct {
+	Sessions  int `json:"sessions"`
+	Artifacts int `json:"artifacts"`
+	Skipped   int `json:"skipped"`
+}
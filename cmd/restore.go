@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <key>",
+	Short: "Move an archived session or artifact back out of the archive",
+	Long: `Move an archived session or artifact back out of .sessions/archive/ and
+repair the references archiving removed. A restored session brings back the
+artifacts it lists and is linked again from its related sessions. An
+artifact can only be restored into a session that is not archived.
+Use --dry-run to see what would change.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRestore,
+}
+
+var restoreDryRun bool
+
+func init() {
+	restoreCmd.Flags().BoolVar(&restoreDryRun, "dry-run", false, "Show what would be restored without changing anything")
+	rootCmd.AddCommand(restoreCmd)
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	c, err := st.Restore(args[0], restoreDryRun)
+	if err != nil {
+		return err
+	}
+	return printChange(c, restoreDryRun, "Restored", "Would restore")
+}
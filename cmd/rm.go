@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var rmCmd = &cobra.Command{
+	Use:   "rm <key>",
+	Short: "Delete a session or artifact",
+	Long: `Delete a session or artifact, archived or not. Deleting a session deletes its
+artifacts too.
+
+References to what was deleted are cleaned up across the store: the session
+is dropped from other sessions' related_sessions, an artifact from its
+session's artifacts list, and supersedes pointers to deleted artifacts are
+cleared. Use --dry-run to see what would change.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRm,
+}
+
+var rmDryRun bool
+
+func init() {
+	rmCmd.Flags().BoolVar(&rmDryRun, "dry-run", false, "Show what would be deleted without changing anything")
+	rootCmd.AddCommand(rmCmd)
+}
+
+func runRm(cmd *cobra.Command, args []string) error {
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	c, err := st.Remove(args[0], rmDryRun)
+	if err != nil {
+		return err
+	}
+	return printChange(c, rmDryRun, "Removed", "Would remove")
+}
@@ -9,11 +9,15 @@ import (
 var rootCmd = &cobra.Command{
 	Use:   "sessions",
 	Short: "Manage local, file-based session memory",
-	Long:  `sessions is a CLI tool for managing a local, file-based session memory system. It stores concise session summaries as markdown files with YAML frontmatter, supports deep-dive documentation, and provides query tooling to retrieve context.`,
+	Long: `sessions is a CLI tool for managing a local, file-based session memory system. It stores concise session summaries as markdown files with YAML frontmatter, supports deep-dive documentation, and provides query tooling to retrieve context.
+
+` + outputHelp,
+	SilenceErrors: true,
+	SilenceUsage:  true,
 }
 
+// Execute runs the command line and exits with the status documented in
+// the root command's help.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
-	}
+	os.Exit(execute(os.Args[1:]))
 }
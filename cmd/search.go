@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/glopal/sessions/pkg/store"
+)
+
+// outputRanked prints scored search hits with their snippets.
+func outputRanked(ranked []store.Hit) error {
+	ranked = ranked[min(queryPage.offset, len(ranked)):]
+	if queryPage.limit > 0 && len(ranked) > queryPage.limit {
+		ranked = ranked[:queryPage.limit]
+	}
+
+	hits, err := pageItems(nonNil(rankedJSONResults(ranked)), queryPage.fields)
+	if err != nil {
+		return err
+	}
+	err = writeResult(rankedResult{Hits: hits}, func() error {
+		if len(ranked) == 0 {
+			fmt.Println("No matching sessions found.")
+			return nil
+		}
+		if printProjected(hits) {
+			return nil
+		}
+		if queryFormat == "json" {
+			return outputRankedJSON(ranked)
+		}
+		printRanked(ranked)
+		return nil
+	})
+	if err == nil && len(ranked) == 0 {
+		return errNoResults
+	}
+	return err
+}
+
+func printRanked(ranked []store.Hit) {
+	for _, h := range ranked {
+		title := h.Title
+		if title == "" {
+			title = "(no summary)"
+		}
+		fmt.Printf("%s  %.2f  %s\n", h.Key, h.Score, title)
+		if h.Snippet != "" {
+			fmt.Printf("    %s: %s\n", h.Field, h.Snippet)
+		}
+	}
+}
+
+// rankedResult is the JSON result of query --rank. Hits holds
+// rankedJSONResult values, or their projections with --fields.
+type rankedResult struct {
+	Hits any `json:"hits"`
+}
+
+func (r rankedResult) items() any { return r.Hits }
+
+type rankedJSONResult struct {
+	Key       string  `json:"key"`
+	SessionID string  `json:"session_id"`
+	Title     string  `json:"title"`
+	Score     float64 `json:"score"`
+	Field     string  `json:"field"`
+	Snippet   string  `json:"snippet"`
+}
+
+func outputRankedJSON(hits []store.Hit) error {
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rankedJSONResults(hits))
+}
+
+func rankedJSONResults(hits []store.Hit) []rankedJSONResult {
+	var jsonResults []rankedJSONResult
+	for _, h := range hits {
+		jsonResults = append(jsonResults, rankedJSONResult{
+			Key:       h.Key,
+			SessionID: h.SessionID,
+			Title:     h.Title,
+			Score:     h.Score,
+			Field:     h.Field,
+			Snippet:   h.Snippet,
+		})
+	}
+	return jsonResults
+}
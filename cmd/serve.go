@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/glopal/sessions/internal/session"
+	"github.com/glopal/sessions/pkg/store"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve sessions over a local HTTP/JSON API",
+	Long: `Serve sessions over a local HTTP/JSON API.
+
+  GET  /sessions                 List sessions; filters: q (expression), tag, file,
+                                 artifact_type, after, before, commit, branch,
+                                 search, limit
+  POST /sessions                 Create a session from a JSON body
+  GET  /sessions/{id}            Fetch a session
+  POST /sessions/{id}            Create an artifact in the session from a JSON body;
+                                 its type and status must be declared in config.yaml
+  GET  /sessions/{id}/{artifact} Fetch an artifact
+  GET  /artifacts                List artifact statuses; filters: type, stale
+
+URLs use the same keys as the CLI. Single resources carry an ETag derived from
+the file's modification time and honour If-None-Match. Errors are returned as
+{"error": {"status": N, "message": "..."}}.`,
+	Args:        cobra.NoArgs,
+	RunE:        runServe,
+	Annotations: map[string]string{noEnvelopeAnnotation: "true"},
+}
+
+var serveAddr string
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:7420", "Address to listen on")
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Serving %s on http://%s\n", st.Dir(), serveAddr)
+	return http.ListenAndServe(serveAddr, newServeHandler(st))
+}
+
+// newServeHandler returns the HTTP API for st.
+func newServeHandler(st *store.Store) http.Handler {
+	a := &apiServer{store: st}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sessions", a.listSessions)
+	mux.HandleFunc("POST /sessions", a.createSession)
+	mux.HandleFunc("GET /sessions/{key...}", a.getKey)
+	mux.HandleFunc("POST /sessions/{id}", a.createArtifact)
+	mux.HandleFunc("GET /artifacts", a.listArtifacts)
+	return mux
+}
+
+type apiServer struct {
+	store *store.Store
+}
+
+// apiError is an error with an HTTP status code.
+type apiError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+func errStatus(status int, format string, args ...any) *apiError {
+	return &apiError{Status: status, Message: fmt.Sprintf(format, args...)}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// writeError renders err, mapping store error kinds to HTTP statuses.
+func writeError(w http.ResponseWriter, err error) {
+	var ae *apiError
+	if !errors.As(err, &ae) {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, store.ErrExists):
+			status = http.StatusConflict
+		case errors.Is(err, store.ErrInvalid):
+			status = http.StatusUnprocessableEntity
+		}
+		ae = &apiError{Status: status, Message: err.Error()}
+	}
+	writeJSON(w, ae.Status, map[string]any{"error": ae})
+}
+
+// resolveKey maps a key to its file, reporting keys that escape the sessions
+// directory as not found.
+func (a *apiServer) resolveKey(key string) (string, error) {
+	path, err := a.store.Path(key)
+	if err != nil {
+		return "", errStatus(http.StatusNotFound, "%s not found", key)
+	}
+	return path, nil
+}
+
+// checkETag sets the ETag for the file at path and reports whether the client's
+// copy is current, in which case a 304 has already been written.
+func checkETag(w http.ResponseWriter, r *http.Request, info os.FileInfo) bool {
+	etag := fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+	w.Header().Set("ETag", etag)
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+func (a *apiServer) listSessions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := store.Query{
+		File:         q.Get("file"),
+		Tag:          q.Get("tag"),
+		ArtifactType: q.Get("artifact_type"),
+		After:        q.Get("after"),
+		Before:       q.Get("before"),
+		Commit:       q.Get("commit"),
+		Branch:       q.Get("branch"),
+		Search:       q.Get("search"),
+		Expr:         q.Get("q"),
+	}
+	limit := 0
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			writeError(w, errStatus(http.StatusUnprocessableEntity, "invalid limit %q", s))
+			return
+		}
+		limit = n
+	}
+
+	results, hits, err := a.store.Query(opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if limit > 0 {
+		results = results[:min(limit, len(results))]
+		hits = hits[:min(limit, len(hits))]
+	}
+
+	out := map[string]any{"sessions": nonNil(queryJSONResults(results))}
+	if opts.Search != "" {
+		out["hits"] = nonNil(rankedJSONResults(hits))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// artifactResponse is an artifact together with its addressing information.
+type artifactResponse struct {
+	Key       string `json:"key"`
+	SessionID string `json:"session_id"`
+	Name      string `json:"name"`
+	*session.Artifact
+}
+
+func (a *apiServer) getKey(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	path, err := a.resolveKey(key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		writeError(w, errStatus(http.StatusNotFound, "%s not found", key))
+		return
+	}
+	if checkETag(w, r, info) {
+		return
+	}
+
+	sessionID, artifactFile, isArtifact := session.ParseKey(key)
+	if isArtifact {
+		art, err := a.store.GetArtifact(key)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, artifactResponse{Key: key, SessionID: sessionID, Name: artifactFile, Artifact: art})
+		return
+	}
+	s, err := a.store.Get(sessionID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// decodeBody strictly decodes a JSON request body, reporting problems as 422.
+func decodeBody(r *http.Request, v any) error {
+	dec := json.NewDecoder(io.LimitReader(r.Body, 16<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return errStatus(http.StatusUnprocessableEntity, "invalid request body: %v", err)
+	}
+	return nil
+}
+
+func (a *apiServer) createSession(w http.ResponseWriter, r *http.Request) {
+	var in session.Session
+	if err := decodeBody(r, &in); err != nil {
+		writeError(w, err)
+		return
+	}
+	if in.Body == "" || in.Template != "" {
+		body, err := sessionBody(a.store.Dir(), in.Template, &in)
+		if err != nil {
+			writeError(w, errStatus(http.StatusUnprocessableEntity, "%v", err))
+			return
+		}
+		if in.Body == "" {
+			in.Body = body
+		}
+	}
+
+	s, err := a.store.CreateSession(&in)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/sessions/"+session.FormatSessionKey(s.SessionID))
+	writeJSON(w, http.StatusCreated, s)
+}
+
+func (a *apiServer) createArtifact(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+
+	var in struct {
+		Name string `json:"name"`
+		session.Artifact
+	}
+	if err := decodeBody(r, &in); err != nil {
+		writeError(w, err)
+		return
+	}
+	if in.Name == "" || strings.ContainsAny(in.Name, `/\`) || strings.HasPrefix(in.Name, ".") {
+		writeError(w, errStatus(http.StatusUnprocessableEntity, "name must be a plain file name"))
+		return
+	}
+
+	name := ensureMD(in.Name)
+	key := session.FormatArtifactKey(sessionID, name)
+
+	art := in.Artifact
+	if art.Title == "" {
+		art.Title = titleFromName(name)
+	}
+	if _, err := createArtifact(a.store, sessionID, name, &art); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/sessions/"+key)
+	writeJSON(w, http.StatusCreated, artifactResponse{Key: key, SessionID: sessionID, Name: name, Artifact: &art})
+}
+
+func (a *apiServer) listArtifacts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	stale := false
+	if s := q.Get("stale"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			writeError(w, errStatus(http.StatusUnprocessableEntity, "invalid stale %q", s))
+			return
+		}
+		stale = b
+	}
+	entries, err := collectArtifactStatuses(a.store, q.Get("type"), stale)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"artifacts": nonNil(entries)})
+}
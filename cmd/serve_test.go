@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func doRequest(t *testing.T, h http.Handler, method, target, body string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeJSON(t *testing.T, rec *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+	return out
+}
+
+func TestServeSessions(t *testing.T) {
+	h := newServeHandler(newTestStore(t))
+
+	rec := doRequest(t, h, "POST", "/sessions", `{"summary":"Add loader","tags":["csv"],"files_changed":[{"path":"loader.go","action":"added","summary":"New"}]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /sessions = %d: %s", rec.Code, rec.Body.String())
+	}
+	id := decodeJSON(t, rec)["session_id"].(string)
+	if loc := rec.Header().Get("Location"); loc != "/sessions/"+id {
+		t.Errorf("Location = %q", loc)
+	}
+
+	rec = doRequest(t, h, "GET", "/sessions/"+id, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET session = %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := decodeJSON(t, rec)["summary"]; got != "Add loader" {
+		t.Errorf("summary = %v", got)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag")
+	}
+	if rec := doRequest(t, h, "GET", "/sessions/"+id, "", "If-None-Match", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("conditional GET = %d, want 304", rec.Code)
+	}
+
+	rec = doRequest(t, h, "GET", "/sessions?q=tag:csv%20AND%20file:*.go", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /sessions = %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := len(decodeJSON(t, rec)["sessions"].([]any)); got != 1 {
+		t.Errorf("query returned %d sessions, want 1", got)
+	}
+
+	rec = doRequest(t, h, "GET", "/sessions?tag=other", "")
+	if got := len(decodeJSON(t, rec)["sessions"].([]any)); got != 0 {
+		t.Errorf("filtered query returned %d sessions, want 0", got)
+	}
+}
+
+func TestServeArtifacts(t *testing.T) {
+	h := newServeHandler(newTestStore(t))
+	rec := doRequest(t, h, "POST", "/sessions", `{"summary":"Base"}`)
+	id := decodeJSON(t, rec)["session_id"].(string)
+
+	rec = doRequest(t, h, "POST", "/sessions/"+id, `{"name":"adr","type":"decision","body":"Use CSV."}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST artifact = %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := decodeJSON(t, rec)["key"]; got != id+"/adr.md" {
+		t.Errorf("key = %v", got)
+	}
+
+	rec = doRequest(t, h, "GET", "/sessions/"+id+"/adr.md", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET artifact = %d: %s", rec.Code, rec.Body.String())
+	}
+	out := decodeJSON(t, rec)
+	if out["body"] != "Use CSV." || out["status"] != "draft" || out["title"] != "Adr" {
+		t.Errorf("artifact = %v", out)
+	}
+
+	rec = doRequest(t, h, "POST", "/sessions/"+id, `{"name":"adr","type":"decision","body":"Again"}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("duplicate artifact = %d, want 409", rec.Code)
+	}
+
+	for _, body := range []string{
+		`{"name":"plan","type":"roadmap"}`,
+		`{"name":"plan","type":"decision","status":"shipped"}`,
+		`{"name":"plan","type":"decision","supersedes":"` + id + `/missing.md"}`,
+		`{"name":"plan"}`,
+	} {
+		if rec := doRequest(t, h, "POST", "/sessions/"+id, body); rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("POST artifact %s = %d, want 422: %s", body, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec = doRequest(t, h, "GET", "/artifacts?type=decision", "")
+	if got := len(decodeJSON(t, rec)["artifacts"].([]any)); got != 1 {
+		t.Errorf("GET /artifacts returned %d, want 1", got)
+	}
+}
+
+func TestServeErrors(t *testing.T) {
+	h := newServeHandler(newTestStore(t))
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{"missing session", "GET", "/sessions/1771953023", "", http.StatusNotFound},
+		{"missing artifact", "GET", "/sessions/1771953023/x.md", "", http.StatusNotFound},
+		{"path traversal", "GET", "/sessions/..%2F..%2Fetc/passwd", "", http.StatusNotFound},
+		{"artifact on missing session", "POST", "/sessions/1771953023", `{"name":"x","type":"analysis"}`, http.StatusNotFound},
+		{"bad query", "GET", "/sessions?q=tag:a%20AND", "", http.StatusUnprocessableEntity},
+		{"bad date", "GET", "/sessions?after=yesterday", "", http.StatusUnprocessableEntity},
+		{"unknown field", "POST", "/sessions", `{"summry":"x"}`, http.StatusUnprocessableEntity},
+		{"long summary", "POST", "/sessions", `{"summary":"` + strings.Repeat("x", 200) + `"}`, http.StatusUnprocessableEntity},
+		{"bad action", "POST", "/sessions", `{"summary":"x","files_changed":[{"path":"a","action":"moved"}]}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(t, h, tt.method, tt.target, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("%s %s = %d, want %d: %s", tt.method, tt.target, rec.Code, tt.status, rec.Body.String())
+			}
+			e, ok := decodeJSON(t, rec)["error"].(map[string]any)
+			if !ok || e["message"] == "" || e["status"] != float64(tt.status) {
+				t.Errorf("error body = %s", rec.Body.String())
+			}
+		})
+	}
+}
@@ -4,93 +4,168 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/glopal/sessions/internal/root"
+	"github.com/glopal/sessions/internal/config"
+	"github.com/glopal/sessions/internal/session"
+	"github.com/glopal/sessions/pkg/store"
 	"github.com/spf13/cobra"
 )
 
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show status of artifacts, flagging stale decisions",
-	RunE:  runStatus,
+	Long: `Show the status of every artifact, most recent session first, flagging
+superseded and deprecated ones. Each status is marked with the icon declared
+for it in .sessions/config.yaml.
+
+With --chain KEY, show only the supersession chain the artifact at KEY
+belongs to, from the first version to the one that replaced all others.`,
+	RunE: runStatus,
 }
 
 var (
 	statusArtifactType string
 	statusStale        bool
+	statusChain        string
 )
 
 func init() {
 	statusCmd.Flags().StringVar(&statusArtifactType, "artifact-type", "", "Filter by artifact type")
-	statusCmd.Flags().BoolVar(&statusStale, "stale", false, "Show only superseded or deprecated artifacts")
+	statusCmd.Flags().BoolVar(&statusStale, "stale", false, "Show only artifacts in a stale status, such as superseded or deprecated")
+	statusCmd.Flags().StringVar(&statusChain, "chain", "", "Show the supersession chain of an artifact, oldest first")
 	rootCmd.AddCommand(statusCmd)
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
-	sessionsDir, err := root.SessionsDir()
+	if statusChain != "" && (statusArtifactType != "" || statusStale) {
+		return usageErrorf("--chain cannot be combined with --artifact-type or --stale")
+	}
+	st, err := openStore()
 	if err != nil {
 		return err
 	}
-
-	if err := ensureSessionsDir(sessionsDir); err != nil {
+	cfg, err := loadConfig(st)
+	if err != nil {
 		return err
 	}
 
-	sessions, err := loadAllSessions(sessionsDir)
+	var entries []artifactStatus
+	if statusChain != "" {
+		entries, err = collectChainStatuses(st, statusChain)
+	} else {
+		entries, err = collectArtifactStatuses(st, statusArtifactType, statusStale)
+	}
 	if err != nil {
 		return err
 	}
 
-	found := false
+	err = writeResult(statusResult{Artifacts: nonNil(entries)}, func() error {
+		if len(entries) == 0 {
+			fmt.Println("No matching artifacts found.")
+		}
+		printStatuses(cfg, entries)
+		return nil
+	})
+	if err == nil && len(entries) == 0 {
+		return errNoResults
+	}
+	return err
+}
+
+// statusResult is the JSON result of status.
+type statusResult struct {
+	Artifacts []artifactStatus `json:"artifacts"`
+}
+
+func (r statusResult) items() any { return r.Artifacts }
+
+func printStatuses(cfg *config.Config, entries []artifactStatus) {
+	for _, e := range entries {
+		fmt.Printf("%s %s  %s  [%s]", cfg.Icon(e.Status), e.Status, e.Key, e.Type)
+		if e.Title != "" {
+			fmt.Printf("  %s", e.Title)
+		}
+		if e.Supersedes != "" {
+			fmt.Printf("  (supersedes: %s)", e.Supersedes)
+		}
+		if e.SupersededBy != "" {
+			fmt.Printf("  (superseded by: %s)", e.SupersededBy)
+		}
+		fmt.Println()
+	}
+}
+
+// artifactStatus is one row of the status report.
+type artifactStatus struct {
+	Key          string `json:"key"`
+	Type         string `json:"type"`
+	Status       string `json:"status"`
+	Title        string `json:"title"`
+	Supersedes   string `json:"supersedes,omitempty"`
+	SupersededBy string `json:"superseded_by,omitempty"`
+}
+
+// collectArtifactStatuses loads every artifact, optionally filtered by type
+// and to those in a stale status, most recent session first.
+func collectArtifactStatuses(st *store.Store, artifactType string, staleOnly bool) ([]artifactStatus, error) {
+	cfg, err := loadConfig(st)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := st.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []artifactStatus
 	for _, s := range sessions {
 		for _, art := range s.Artifacts {
 			// Filter by artifact type
-			if statusArtifactType != "" && art.Type != statusArtifactType {
+			if artifactType != "" && art.Type != artifactType {
 				continue
 			}
 
-			a, err := loadArtifact(sessionsDir, s.SessionID, art.Path)
+			a, err := st.Artifact(s.SessionID, art.Path)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "warning: could not load %s/%s: %v\n", s.SessionID, art.Path, err)
 				continue
 			}
 
 			// Filter stale only
-			if statusStale && a.Status != "superseded" && a.Status != "deprecated" {
+			if staleOnly && !cfg.Stale(a.Status) {
 				continue
 			}
 
-			found = true
-			statusIcon := statusIndicator(a.Status)
-			fmt.Printf("%s %s  %s/%s  [%s]", statusIcon, a.Status, s.SessionID, art.Path, art.Type)
-			if a.Title != "" {
-				fmt.Printf("  %s", a.Title)
-			}
-			if a.Supersedes != "" {
-				fmt.Printf("  (supersedes: %s)", a.Supersedes)
-			}
-			fmt.Println()
+			entries = append(entries, newArtifactStatus(session.FormatArtifactKey(s.SessionID, art.Path), a))
 		}
 	}
+	return entries, nil
+}
 
-	if !found {
-		fmt.Println("No matching artifacts found.")
-		os.Exit(2)
+// collectChainStatuses loads the supersession chain of the artifact at key,
+// oldest first.
+func collectChainStatuses(st *store.Store, key string) ([]artifactStatus, error) {
+	chain, err := st.Chain(key)
+	if err != nil {
+		return nil, err
 	}
-
-	return nil
+	entries := make([]artifactStatus, 0, len(chain))
+	for _, k := range chain {
+		a, err := st.GetArtifact(k)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, newArtifactStatus(k, a))
+	}
+	return entries, nil
 }
 
-func statusIndicator(status string) string {
-	switch status {
-	case "accepted":
-		return "+"
-	case "draft":
-		return "~"
-	case "superseded":
-		return "!"
-	case "deprecated":
-		return "x"
-	default:
-		return "?"
+func newArtifactStatus(key string, a *session.Artifact) artifactStatus {
+	return artifactStatus{
+		Key:          key,
+		Type:         a.Type,
+		Status:       a.Status,
+		Title:        a.Title,
+		Supersedes:   a.Supersedes,
+		SupersededBy: a.SupersededBy,
 	}
 }
@@ -1,119 +1,227 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
-	"github.com/glopal/sessions/internal/parser"
-	"github.com/glopal/sessions/internal/root"
-	"github.com/glopal/sessions/internal/session"
+	"github.com/glopal/sessions/internal/validate"
+	"github.com/glopal/sessions/pkg/store"
 	"github.com/spf13/cobra"
 )
 
 var validateCmd = &cobra.Command{
 	Use:   "validate [keys...]",
 	Short: "Validate sessions and artifacts",
-	RunE:  runValidate,
+	Long: `Validate sessions and artifacts against a set of rules, reporting the
+issues found grouped by rule. With keys, only the issues of those sessions and
+artifacts are reported, though rules still look at the whole store.
+
+Rules:
+` + ruleList() + `
+Each issue is an error or a warning. Errors make validate exit with status 3;
+warnings are reported but do not. The validation section of
+.sessions/config.yaml sets the pattern tags must match and can change a
+rule's severity or turn it off:
+
+  validation:
+    tag_pattern: '^[a-z0-9][a-z0-9._/-]*$'
+    rules:
+      related-sessions: error
+      required-sections: off
+
+Rules marked opt-in only run when asked for: --paths checks that the paths
+in files_changed still exist in the working tree, or were renamed to ones
+that do by 'sessions paths migrate'.
+
+--fix repairs the issues that can be repaired mechanically, such as dangling
+artifact and related session references, one-sided links, misspelled file
+actions and badly formatted tags, then reports what is left.`,
+	RunE: runValidate,
 }
 
+var (
+	validateFormat string
+	validateFix    bool
+	validatePaths  bool
+)
+
 func init() {
+	validateCmd.Flags().StringVar(&validateFormat, "format", "text", "Output format: text or json")
+	validateCmd.Flags().MarkDeprecated("format", "use --output json")
+	validateCmd.Flags().BoolVar(&validateFix, "fix", false, "Repair mechanically fixable issues")
+	validateCmd.Flags().BoolVar(&validatePaths, "paths", false, "Also check that files_changed paths still exist")
 	rootCmd.AddCommand(validateCmd)
 }
 
-type validationIssue struct {
-	Key    string
-	Reason string
+// ruleList describes validate.Rules for the command's help.
+func ruleList() string {
+	var b strings.Builder
+	for _, r := range validate.Rules {
+		severity := string(r.Severity)
+		if r.OptIn {
+			severity += ", opt-in"
+		}
+		fmt.Fprintf(&b, "  %-18s %s (%s)\n", r.Name, r.Description, severity)
+	}
+	return b.String()
+}
+
+type validateResult struct {
+	Valid    bool             `json:"valid"`
+	Errors   int              `json:"errors"`
+	Warnings int              `json:"warnings"`
+	Issues   []validate.Issue `json:"issues"`
+	Fixed    []validate.Issue `json:"fixed,omitempty"`
 }
 
 func runValidate(cmd *cobra.Command, args []string) error {
-	sessionsDir, err := root.SessionsDir()
+	if validateFormat != "text" && validateFormat != "json" {
+		return usageErrorf("invalid format %q (expected text or json)", validateFormat)
+	}
+	st, err := openStore()
 	if err != nil {
 		return err
 	}
 
-	if err := ensureSessionsDir(sessionsDir); err != nil {
+	issues, err := collectValidationIssues(st, args, validatePaths)
+	if err != nil {
 		return err
 	}
-
-	var issues []validationIssue
-
-	if len(args) == 0 {
-		// Validate all sessions and their artifacts
-		sessions, err := loadAllSessions(sessionsDir)
+	var fixed []validate.Issue
+	if validateFix {
+		fixed, err = validate.Apply(st, issues)
 		if err != nil {
-			return err
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
 		}
-		for _, s := range sessions {
-			issues = append(issues, validateSessionSummary(s.SessionID, s.Summary)...)
-			for _, art := range s.Artifacts {
-				key := session.FormatArtifactKey(s.SessionID, art.Path)
-				a, err := loadArtifact(sessionsDir, s.SessionID, art.Path)
-				if err != nil {
-					issues = append(issues, validationIssue{Key: key, Reason: "unreadable"})
-					continue
-				}
-				issues = append(issues, validateArtifactSummary(key, a.Summary)...)
+		if len(fixed) > 0 {
+			if issues, err = collectValidationIssues(st, args, validatePaths); err != nil {
+				return err
 			}
 		}
-	} else {
-		// Validate only specified keys
-		for _, key := range args {
-			sessionID, _, isArtifact := session.ParseKey(key)
-			path := session.ResolveKeyToPath(sessionsDir, key)
-			if isArtifact {
-				a, err := parser.ParseArtifactFile(path)
-				if err != nil {
-					issues = append(issues, validationIssue{Key: key, Reason: "unreadable"})
-					continue
-				}
-				issues = append(issues, validateArtifactSummary(key, a.Summary)...)
-			} else {
-				s, err := parser.ParseSessionFile(path)
-				if err != nil {
-					issues = append(issues, validationIssue{Key: key, Reason: "unreadable"})
-					continue
-				}
-				issues = append(issues, validateSessionSummary(sessionID, s.Summary)...)
-			}
+	}
+
+	res := newValidateResult(issues, fixed)
+	err = writeResult(res, func() error {
+		if validateFormat == "json" {
+			enc := json.NewEncoder(stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(res)
 		}
+		printValidateResult(res)
+		return nil
+	})
+	if err == nil && !res.Valid {
+		return errCheckFailed
+	}
+	return err
+}
+
+func newValidateResult(issues, fixed []validate.Issue) validateResult {
+	errs, warnings := validate.Count(issues)
+	return validateResult{
+		Valid:    errs == 0,
+		Errors:   errs,
+		Warnings: warnings,
+		Issues:   nonNil(issues),
+		Fixed:    fixed,
 	}
+}
 
-	if len(issues) == 0 {
+// printValidateResult prints the fixes made and then the remaining issues,
+// grouped by rule, with a hint for resolving each rule's issues by hand.
+func printValidateResult(res validateResult) {
+	for _, issue := range res.Fixed {
+		fmt.Printf("fixed %s: %s\n", issue.Fix.Key, issue.Fix.Description)
+	}
+	if len(res.Fixed) > 0 {
+		fmt.Println()
+	}
+	if len(res.Issues) == 0 {
 		fmt.Println("All valid.")
-		return nil
+		return
 	}
 
-	fmt.Println("PROBLEM")
-	fmt.Println("Invalid Summary")
-	fmt.Println()
-	fmt.Println("AFFECTED KEYS")
-	for _, issue := range issues {
-		fmt.Printf("- %s (%s)\n", issue.Key, issue.Reason)
+	fixable := 0
+	for i, issue := range res.Issues {
+		if i == 0 || issue.Rule != res.Issues[i-1].Rule {
+			if i > 0 {
+				printRuleHint(res.Issues[i-1].Rule)
+			}
+			rule, _ := validate.Lookup(issue.Rule)
+			fmt.Printf("%s (%s): %s\n", issue.Rule, issue.Severity, rule.Description)
+		}
+		fmt.Printf("  %s: %s", issue.Key, issue.Message)
+		if issue.Fix != nil {
+			fmt.Printf(" [fixable: %s]", issue.Fix.Description)
+			fixable++
+		}
+		fmt.Println()
 	}
-	fmt.Println()
-	fmt.Println("FIX")
-	fmt.Printf("sessions edit <KEY> --summary \"<SUMMARY_LTE_%d_CHARS>\"\n", session.MaxSummaryLength)
+	printRuleHint(res.Issues[len(res.Issues)-1].Rule)
 
-	os.Exit(1)
-	return nil
+	fmt.Printf("%s, %s", plural(res.Errors, "error"), plural(res.Warnings, "warning"))
+	if fixable > 0 {
+		fmt.Printf("; %d fixable with 'sessions validate --fix'", fixable)
+	}
+	fmt.Println()
 }
 
-func validateSessionSummary(sessionID, summary string) []validationIssue {
-	return validateSummary(session.FormatSessionKey(sessionID), summary)
+func printRuleHint(name string) {
+	if rule, ok := validate.Lookup(name); ok && rule.Hint != "" {
+		fmt.Printf("  fix: %s\n", rule.Hint)
+	}
+	fmt.Println()
 }
 
-func validateArtifactSummary(key, summary string) []validationIssue {
-	return validateSummary(key, summary)
-}
+// collectValidationIssues validates the store, returning the issues of the
+// given keys, or all issues when keys is empty. With paths set, the paths
+// rule checks files_changed against the working tree.
+func collectValidationIssues(st *store.Store, keys []string, paths bool) ([]validate.Issue, error) {
+	cfg, err := loadConfig(st)
+	if err != nil {
+		return nil, err
+	}
+	var resolved []string
+	for _, key := range keys {
+		key, err := st.Resolve(key)
+		if err != nil {
+			return nil, err
+		}
+		if !st.Exists(key) {
+			return nil, fmt.Errorf("%s: no such session or artifact", key)
+		}
+		resolved = append(resolved, key)
+	}
 
-func validateSummary(key, summary string) []validationIssue {
-	summary = strings.TrimSpace(summary)
-	if summary == "" {
-		return []validationIssue{{Key: key, Reason: "missing"}}
+	files, err := st.Files()
+	if err != nil {
+		return nil, err
+	}
+	var opts validate.Options
+	if paths {
+		renames, err := st.Renames()
+		if err != nil {
+			return nil, err
+		}
+		opts = validate.Options{Enable: []string{"paths"}, WorkTree: filepath.Dir(st.Dir()), Renames: renames}
+	}
+	issues, err := validate.Run(cfg, st.Dir(), files, opts)
+	if err != nil {
+		return nil, err
 	}
-	if len(summary) > session.MaxSummaryLength {
-		return []validationIssue{{Key: key, Reason: "too long"}}
+	if len(resolved) > 0 {
+		issues = validate.Filter(issues, resolved)
+	}
+	return issues, nil
+}
+
+// plural formats a count of things named by word.
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
 	}
-	return nil
+	return fmt.Sprintf("%d %ss", n, word)
 }
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/glopal/sessions/internal/session"
+)
+
+func TestValidateRequiredSections(t *testing.T) {
+	st := newTestStore(t)
+	s, err := createStubSession(st, "", "bugfix", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sectionIssues := func() []string {
+		t.Helper()
+		issues, err := collectValidationIssues(st, []string{s.SessionID}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var reasons []string
+		for _, issue := range issues {
+			if issue.Rule == "required-sections" {
+				reasons = append(reasons, issue.Message)
+			}
+		}
+		return reasons
+	}
+	want := []string{
+		`required section "Symptoms" is scaffold text`,
+		`required section "Root Cause" is scaffold text`,
+		`required section "Fix" is scaffold text`,
+	}
+	if got := sectionIssues(); !slices.Equal(got, want) {
+		t.Errorf("issues = %q, want %q", got, want)
+	}
+
+	err = st.UpdateSession(s.SessionID, func(s *session.Session) error {
+		s.Body = "## Symptoms\n\nImports hung.\n\n## Root Cause\n\nA deadlock.\n\n## Fix\n"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sectionIssues(); !slices.Equal(got, []string{`required section "Fix" is empty`}) {
+		t.Errorf("issues = %q, want Fix empty", got)
+	}
+}
@@ -0,0 +1,125 @@
+// Package budget fits prioritized pieces of text into an approximate token budget.
+package budget
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// bytesPerToken is the rough ratio of UTF-8 bytes to tokens for English prose
+// and code under common LLM tokenizers.
+const bytesPerToken = 4
+
+// minTruncated is the smallest useful truncated rendering, in tokens. Below
+// this an item is condensed or omitted rather than cut.
+const minTruncated = 32
+
+// TruncationMarker is appended to truncated text.
+const TruncationMarker = "\n\n[... truncated]"
+
+// Estimate returns the approximate number of tokens in s.
+func Estimate(s string) int {
+	return (len(s) + bytesPerToken - 1) / bytesPerToken
+}
+
+// Level is how much of an item made it into the budget.
+type Level int
+
+const (
+	Omitted Level = iota
+	Condensed
+	Truncated
+	Full
+)
+
+func (l Level) String() string {
+	switch l {
+	case Condensed:
+		return "condensed"
+	case Truncated:
+		return "truncated"
+	case Full:
+		return "full"
+	default:
+		return "omitted"
+	}
+}
+
+// Item is one candidate piece of output.
+type Item struct {
+	// Priority orders items; higher priorities are fitted first.
+	Priority float64
+	// Parent is the index of an item that must be shown in full or truncated
+	// for this one to be considered, or -1. A parent must come before its
+	// children and have at least their priority.
+	Parent int
+	// Text is the full rendering.
+	Text string
+	// Short is an optional condensed rendering used when Text does not fit.
+	Short string
+	// Truncatable allows Text to be cut to fit the remaining budget.
+	Truncatable bool
+}
+
+// Choice is the rendering chosen for an item.
+type Choice struct {
+	Level  Level
+	Text   string
+	Tokens int
+}
+
+// Fit chooses, for each item, the fullest rendering that fits in max tokens,
+// visiting items in priority order. The returned choices are parallel to items.
+func Fit(items []Item, max int) []Choice {
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return items[order[a]].Priority > items[order[b]].Priority
+	})
+
+	choices := make([]Choice, len(items))
+	remaining := max
+	for _, i := range order {
+		it := items[i]
+		if it.Parent >= 0 && choices[it.Parent].Level < Truncated {
+			continue
+		}
+		var c Choice
+		if n := Estimate(it.Text); n <= remaining {
+			c = Choice{Level: Full, Text: it.Text, Tokens: n}
+		} else if it.Truncatable && remaining >= minTruncated {
+			text := Truncate(it.Text, remaining)
+			c = Choice{Level: Truncated, Text: text, Tokens: Estimate(text)}
+		} else if n := Estimate(it.Short); it.Short != "" && n <= remaining {
+			c = Choice{Level: Condensed, Text: it.Short, Tokens: n}
+		}
+		remaining -= c.Tokens
+		choices[i] = c
+	}
+	return choices
+}
+
+// Truncate cuts s to about tokens tokens, including TruncationMarker,
+// preferring to break at a line or word boundary.
+func Truncate(s string, tokens int) string {
+	limit := tokens*bytesPerToken - len(TruncationMarker)
+	if len(s) <= tokens*bytesPerToken {
+		return s
+	}
+	if limit <= 0 {
+		return ""
+	}
+	cut := s[:limit]
+	for !utf8.ValidString(cut) {
+		cut = cut[:len(cut)-1]
+	}
+	if i := strings.LastIndexByte(cut, '\n'); i > limit/2 {
+		cut = cut[:i]
+	} else if i := strings.LastIndexByte(cut, ' '); i > limit/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " \n") + TruncationMarker
+}
@@ -0,0 +1,86 @@
+package budget
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"abc", 1},
+		{"abcd", 1},
+		{"abcde", 2},
+	}
+	for _, tt := range tests {
+		if got := Estimate(tt.in); got != tt.want {
+			t.Errorf("Estimate(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	long := strings.Repeat("word ", 200) // ~250 tokens
+	items := []Item{
+		{Priority: 2.5, Parent: -1, Text: strings.Repeat("a", 800), Short: "short a"},
+		{Priority: 3, Parent: -1, Text: strings.Repeat("b", 200)},
+		{Priority: 2, Parent: 1, Text: long, Truncatable: true},
+		{Priority: 0.5, Parent: -1, Text: strings.Repeat("c", 400)},
+	}
+	choices := Fit(items, 200)
+
+	if choices[1].Level != Full {
+		t.Errorf("highest priority item = %v, want full", choices[1].Level)
+	}
+	if choices[2].Level != Truncated || !strings.HasSuffix(choices[2].Text, TruncationMarker) {
+		t.Errorf("truncatable child = %v %q, want truncated", choices[2].Level, choices[2].Text)
+	}
+	if choices[0].Level != Condensed || choices[0].Text != "short a" {
+		t.Errorf("item with short form = %v %q, want condensed", choices[0].Level, choices[0].Text)
+	}
+	if choices[3].Level != Omitted {
+		t.Errorf("lowest priority item = %v, want omitted", choices[3].Level)
+	}
+
+	total := 0
+	for _, c := range choices {
+		total += c.Tokens
+	}
+	if total > 200 {
+		t.Errorf("used %d tokens, budget 200", total)
+	}
+}
+
+func TestFitSkipsChildrenOfTrimmedParents(t *testing.T) {
+	items := []Item{
+		{Priority: 2, Parent: -1, Text: strings.Repeat("a", 400), Short: "a"},
+		{Priority: 1, Parent: 0, Text: "child"},
+	}
+	choices := Fit(items, 10)
+	if choices[0].Level != Condensed || choices[1].Level != Omitted {
+		t.Errorf("choices = %+v, want condensed parent and omitted child", choices)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	s := "first line\nsecond line with several words\nthird"
+	got := Truncate(s, 10)
+	if !strings.HasSuffix(got, TruncationMarker) {
+		t.Fatalf("Truncate = %q, missing marker", got)
+	}
+	if Estimate(got) > 10 {
+		t.Errorf("Truncate = %q (%d tokens), want at most 10", got, Estimate(got))
+	}
+	if !strings.HasPrefix(got, "first line") {
+		t.Errorf("Truncate = %q, want it to keep the start", got)
+	}
+	if got := Truncate("short", 10); got != "short" {
+		t.Errorf("Truncate of short text = %q", got)
+	}
+	if got := Truncate(strings.Repeat("é", 40), 10); !strings.HasSuffix(got, TruncationMarker) || strings.ContainsRune(got, '�') {
+		t.Errorf("Truncate split a rune: %q", got)
+	}
+}
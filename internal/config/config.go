@@ -0,0 +1,305 @@
+// Package config reads .sessions/config.yaml, which declares the artifact
+// types a store uses and the lifecycle of their statuses.
+package config
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the config file inside .sessions/.
+const FileName = "config.yaml"
+
+// DefaultYAML is the config used by stores without a config file, and the
+// one 'sessions init' writes.
+//
+//go:embed default.yaml
+var DefaultYAML []byte
+
+// Config is the parsed contents of config.yaml.
+type Config struct {
+	Statuses []Status `yaml:"statuses"`
+	// Transitions lists, for each status, the statuses an artifact may
+	// move to from it. A status without an entry may move to any status.
+	Transitions map[string][]string `yaml:"transitions"`
+	Types       []Type              `yaml:"types"`
+	Validation  Validation          `yaml:"validation"`
+}
+
+// Status is an artifact status.
+type Status struct {
+	Name string `yaml:"name"`
+	// Icon is the marker 'sessions status' shows for the status.
+	Icon string `yaml:"icon"`
+	// Stale statuses mark artifacts whose content should no longer be
+	// relied on.
+	Stale bool `yaml:"stale"`
+}
+
+// Type is an artifact type.
+type Type struct {
+	Name string `yaml:"name"`
+	// Statuses restricts the type to these statuses, in lifecycle order;
+	// empty means every status. New artifacts start in the first one.
+	Statuses []string `yaml:"statuses,omitempty"`
+	// Transitions replaces the top-level transitions for the type's
+	// statuses that it lists.
+	Transitions map[string][]string `yaml:"transitions,omitempty"`
+}
+
+// Validation configures 'sessions validate'.
+type Validation struct {
+	// TagPattern is a regular expression every tag must match; empty allows
+	// any tag.
+	TagPattern string `yaml:"tag_pattern"`
+	// Rules overrides the severity of rules by name: "error", "warning" or
+	// "off".
+	Rules map[string]string `yaml:"rules"`
+}
+
+// Severities are the values a rule's severity may be set to.
+var Severities = []string{"error", "warning", "off"}
+
+// Default returns the built-in config.
+func Default() *Config {
+	c, err := Parse(DefaultYAML)
+	if err != nil {
+		panic("config: invalid default config: " + err.Error())
+	}
+	return c
+}
+
+// Load reads the config file of the store at sessionsDir, falling back to
+// Default if there is none.
+func Load(sessionsDir string) (*Config, error) {
+	path := filepath.Join(sessionsDir, FileName)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Default(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", FileName, err)
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", FileName, err)
+	}
+	return c, nil
+}
+
+// Parse parses and checks a config file.
+func Parse(data []byte) (*Config, error) {
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// check reports names that are empty, declared twice or never declared.
+func (c *Config) check() error {
+	if len(c.Statuses) == 0 {
+		return errors.New("no statuses declared")
+	}
+	if len(c.Types) == 0 {
+		return errors.New("no types declared")
+	}
+	statuses := make(map[string]bool)
+	for _, s := range c.Statuses {
+		if s.Name == "" {
+			return errors.New("status with no name")
+		}
+		if statuses[s.Name] {
+			return fmt.Errorf("status %q declared twice", s.Name)
+		}
+		statuses[s.Name] = true
+	}
+	checkTransitions := func(where string, transitions map[string][]string) error {
+		for from, tos := range transitions {
+			for _, name := range append([]string{from}, tos...) {
+				if !statuses[name] {
+					return fmt.Errorf("%s: unknown status %q", where, name)
+				}
+			}
+		}
+		return nil
+	}
+	if err := checkTransitions("transitions", c.Transitions); err != nil {
+		return err
+	}
+	types := make(map[string]bool)
+	for _, t := range c.Types {
+		if t.Name == "" {
+			return errors.New("type with no name")
+		}
+		if types[t.Name] {
+			return fmt.Errorf("type %q declared twice", t.Name)
+		}
+		types[t.Name] = true
+		for _, name := range t.Statuses {
+			if !statuses[name] {
+				return fmt.Errorf("type %q: unknown status %q", t.Name, name)
+			}
+		}
+		if err := checkTransitions(fmt.Sprintf("type %q transitions", t.Name), t.Transitions); err != nil {
+			return err
+		}
+	}
+	if _, err := regexp.Compile(c.Validation.TagPattern); err != nil {
+		return fmt.Errorf("validation: invalid tag_pattern: %w", err)
+	}
+	for rule, severity := range c.Validation.Rules {
+		if !slices.Contains(Severities, severity) {
+			return fmt.Errorf("validation: rule %s: invalid severity %q (expected %s)", rule, severity, strings.Join(Severities, ", "))
+		}
+	}
+	return nil
+}
+
+// TagPattern returns the compiled tag pattern, or nil if tags are not
+// restricted.
+func (c *Config) TagPattern() *regexp.Regexp {
+	if c.Validation.TagPattern == "" {
+		return nil
+	}
+	return regexp.MustCompile(c.Validation.TagPattern)
+}
+
+// TypeNames returns the names of the declared types, in order.
+func (c *Config) TypeNames() []string {
+	names := make([]string, len(c.Types))
+	for i, t := range c.Types {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// StatusNames returns the names of the declared statuses, in order.
+func (c *Config) StatusNames() []string {
+	names := make([]string, len(c.Statuses))
+	for i, s := range c.Statuses {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// Type returns the type named name.
+func (c *Config) Type(name string) (*Type, bool) {
+	for i := range c.Types {
+		if c.Types[i].Name == name {
+			return &c.Types[i], true
+		}
+	}
+	return nil, false
+}
+
+// Status returns the status named name.
+func (c *Config) Status(name string) (*Status, bool) {
+	for i := range c.Statuses {
+		if c.Statuses[i].Name == name {
+			return &c.Statuses[i], true
+		}
+	}
+	return nil, false
+}
+
+// StatusesFor returns the statuses artifacts of type typ may have. Unknown
+// types may have any status.
+func (c *Config) StatusesFor(typ string) []string {
+	if t, ok := c.Type(typ); ok && len(t.Statuses) > 0 {
+		return t.Statuses
+	}
+	return c.StatusNames()
+}
+
+// InitialStatus returns the status new artifacts of type typ start in.
+func (c *Config) InitialStatus(typ string) string {
+	return c.StatusesFor(typ)[0]
+}
+
+// ReinstatedStatus returns the status an artifact of type typ goes back to
+// when whatever moved it to status from is undone, as when the artifact that
+// superseded it is removed: the first status of the type that it may move to
+// from there and that is not stale, or else its initial status.
+func (c *Config) ReinstatedStatus(typ, from string) string {
+	for _, name := range c.StatusesFor(typ) {
+		if name != from && !c.Stale(name) && c.CheckTransition(typ, from, name) == nil {
+			return name
+		}
+	}
+	return c.InitialStatus(typ)
+}
+
+// Icon returns the marker shown for status, or "?" for an unknown status
+// or one without an icon.
+func (c *Config) Icon(status string) string {
+	if s, ok := c.Status(status); ok && s.Icon != "" {
+		return s.Icon
+	}
+	return "?"
+}
+
+// Stale reports whether status marks an artifact as no longer current.
+func (c *Config) Stale(status string) bool {
+	s, ok := c.Status(status)
+	return ok && s.Stale
+}
+
+// CheckType checks that typ is a declared type.
+func (c *Config) CheckType(typ string) error {
+	if _, ok := c.Type(typ); !ok {
+		return fmt.Errorf("invalid type %q (expected %s)", typ, strings.Join(c.TypeNames(), ", "))
+	}
+	return nil
+}
+
+// CheckStatus checks that status is allowed for artifacts of type typ.
+func (c *Config) CheckStatus(typ, status string) error {
+	allowed := c.StatusesFor(typ)
+	if slices.Contains(allowed, status) {
+		return nil
+	}
+	if _, ok := c.Type(typ); ok && len(allowed) < len(c.Statuses) {
+		return fmt.Errorf("invalid status %q for type %s (expected %s)", status, typ, strings.Join(allowed, ", "))
+	}
+	return fmt.Errorf("invalid status %q (expected %s)", status, strings.Join(allowed, ", "))
+}
+
+// CheckTransition checks that an artifact of type typ may move from status
+// from to status to. Staying in the same status is always allowed, as is
+// leaving a status that is not declared.
+func (c *Config) CheckTransition(typ, from, to string) error {
+	if err := c.CheckStatus(typ, to); err != nil {
+		return err
+	}
+	if from == to {
+		return nil
+	}
+	if _, ok := c.Status(from); !ok {
+		return nil
+	}
+	allowed, ok := c.Transitions[from]
+	if t, found := c.Type(typ); found {
+		if tos, listed := t.Transitions[from]; listed {
+			allowed, ok = tos, true
+		}
+	}
+	if !ok || slices.Contains(allowed, to) {
+		return nil
+	}
+	if len(allowed) == 0 {
+		return fmt.Errorf("cannot change status from %s: it is final", from)
+	}
+	return fmt.Errorf("cannot change status from %s to %s (allowed: %s)", from, to, strings.Join(allowed, ", "))
+}
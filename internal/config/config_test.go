@@ -0,0 +1,101 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefault(t *testing.T) {
+	c := Default()
+	if got := strings.Join(c.TypeNames(), ","); got != "decision,analysis,investigation,architecture,debug-log" {
+		t.Errorf("types = %s", got)
+	}
+	if c.InitialStatus("decision") != "draft" || c.Icon("accepted") != "+" || c.Icon("done") != "?" {
+		t.Errorf("unexpected default statuses: %+v", c.Statuses)
+	}
+	if !c.Stale("superseded") || c.Stale("accepted") {
+		t.Error("wrong stale statuses")
+	}
+	if got := c.ReinstatedStatus("decision", "superseded"); got != "accepted" {
+		t.Errorf("ReinstatedStatus(superseded) = %s, want accepted", got)
+	}
+	if p := c.TagPattern(); p == nil || !p.MatchString("csv-loader") || p.MatchString("CSV Loader") {
+		t.Errorf("tag pattern = %v", p)
+	}
+	for _, tt := range []struct {
+		from, to string
+		ok       bool
+	}{
+		{"draft", "accepted", true},
+		{"accepted", "accepted", true},
+		{"accepted", "superseded", true},
+		{"accepted", "draft", false},
+		{"", "accepted", true},
+		{"unknown", "draft", true},
+		{"draft", "done", false},
+	} {
+		if err := c.CheckTransition("decision", tt.from, tt.to); (err == nil) != tt.ok {
+			t.Errorf("CheckTransition(%s -> %s) = %v, want ok %v", tt.from, tt.to, err, tt.ok)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Load(dir)
+	if err != nil || len(c.Types) != len(Default().Types) {
+		t.Fatalf("Load without a config file = %+v, %v", c, err)
+	}
+
+	custom := `
+statuses:
+  - name: open
+    icon: o
+  - name: closed
+    icon: c
+    stale: true
+transitions:
+  closed: []
+types:
+  - name: decision
+  - name: incident
+    statuses: [open, closed]
+    transitions:
+      closed: [open]
+`
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(custom), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err = Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CheckType("analysis"); err == nil {
+		t.Error("undeclared type accepted")
+	}
+	if c.InitialStatus("incident") != "open" {
+		t.Errorf("initial status = %s, want open", c.InitialStatus("incident"))
+	}
+	if err := c.CheckTransition("decision", "closed", "open"); err == nil || !strings.Contains(err.Error(), "final") {
+		t.Errorf("leaving a final status = %v", err)
+	}
+	if err := c.CheckTransition("incident", "closed", "open"); err != nil {
+		t.Errorf("per-type transition rejected: %v", err)
+	}
+
+	for _, bad := range []string{
+		"statuses: []\ntypes: [{name: a}]",
+		"statuses: [{name: a}]\ntypes: [{name: t, statuses: [b]}]",
+		"statuses: [{name: a}]\ntransitions: {a: [b]}\ntypes: [{name: t}]",
+		"statuses: [{name: a}, {name: a}]\ntypes: [{name: t}]",
+		"statuses: [",
+		"statuses: [{name: a}]\ntypes: [{name: t}]\nvalidation: {tag_pattern: '['}",
+		"statuses: [{name: a}]\ntypes: [{name: t}]\nvalidation: {rules: {tags: loud}}",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("Parse(%q) succeeded", bad)
+		}
+	}
+}
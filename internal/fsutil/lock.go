@@ -0,0 +1,13 @@
+package fsutil
+
+import "os"
+
+// FileLock is an exclusive lock held on a file.
+type FileLock struct {
+	f *os.File
+}
+
+// Unlock releases the lock.
+func (l *FileLock) Unlock() error {
+	return l.f.Close()
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package fsutil
+
+import "os"
+
+// Lock creates the file at path if needed. Advisory file locks are only
+// implemented on Unix; elsewhere Lock does not exclude other processes.
+func Lock(path string) (*FileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileLock{f: f}, nil
+}
@@ -0,0 +1,349 @@
+package index
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/glopal/sessions/internal/fsutil"
+	"github.com/glopal/sessions/internal/parser"
+	"github.com/glopal/sessions/internal/session"
+)
+
+// FileName is the name of the index file inside .sessions/.
+const FileName = "index.json"
+
+// ArchiveDir is the directory inside .sessions/ that archived sessions and
+// artifacts are moved to. It mirrors the layout of .sessions/ itself.
+const ArchiveDir = "archive"
+
+// version is bumped whenever the on-disk layout changes; a mismatch forces a full rescan.
+const version = 3
+
+// Entry caches the parsed contents of one session or artifact file, keyed by the
+// file's modification time and size at the moment it was parsed.
+type Entry struct {
+	ModTime  int64             `json:"mtime"`
+	Size     int64             `json:"size"`
+	Session  *session.Session  `json:"session,omitempty"`
+	Artifact *session.Artifact `json:"artifact,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// Index is a persistent cache of parsed session and artifact files.
+// Entries are keyed by slash-separated paths relative to the sessions directory.
+type Index struct {
+	Version int               `json:"version"`
+	Entries map[string]*Entry `json:"entries"`
+
+	dir   string
+	dirty bool
+}
+
+// Open reads the index for sessionsDir without checking it against the files on disk.
+// A missing, corrupt or outdated index file yields an empty index, so the next
+// Refresh falls back to a full scan.
+func Open(sessionsDir string) *Index {
+	ix := &Index{dir: sessionsDir}
+	data, err := os.ReadFile(filepath.Join(sessionsDir, FileName))
+	if err == nil && json.Unmarshal(data, ix) == nil && ix.Version == version && ix.Entries != nil {
+		return ix
+	}
+	return &Index{Version: version, Entries: map[string]*Entry{}, dir: sessionsDir, dirty: true}
+}
+
+// Load opens the index and brings it up to date with the files on disk,
+// saving it back if anything changed.
+func Load(sessionsDir string) (*Index, error) {
+	ix := Open(sessionsDir)
+	if err := ix.Refresh(); err != nil {
+		return nil, err
+	}
+	if err := ix.Save(); err != nil {
+		return ix, err
+	}
+	return ix, nil
+}
+
+// Rebuild discards any existing index and rescans every file.
+func Rebuild(sessionsDir string) (*Index, error) {
+	ix := &Index{Version: version, Entries: map[string]*Entry{}, dir: sessionsDir, dirty: true}
+	if err := ix.Refresh(); err != nil {
+		return nil, err
+	}
+	return ix, ix.Save()
+}
+
+// Dir returns the sessions directory the index belongs to.
+func (ix *Index) Dir() string {
+	return ix.dir
+}
+
+// Refresh walks the sessions and artifacts trees, and their copies under
+// archive/, re-parsing files whose mtime or size changed and dropping entries
+// whose files are gone.
+func (ix *Index) Refresh() error {
+	seen := make(map[string]bool)
+
+	if err := ix.refreshSessions("sessions", seen); err != nil {
+		return fmt.Errorf("reading sessions directory: %w", err)
+	}
+	if err := ix.refreshArtifacts("artifacts", seen); err != nil {
+		return fmt.Errorf("reading artifacts directory: %w", err)
+	}
+	if err := ix.refreshSessions(ArchiveDir+"/sessions", seen); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading archive directory: %w", err)
+	}
+	if err := ix.refreshArtifacts(ArchiveDir+"/artifacts", seen); err != nil {
+		return fmt.Errorf("reading archive directory: %w", err)
+	}
+
+	for rel := range ix.Entries {
+		if !seen[rel] {
+			delete(ix.Entries, rel)
+			ix.dirty = true
+		}
+	}
+	return nil
+}
+
+// refreshSessions refreshes the session files in the YYYY-MM directories of
+// dir, a slash-separated path relative to the sessions directory.
+func (ix *Index) refreshSessions(dir string, seen map[string]bool) error {
+	ymDirs, err := os.ReadDir(filepath.Join(ix.dir, filepath.FromSlash(dir)))
+	if err != nil {
+		return err
+	}
+	for _, ym := range ymDirs {
+		if !ym.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(ix.dir, filepath.FromSlash(dir), ym.Name()))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".md") {
+				continue
+			}
+			rel := dir + "/" + ym.Name() + "/" + e.Name()
+			seen[rel] = true
+			ix.refreshFile(rel)
+		}
+	}
+	return nil
+}
+
+// refreshArtifacts refreshes the artifact files below dir. A missing dir has
+// no artifacts.
+func (ix *Index) refreshArtifacts(dir string, seen map[string]bool) error {
+	root := filepath.Join(ix.dir, filepath.FromSlash(dir))
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".md") {
+			return nil
+		}
+		rel, err := filepath.Rel(ix.dir, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		seen[rel] = true
+		ix.refreshFile(rel)
+		return nil
+	})
+}
+
+// Update re-parses the given files unconditionally, removing entries for files
+// that no longer exist. Paths may be absolute or relative to the sessions directory.
+func (ix *Index) Update(paths ...string) {
+	for _, p := range paths {
+		rel, ok := ix.relPath(p)
+		if !ok {
+			continue
+		}
+		delete(ix.Entries, rel)
+		ix.dirty = true
+		ix.refreshFile(rel)
+	}
+}
+
+// Save writes the index to disk if it has changed since it was opened.
+func (ix *Index) Save() error {
+	if !ix.dirty {
+		return nil
+	}
+	data, err := json.Marshal(ix)
+	if err != nil {
+		return fmt.Errorf("encoding index: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(filepath.Join(ix.dir, FileName), data, 0644); err != nil {
+		return fmt.Errorf("writing index: %w", err)
+	}
+	ix.dirty = false
+	return nil
+}
+
+// Sessions returns all successfully parsed sessions that are not archived,
+// most recent first.
+func (ix *Index) Sessions() []*session.Session {
+	return ix.sessions(func(s *session.Session) bool { return !s.Archived })
+}
+
+// ArchivedSessions returns the successfully parsed archived sessions, most
+// recent first.
+func (ix *Index) ArchivedSessions() []*session.Session {
+	return ix.sessions(func(s *session.Session) bool { return s.Archived })
+}
+
+// AllSessions returns every successfully parsed session, archived or not,
+// most recent first.
+func (ix *Index) AllSessions() []*session.Session {
+	return ix.sessions(func(*session.Session) bool { return true })
+}
+
+func (ix *Index) sessions(keep func(*session.Session) bool) []*session.Session {
+	var sessions []*session.Session
+	for _, e := range ix.Entries {
+		if e.Session != nil && keep(e.Session) {
+			sessions = append(sessions, e.Session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return session.CompareSessionIDs(sessions[i].SessionID, sessions[j].SessionID) > 0
+	})
+	return sessions
+}
+
+// Artifacts returns every successfully parsed artifact, archived or not,
+// by key ("SESSION_ID/name.md").
+func (ix *Index) Artifacts() map[string]*session.Artifact {
+	artifacts := make(map[string]*session.Artifact)
+	for rel, e := range ix.Entries {
+		if e.Artifact == nil {
+			continue
+		}
+		parts := strings.Split(rel, "/")
+		if len(parts) < 3 {
+			continue
+		}
+		artifacts[session.FormatArtifactKey(parts[len(parts)-2], parts[len(parts)-1])] = e.Artifact
+	}
+	return artifacts
+}
+
+// Failures returns the session files that could not be parsed, in path order.
+func (ix *Index) Failures() []Failure {
+	var failures []Failure
+	for rel, e := range ix.Entries {
+		if e.Error != "" && strings.HasPrefix(rel, "sessions/") {
+			failures = append(failures, Failure{Path: rel, Err: e.Error})
+		}
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].Path < failures[j].Path
+	})
+	return failures
+}
+
+// Failure describes an indexed file that could not be parsed.
+type Failure struct {
+	Path string
+	Err  string
+}
+
+// Lookup returns the cached entry for path, which may be absolute or
+// relative to the sessions directory.
+func (ix *Index) Lookup(path string) (*Entry, bool) {
+	rel, ok := ix.relPath(path)
+	if !ok {
+		return nil, false
+	}
+	e, ok := ix.Entries[rel]
+	return e, ok
+}
+
+// File returns the entry for path after re-validating it against the file on
+// disk, re-parsing it if its mtime or size changed since it was indexed.
+func (ix *Index) File(path string) (*Entry, bool) {
+	rel, ok := ix.relPath(path)
+	if !ok {
+		return nil, false
+	}
+	ix.refreshFile(rel)
+	e, ok := ix.Entries[rel]
+	return e, ok
+}
+
+// Counts returns the number of indexed sessions and artifacts.
+func (ix *Index) Counts() (sessions, artifacts int) {
+	for _, e := range ix.Entries {
+		switch {
+		case e.Session != nil:
+			sessions++
+		case e.Artifact != nil:
+			artifacts++
+		}
+	}
+	return sessions, artifacts
+}
+
+// refreshFile re-parses rel if it is not cached or its mtime/size changed.
+func (ix *Index) refreshFile(rel string) {
+	full := filepath.Join(ix.dir, filepath.FromSlash(rel))
+	info, err := os.Stat(full)
+	if err != nil {
+		if _, ok := ix.Entries[rel]; ok {
+			delete(ix.Entries, rel)
+			ix.dirty = true
+		}
+		return
+	}
+
+	mtime := info.ModTime().UnixNano()
+	if e, ok := ix.Entries[rel]; ok && e.ModTime == mtime && e.Size == info.Size() {
+		return
+	}
+
+	e := &Entry{ModTime: mtime, Size: info.Size()}
+	archived := strings.HasPrefix(rel, ArchiveDir+"/")
+	if strings.HasPrefix(strings.TrimPrefix(rel, ArchiveDir+"/"), "sessions/") {
+		s, err := parser.ParseSessionFile(full)
+		if err != nil {
+			e.Error = err.Error()
+		} else {
+			s.Archived = archived
+			e.Session = s
+		}
+	} else {
+		a, err := parser.ParseArtifactFile(full)
+		if err != nil {
+			e.Error = err.Error()
+		} else {
+			e.Artifact = a
+		}
+	}
+	ix.Entries[rel] = e
+	ix.dirty = true
+}
+
+// relPath converts path to a slash-separated path relative to the sessions directory.
+func (ix *Index) relPath(path string) (string, bool) {
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(ix.dir, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return "", false
+		}
+		path = rel
+	}
+	return filepath.ToSlash(path), true
+}
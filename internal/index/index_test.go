@@ -0,0 +1,129 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glopal/sessions/internal/parser"
+	"github.com/glopal/sessions/internal/session"
+)
+
+func writeTestSession(t *testing.T, dir, id, summary string) string {
+	t.Helper()
+	path := session.ResolveSessionPath(dir, id)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s := &session.Session{SessionID: id, Summary: summary, Timestamp: time.Unix(1771953023, 0)}
+	if err := parser.WriteSessionFile(path, s); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadIncremental(t *testing.T) {
+	dir := t.TempDir()
+	writeTestSession(t, dir, "1771953023", "first")
+	path := writeTestSession(t, dir, "1771961662", "second")
+
+	ix, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := len(ix.Sessions()); got != 2 {
+		t.Fatalf("Sessions() returned %d, want 2", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, FileName)); err != nil {
+		t.Fatalf("index file not written: %v", err)
+	}
+
+	// A rewritten file with a different size must be re-parsed on the next load.
+	s := &session.Session{SessionID: "1771961662", Summary: "second, edited"}
+	if err := parser.WriteSessionFile(path, s); err != nil {
+		t.Fatal(err)
+	}
+	ix, err = Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := ix.Sessions()[0].Summary; got != "second, edited" {
+		t.Errorf("Summary = %q, want %q", got, "second, edited")
+	}
+
+	// Removed files drop out of the index.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	ix, err = Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := len(ix.Sessions()); got != 1 {
+		t.Errorf("Sessions() returned %d after removal, want 1", got)
+	}
+}
+
+func TestLoadCorruptIndex(t *testing.T) {
+	dir := t.TempDir()
+	writeTestSession(t, dir, "1771953023", "first")
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ix, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := len(ix.Sessions()); got != 1 {
+		t.Errorf("Sessions() returned %d, want 1", got)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestSession(t, dir, "1771953023", "first")
+	if _, err := Load(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &session.Session{SessionID: "1771953023", Summary: "fixed"}
+	if err := parser.WriteSessionFile(path, s); err != nil {
+		t.Fatal(err)
+	}
+	ix := Open(dir)
+	ix.Update(path)
+	if err := ix.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, FileName)); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("saved index: %v, %v; want mode 0644", info, err)
+	}
+
+	e, ok := Open(dir).Lookup("sessions/2026-02/1771953023.md")
+	if !ok || e.Session == nil {
+		t.Fatal("updated session missing from index")
+	}
+	if e.Session.Summary != "fixed" {
+		t.Errorf("Summary = %q, want %q", e.Session.Summary, "fixed")
+	}
+}
+
+func TestFailures(t *testing.T) {
+	dir := t.TempDir()
+	writeTestSession(t, dir, "1771953023", "first")
+	bad := filepath.Join(dir, "sessions", "2026-02", "1771953024.md")
+	if err := os.WriteFile(bad, []byte("no frontmatter"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ix, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failures := ix.Failures()
+	if len(failures) != 1 || failures[0].Path != "sessions/2026-02/1771953024.md" {
+		t.Errorf("Failures() = %+v, want one entry for the unparseable file", failures)
+	}
+}
@@ -0,0 +1,335 @@
+// Package mcp implements a minimal Model Context Protocol server over a
+// newline-delimited JSON-RPC 2.0 stream (the MCP stdio transport).
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ProtocolVersion is the MCP revision this server implements.
+const ProtocolVersion = "2025-06-18"
+
+// supportedVersions lists protocol revisions the server accepts from clients.
+var supportedVersions = map[string]bool{
+	"2024-11-05": true,
+	"2025-03-26": true,
+	"2025-06-18": true,
+}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+	codeNotFound       = -32002
+)
+
+// Tool is a callable tool exposed to the client.
+type Tool struct {
+	Name        string
+	Description string
+	// InputSchema is a JSON Schema object describing the tool's arguments.
+	InputSchema map[string]any
+	// Handler receives the raw arguments object and returns a JSON-encodable
+	// result. A returned error is reported to the client as a tool error
+	// rather than a protocol error, so the model can see and react to it.
+	Handler func(args json.RawMessage) (any, error)
+}
+
+// Resource describes a readable resource.
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceTemplate describes a parameterized family of resources.
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceContents is the text of a resource.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text"`
+}
+
+// ErrResourceNotFound is returned by a ResourceProvider for unknown URIs.
+var ErrResourceNotFound = errors.New("resource not found")
+
+// ResourceProvider lists and reads resources.
+type ResourceProvider interface {
+	Resources() ([]Resource, error)
+	Templates() []ResourceTemplate
+	Read(uri string) (*ResourceContents, error)
+}
+
+// Server dispatches MCP requests to registered tools and resources.
+// Requests are handled one at a time, in order.
+type Server struct {
+	Name         string
+	Version      string
+	Instructions string
+	Resources    ResourceProvider
+
+	tools []Tool
+	mu    sync.Mutex
+}
+
+// AddTool registers a tool. Tools are listed in registration order.
+func (s *Server) AddTool(t Tool) {
+	s.tools = append(s.tools, t)
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// Serve reads requests from r and writes responses to w until r is exhausted.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	enc := json.NewEncoder(w)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if resp := s.HandleMessage(line); resp != nil {
+			if err := enc.Encode(resp); err != nil {
+				return fmt.Errorf("writing response: %w", err)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("reading request: %w", err)
+	}
+	return nil
+}
+
+// HandleMessage handles one JSON-RPC message and returns the response to send,
+// or nil for notifications.
+func (s *Server) HandleMessage(msg []byte) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var req request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return &response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: "parse error: " + err.Error()}}
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		if len(req.ID) == 0 {
+			return nil
+		}
+		return &response{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: codeInvalidRequest, Message: "invalid JSON-RPC 2.0 request"}}
+	}
+
+	result, err := s.dispatch(req.Method, req.Params)
+	if len(req.ID) == 0 {
+		// Notifications never get a response.
+		return nil
+	}
+	resp := &response{JSONRPC: "2.0", ID: req.ID}
+	if err != nil {
+		var re *rpcError
+		if !errors.As(err, &re) {
+			re = &rpcError{Code: codeInternalError, Message: err.Error()}
+		}
+		resp.Error = re
+	} else {
+		resp.Result = result
+	}
+	return resp
+}
+
+func (s *Server) dispatch(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "initialize":
+		return s.initialize(params)
+	case "ping", "notifications/initialized", "notifications/cancelled":
+		return struct{}{}, nil
+	case "tools/list":
+		return s.listTools(), nil
+	case "tools/call":
+		return s.callTool(params)
+	case "resources/list":
+		return s.listResources()
+	case "resources/templates/list":
+		return s.listResourceTemplates(), nil
+	case "resources/read":
+		return s.readResource(params)
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + method}
+	}
+}
+
+func (s *Server) initialize(params json.RawMessage) (any, error) {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: "invalid initialize params: " + err.Error()}
+		}
+	}
+	version := ProtocolVersion
+	if supportedVersions[p.ProtocolVersion] {
+		version = p.ProtocolVersion
+	}
+
+	capabilities := map[string]any{"tools": map[string]any{}}
+	if s.Resources != nil {
+		capabilities["resources"] = map[string]any{}
+	}
+	result := map[string]any{
+		"protocolVersion": version,
+		"capabilities":    capabilities,
+		"serverInfo":      map[string]string{"name": s.Name, "version": s.Version},
+	}
+	if s.Instructions != "" {
+		result["instructions"] = s.Instructions
+	}
+	return result, nil
+}
+
+type toolDescriptor struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+func (s *Server) listTools() any {
+	tools := make([]toolDescriptor, 0, len(s.tools))
+	for _, t := range s.tools {
+		schema := t.InputSchema
+		if schema == nil {
+			schema = map[string]any{"type": "object"}
+		}
+		tools = append(tools, toolDescriptor{Name: t.Name, Description: t.Description, InputSchema: schema})
+	}
+	return map[string]any{"tools": tools}
+}
+
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type toolResult struct {
+	Content           []textContent `json:"content"`
+	StructuredContent any           `json:"structuredContent,omitempty"`
+	IsError           bool          `json:"isError"`
+}
+
+func (s *Server) callTool(params json.RawMessage) (any, error) {
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "invalid tools/call params: " + err.Error()}
+	}
+	var tool *Tool
+	for i := range s.tools {
+		if s.tools[i].Name == p.Name {
+			tool = &s.tools[i]
+			break
+		}
+	}
+	if tool == nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "unknown tool: " + p.Name}
+	}
+	args := p.Arguments
+	if len(args) == 0 || string(args) == "null" {
+		args = json.RawMessage("{}")
+	}
+
+	out, err := tool.Handler(args)
+	if err != nil {
+		return &toolResult{Content: []textContent{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+	}
+	text, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s result: %w", p.Name, err)
+	}
+	res := &toolResult{Content: []textContent{{Type: "text", Text: string(text)}}}
+	// Structured content must be a JSON object; wrap anything else.
+	var obj map[string]any
+	if json.Unmarshal(text, &obj) == nil {
+		res.StructuredContent = obj
+	} else {
+		res.StructuredContent = map[string]any{"result": out}
+	}
+	return res, nil
+}
+
+func (s *Server) listResources() (any, error) {
+	resources := []Resource{}
+	if s.Resources != nil {
+		list, err := s.Resources.Resources()
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, list...)
+	}
+	return map[string]any{"resources": resources}, nil
+}
+
+func (s *Server) listResourceTemplates() any {
+	templates := []ResourceTemplate{}
+	if s.Resources != nil {
+		templates = append(templates, s.Resources.Templates()...)
+	}
+	return map[string]any{"resourceTemplates": templates}
+}
+
+func (s *Server) readResource(params json.RawMessage) (any, error) {
+	var p struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.URI == "" {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "resources/read requires a uri"}
+	}
+	if s.Resources == nil {
+		return nil, &rpcError{Code: codeNotFound, Message: "resource not found: " + p.URI}
+	}
+	c, err := s.Resources.Read(p.URI)
+	if errors.Is(err, ErrResourceNotFound) {
+		return nil, &rpcError{Code: codeNotFound, Message: "resource not found: " + p.URI}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"contents": []*ResourceContents{c}}, nil
+}
@@ -0,0 +1,158 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type fakeResources struct{}
+
+func (fakeResources) Resources() ([]Resource, error) {
+	return []Resource{{URI: "test://a", Name: "a"}}, nil
+}
+
+func (fakeResources) Templates() []ResourceTemplate { return nil }
+
+func (fakeResources) Read(uri string) (*ResourceContents, error) {
+	if uri != "test://a" {
+		return nil, ErrResourceNotFound
+	}
+	return &ResourceContents{URI: uri, Text: "hello"}, nil
+}
+
+func newTestServer() *Server {
+	s := &Server{Name: "test", Version: "1", Resources: fakeResources{}}
+	s.AddTool(Tool{
+		Name: "echo",
+		Handler: func(args json.RawMessage) (any, error) {
+			var in struct {
+				Msg string `json:"msg"`
+			}
+			if err := json.Unmarshal(args, &in); err != nil {
+				return nil, err
+			}
+			if in.Msg == "" {
+				return nil, errors.New("msg is required")
+			}
+			return map[string]string{"echo": in.Msg}, nil
+		},
+	})
+	return s
+}
+
+// roundTrip sends one message and decodes the response into a generic map.
+func roundTrip(t *testing.T, s *Server, msg string) map[string]any {
+	t.Helper()
+	resp := s.HandleMessage([]byte(msg))
+	if resp == nil {
+		return nil
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestInitialize(t *testing.T) {
+	out := roundTrip(t, newTestServer(), `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`)
+	result := out["result"].(map[string]any)
+	if result["protocolVersion"] != "2024-11-05" {
+		t.Errorf("protocolVersion = %v, want client's supported version", result["protocolVersion"])
+	}
+	caps := result["capabilities"].(map[string]any)
+	if _, ok := caps["resources"]; !ok {
+		t.Error("expected resources capability")
+	}
+
+	out = roundTrip(t, newTestServer(), `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"1999-01-01"}}`)
+	if got := out["result"].(map[string]any)["protocolVersion"]; got != ProtocolVersion {
+		t.Errorf("protocolVersion = %v, want %s for unknown client version", got, ProtocolVersion)
+	}
+}
+
+func TestNotificationHasNoResponse(t *testing.T) {
+	if out := roundTrip(t, newTestServer(), `{"jsonrpc":"2.0","method":"notifications/initialized"}`); out != nil {
+		t.Errorf("notification got response %v", out)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		code float64
+	}{
+		{"parse error", `{not json`, codeParseError},
+		{"unknown method", `{"jsonrpc":"2.0","id":1,"method":"nope"}`, codeMethodNotFound},
+		{"unknown tool", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"nope"}}`, codeInvalidParams},
+		{"missing resource", `{"jsonrpc":"2.0","id":1,"method":"resources/read","params":{"uri":"test://b"}}`, codeNotFound},
+		{"wrong version", `{"jsonrpc":"1.0","id":1,"method":"ping"}`, codeInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := roundTrip(t, newTestServer(), tt.msg)
+			e, ok := out["error"].(map[string]any)
+			if !ok {
+				t.Fatalf("expected error response, got %v", out)
+			}
+			if e["code"] != tt.code {
+				t.Errorf("code = %v, want %v", e["code"], tt.code)
+			}
+		})
+	}
+}
+
+func TestToolsCall(t *testing.T) {
+	s := newTestServer()
+	out := roundTrip(t, s, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo","arguments":{"msg":"hi"}}}`)
+	result := out["result"].(map[string]any)
+	if result["isError"] != false {
+		t.Errorf("isError = %v, want false", result["isError"])
+	}
+	structured := result["structuredContent"].(map[string]any)
+	if structured["echo"] != "hi" {
+		t.Errorf("structuredContent = %v", structured)
+	}
+
+	// Handler errors are tool results, not protocol errors.
+	out = roundTrip(t, s, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{}}}`)
+	result = out["result"].(map[string]any)
+	if result["isError"] != true {
+		t.Errorf("isError = %v, want true", result["isError"])
+	}
+	content := result["content"].([]any)[0].(map[string]any)
+	if content["text"] != "msg is required" {
+		t.Errorf("content text = %v", content["text"])
+	}
+}
+
+func TestServe(t *testing.T) {
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":"r","method":"resources/read","params":{"uri":"test://a"}}`,
+	}, "\n")
+	var out bytes.Buffer
+	if err := newTestServer().Serve(strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d responses, want 3:\n%s", len(lines), out.String())
+	}
+	if !strings.Contains(lines[1], `"name":"echo"`) {
+		t.Errorf("tools/list response missing echo tool: %s", lines[1])
+	}
+	if !strings.Contains(lines[2], `"id":"r"`) || !strings.Contains(lines[2], `"text":"hello"`) {
+		t.Errorf("unexpected resources/read response: %s", lines[2])
+	}
+}
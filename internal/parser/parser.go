@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/glopal/sessions/internal/fsutil"
 	"github.com/glopal/sessions/internal/session"
 	"gopkg.in/yaml.v3"
 )
@@ -55,6 +56,19 @@ func ParseArtifact(content string) (*session.Artifact, error) {
 	return &a, nil
 }
 
+// ParseFrontmatter parses the YAML frontmatter of content into v and returns
+// the markdown body that follows it.
+func ParseFrontmatter(content string, v any) (string, error) {
+	fm, body, err := splitFrontmatter(content)
+	if err != nil {
+		return "", err
+	}
+	if err := yaml.Unmarshal([]byte(fm), v); err != nil {
+		return "", fmt.Errorf("parsing frontmatter YAML: %w", err)
+	}
+	return body, nil
+}
+
 // splitFrontmatter splits content into YAML frontmatter and markdown body.
 func splitFrontmatter(content string) (string, string, error) {
 	content = strings.TrimSpace(content)
@@ -98,14 +112,33 @@ func SerializeSessionFrontmatter(s *session.Session) (string, error) {
 	return string(data), nil
 }
 
-// WriteSessionFile writes a session to a file with frontmatter and body.
-func WriteSessionFile(path string, s *session.Session) error {
+// FormatSession renders a session as a file with frontmatter and body.
+func FormatSession(s *session.Session) ([]byte, error) {
 	fm, err := SerializeSessionFrontmatter(s)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("---\n%s---\n\n%s\n", fm, s.Body)), nil
+}
+
+// WriteSessionFile writes a session to a file with frontmatter and body. The
+// file is replaced atomically, so readers never see a partial write.
+func WriteSessionFile(path string, s *session.Session) error {
+	content, err := FormatSession(s)
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, content, 0644)
+}
+
+// CreateSessionFile writes a session to a new file, failing with an error
+// matching fs.ErrExist if the file already exists.
+func CreateSessionFile(path string, s *session.Session) error {
+	content, err := FormatSession(s)
 	if err != nil {
 		return err
 	}
-	content := fmt.Sprintf("---\n%s---\n\n%s\n", fm, s.Body)
-	return os.WriteFile(path, []byte(content), 0644)
+	return fsutil.CreateFile(path, content, 0644)
 }
 
 // SerializeArtifactFrontmatter serializes artifact frontmatter to YAML.
@@ -117,12 +150,13 @@ func SerializeArtifactFrontmatter(a *session.Artifact) (string, error) {
 	return string(data), nil
 }
 
-// WriteArtifactFile writes an artifact to a file with frontmatter and body.
+// WriteArtifactFile writes an artifact to a file with frontmatter and body,
+// replacing it atomically.
 func WriteArtifactFile(path string, a *session.Artifact) error {
 	fm, err := SerializeArtifactFrontmatter(a)
 	if err != nil {
 		return err
 	}
 	content := fmt.Sprintf("---\n%s---\n\n%s\n", fm, a.Body)
-	return os.WriteFile(path, []byte(content), 0644)
+	return fsutil.WriteFileAtomic(path, []byte(content), 0644)
 }
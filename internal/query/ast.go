@@ -0,0 +1,229 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glopal/sessions/internal/session"
+	"github.com/gobwas/glob"
+)
+
+// Node is a node in a parsed query expression.
+type Node interface {
+	// eval reports whether the subject matches, collecting what matched into h.
+	eval(sub *Subject, h *Hits) bool
+	String() string
+}
+
+// Subject is a session being evaluated, with lazy access to its artifacts.
+type Subject struct {
+	Session *session.Session
+	// Artifact loads an artifact referenced by the session. It may be nil, in
+	// which case status: terms never match.
+	Artifact func(ref session.ArtifactRef) *session.Artifact
+	// CurrentPath returns the name a changed file has now, if it was renamed
+	// since. It may be nil, in which case file: terms match recorded paths only.
+	CurrentPath func(path string) string
+}
+
+// Hits records which parts of a session satisfied the positive terms of an expression.
+type Hits struct {
+	Files     []string
+	Tags      []string
+	Artifacts []string
+}
+
+func (h *Hits) merge(o *Hits) {
+	h.Files = appendUnique(h.Files, o.Files...)
+	h.Tags = appendUnique(h.Tags, o.Tags...)
+	h.Artifacts = appendUnique(h.Artifacts, o.Artifacts...)
+}
+
+// Match evaluates the expression against a session and returns what matched.
+func Match(n Node, sub *Subject) (bool, *Hits) {
+	h := &Hits{}
+	if !n.eval(sub, h) {
+		return false, nil
+	}
+	return true, h
+}
+
+// And matches when all of its nodes match.
+type And struct {
+	Nodes []Node
+}
+
+func (a *And) eval(sub *Subject, h *Hits) bool {
+	local := &Hits{}
+	for _, n := range a.Nodes {
+		if !n.eval(sub, local) {
+			return false
+		}
+	}
+	h.merge(local)
+	return true
+}
+
+func (a *And) String() string {
+	return joinNodes(a.Nodes, " AND ")
+}
+
+// Or matches when any of its nodes match. Every branch is evaluated so that
+// hits from all matching branches are reported.
+type Or struct {
+	Nodes []Node
+}
+
+func (o *Or) eval(sub *Subject, h *Hits) bool {
+	matched := false
+	for _, n := range o.Nodes {
+		local := &Hits{}
+		if n.eval(sub, local) {
+			matched = true
+			h.merge(local)
+		}
+	}
+	return matched
+}
+
+func (o *Or) String() string {
+	return joinNodes(o.Nodes, " OR ")
+}
+
+// Not inverts its node. Hits inside a negation are discarded.
+type Not struct {
+	Node Node
+}
+
+func (n *Not) eval(sub *Subject, h *Hits) bool {
+	return !n.Node.eval(sub, &Hits{})
+}
+
+func (n *Not) String() string {
+	return "NOT " + n.Node.String()
+}
+
+// Term matches a single field against a value.
+type Term struct {
+	Field string
+	Value string
+
+	glob glob.Glob
+	date time.Time
+}
+
+func (t *Term) String() string {
+	return t.Field + ":" + strconv.Quote(t.Value)
+}
+
+func (t *Term) eval(sub *Subject, h *Hits) bool {
+	s := sub.Session
+	switch t.Field {
+	case "tag":
+		found := false
+		for _, tag := range s.Tags {
+			if t.matchPattern(tag) {
+				h.Tags = appendUnique(h.Tags, tag)
+				found = true
+			}
+		}
+		return found
+	case "file":
+		found := false
+		for _, f := range s.FilesChanged {
+			if t.matchPattern(f.Path) || sub.CurrentPath != nil && t.matchPattern(sub.CurrentPath(f.Path)) {
+				h.Files = appendUnique(h.Files, f.Path)
+				found = true
+			}
+		}
+		return found
+	case "artifact":
+		found := false
+		for _, a := range s.Artifacts {
+			if strings.EqualFold(a.Type, t.Value) {
+				h.Artifacts = appendUnique(h.Artifacts, a.Path)
+				found = true
+			}
+		}
+		return found
+	case "status":
+		if sub.Artifact == nil {
+			return false
+		}
+		found := false
+		for _, ref := range s.Artifacts {
+			if a := sub.Artifact(ref); a != nil && strings.EqualFold(a.Status, t.Value) {
+				h.Artifacts = appendUnique(h.Artifacts, ref.Path)
+				found = true
+			}
+		}
+		return found
+	case "after":
+		return !s.Timestamp.Before(t.date)
+	case "before":
+		return s.Timestamp.Before(t.date.AddDate(0, 0, 1))
+	case "date":
+		return !s.Timestamp.Before(t.date) && s.Timestamp.Before(t.date.AddDate(0, 0, 1))
+	case "commit":
+		return s.Git.HasCommit(t.Value)
+	case "branch":
+		return s.Git != nil && t.matchPattern(s.Git.Branch)
+	case "summary":
+		return containsFold(s.Summary, t.Value)
+	case "body":
+		return containsFold(s.Body, t.Value)
+	default: // text
+		if containsFold(s.Summary, t.Value) || containsFold(s.Body, t.Value) {
+			return true
+		}
+		for _, f := range s.FilesChanged {
+			if containsFold(f.Summary, t.Value) {
+				return true
+			}
+		}
+		for _, tag := range s.Tags {
+			if containsFold(tag, t.Value) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// matchPattern matches a tag, file path or branch against the term's glob, or
+// exactly when the value has no glob metacharacters.
+func (t *Term) matchPattern(s string) bool {
+	if t.glob != nil {
+		return t.glob.Match(s)
+	}
+	return s == t.Value
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func joinNodes(nodes []Node, sep string) string {
+	parts := make([]string, len(nodes))
+	for i, n := range nodes {
+		parts[i] = n.String()
+	}
+	return "(" + strings.Join(parts, sep) + ")"
+}
+
+func appendUnique(list []string, items ...string) []string {
+	for _, item := range items {
+		found := false
+		for _, existing := range list {
+			if existing == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, item)
+		}
+	}
+	return list
+}
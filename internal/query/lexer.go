@@ -0,0 +1,152 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+	tokTerm
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokEOF:
+		return "end of expression"
+	case tokLParen:
+		return "'('"
+	case tokRParen:
+		return "')'"
+	case tokAnd:
+		return "AND"
+	case tokOr:
+		return "OR"
+	case tokNot:
+		return "NOT"
+	default:
+		return "term"
+	}
+}
+
+// token is a lexical token. pos is the 0-based byte offset in the input.
+type token struct {
+	kind     tokenKind
+	pos      int
+	field    string
+	value    string
+	valuePos int
+	quoted   bool
+}
+
+// lex splits an expression into tokens.
+func lex(input string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(input) {
+		c := rune(input[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			toks = append(toks, token{kind: tokLParen, pos: i})
+			i++
+		case c == ')':
+			toks = append(toks, token{kind: tokRParen, pos: i})
+			i++
+		case c == '"':
+			value, next, err := lexQuoted(input, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{kind: tokTerm, pos: i, value: value, valuePos: i, quoted: true})
+			i = next
+		default:
+			tok, next, err := lexWord(input, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, tok)
+			i = next
+		}
+	}
+	toks = append(toks, token{kind: tokEOF, pos: len(input)})
+	return toks, nil
+}
+
+// lexWord reads a bare word, an operator keyword, or a field:value term.
+func lexWord(input string, start int) (token, int, error) {
+	i := start
+	for i < len(input) && !isDelim(input[i]) && input[i] != ':' {
+		i++
+	}
+	word := input[start:i]
+
+	if i < len(input) && input[i] == ':' {
+		field := strings.ToLower(word)
+		if field == "" {
+			return token{}, 0, errorf(input, start, "missing field name before ':'")
+		}
+		i++
+		valueStart := i
+		if i < len(input) && input[i] == '"' {
+			value, next, err := lexQuoted(input, i)
+			if err != nil {
+				return token{}, 0, err
+			}
+			return token{kind: tokTerm, pos: start, field: field, value: value, valuePos: valueStart, quoted: true}, next, nil
+		}
+		for i < len(input) && !isDelim(input[i]) {
+			i++
+		}
+		if i == valueStart {
+			return token{}, 0, errorf(input, valueStart, "missing value for field %q", field)
+		}
+		return token{kind: tokTerm, pos: start, field: field, value: input[valueStart:i], valuePos: valueStart}, i, nil
+	}
+
+	switch strings.ToUpper(word) {
+	case "AND":
+		return token{kind: tokAnd, pos: start}, i, nil
+	case "OR":
+		return token{kind: tokOr, pos: start}, i, nil
+	case "NOT":
+		return token{kind: tokNot, pos: start}, i, nil
+	}
+	return token{kind: tokTerm, pos: start, value: word, valuePos: start}, i, nil
+}
+
+// lexQuoted reads a double-quoted string starting at input[start] == '"'.
+// Backslash escapes the next character.
+func lexQuoted(input string, start int) (string, int, error) {
+	var b strings.Builder
+	i := start + 1
+	for i < len(input) {
+		switch input[i] {
+		case '\\':
+			if i+1 < len(input) {
+				b.WriteByte(input[i+1])
+				i += 2
+				continue
+			}
+			i++
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(input[i])
+			i++
+		}
+	}
+	return "", 0, errorf(input, start, "unterminated quoted string")
+}
+
+func isDelim(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '(' || c == ')' || c == '"'
+}
@@ -0,0 +1,217 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gobwas/glob"
+)
+
+// Error is a parse error pointing at a position in the expression.
+type Error struct {
+	Input  string
+	Column int // 1-based
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Msg)
+}
+
+// Caret renders the expression with a caret under the offending column.
+func (e *Error) Caret() string {
+	return "  " + e.Input + "\n  " + strings.Repeat(" ", e.Column-1) + "^"
+}
+
+func errorf(input string, pos int, format string, args ...any) *Error {
+	return &Error{Input: input, Column: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+// fields lists the supported field names and what they match.
+var fields = map[string]string{
+	"tag":      "session tag (glob)",
+	"file":     "changed file path (glob)",
+	"artifact": "artifact type",
+	"status":   "artifact status",
+	"after":    "sessions on or after a date (YYYY-MM-DD)",
+	"before":   "sessions on or before a date (YYYY-MM-DD)",
+	"date":     "sessions on a date (YYYY-MM-DD)",
+	"summary":  "text in the session summary",
+	"body":     "text in the session body",
+	"commit":   "commit hash recorded on the session (4+ characters)",
+	"branch":   "git branch the session was recorded on (glob)",
+	"text":     "text in the summary, body, file summaries or tags",
+}
+
+// FieldNames returns the supported field names in sorted order.
+func FieldNames() []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse parses a boolean query expression.
+//
+//	expr    := or
+//	or      := and ("OR" and)*
+//	and     := unary (["AND"] unary)*
+//	unary   := "NOT" unary | primary
+//	primary := "(" expr ")" | [field ":"] value
+//
+// Adjacent terms without an operator are ANDed. Operators are case-insensitive;
+// quote a value to search for a literal "and", "or" or "not".
+func Parse(input string) (Node, error) {
+	toks, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{input: input, toks: toks}
+	if p.peek().kind == tokEOF {
+		return nil, errorf(input, 0, "empty expression")
+	}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		if t.kind == tokRParen {
+			return nil, errorf(input, t.pos, "unexpected ')' without matching '('")
+		}
+		return nil, errorf(input, t.pos, "unexpected %s", t.kind)
+	}
+	return n, nil
+}
+
+type parser struct {
+	input string
+	toks  []token
+	pos   int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []Node{left}
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+	if len(nodes) == 1 {
+		return left, nil
+	}
+	return &Or{Nodes: nodes}, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []Node{left}
+	for {
+		switch p.peek().kind {
+		case tokAnd:
+			p.next()
+		case tokTerm, tokNot, tokLParen:
+			// implicit AND
+		default:
+			if len(nodes) == 1 {
+				return left, nil
+			}
+			return &And{Nodes: nodes}, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.peek().kind == tokNot {
+		p.next()
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Node: n}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		if p.peek().kind == tokRParen {
+			return nil, errorf(p.input, p.peek().pos, "empty parentheses")
+		}
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, errorf(p.input, closing.pos, "expected ')' to close '(' at column %d, found %s", t.pos+1, closing.kind)
+		}
+		return n, nil
+	case tokTerm:
+		return p.buildTerm(t)
+	case tokEOF:
+		return nil, errorf(p.input, t.pos, "expected a term, found end of expression")
+	default:
+		return nil, errorf(p.input, t.pos, "expected a term, found %s", t.kind)
+	}
+}
+
+func (p *parser) buildTerm(t token) (Node, error) {
+	field := t.field
+	if field == "" {
+		field = "text"
+	}
+	if _, ok := fields[field]; !ok {
+		return nil, errorf(p.input, t.pos, "unknown field %q (expected one of: %s)", t.field, strings.Join(FieldNames(), ", "))
+	}
+
+	term := &Term{Field: field, Value: t.value}
+	switch field {
+	case "tag", "file", "branch":
+		if strings.ContainsAny(t.value, "*?[{") {
+			g, err := glob.Compile(t.value, '/')
+			if err != nil {
+				return nil, errorf(p.input, t.valuePos, "invalid pattern %q: %v", t.value, err)
+			}
+			term.glob = g
+		}
+	case "after", "before", "date":
+		d, err := time.Parse("2006-01-02", t.value)
+		if err != nil {
+			return nil, errorf(p.input, t.valuePos, "invalid date %q (expected YYYY-MM-DD)", t.value)
+		}
+		term.date = d
+	}
+	return term, nil
+}
@@ -0,0 +1,144 @@
+package query
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/glopal/sessions/internal/session"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"tag:auth", `tag:"auth"`},
+		{"auth bug", `(text:"auth" AND text:"bug")`},
+		{"tag:a OR tag:b tag:c", `(tag:"a" OR (tag:"b" AND tag:"c"))`},
+		{"tag:a and (file:x or file:y)", `(tag:"a" AND (file:"x" OR file:"y"))`},
+		{"NOT NOT tag:a", `NOT NOT tag:"a"`},
+		{`summary:"token refresh"`, `summary:"token refresh"`},
+		{`"and"`, `text:"and"`},
+		{"FILE:cmd/*.go", `file:"cmd/*.go"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			n, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.input, err)
+			}
+			if got := n.String(); got != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		column int
+	}{
+		{"", 1},
+		{"tag:a AND", 10},
+		{"(tag:a", 7},
+		{"tag:a)", 6},
+		{"tagz:a", 1},
+		{"tag:", 5},
+		{"after:yesterday", 7},
+		{`summary:"open`, 9},
+		{"tag:a OR OR tag:b", 10},
+		{"()", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var qe *Error
+			if !errors.As(err, &qe) {
+				t.Fatalf("Parse(%q) error = %v, want *Error", tt.input, err)
+			}
+			if qe.Column != tt.column {
+				t.Errorf("Parse(%q) column = %d, want %d (%v)", tt.input, qe.Column, tt.column, qe)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	s := &session.Session{
+		SessionID: "1771953023",
+		Timestamp: time.Date(2026, 2, 24, 12, 0, 0, 0, time.UTC),
+		Summary:   "Refactor token refresh",
+		Tags:      []string{"auth", "refactor"},
+		FilesChanged: []session.FileChange{
+			{Path: "internal/auth/token.go", Action: "modified", Summary: "Retry on expiry"},
+			{Path: "cmd/login.go", Action: "modified"},
+		},
+		Artifacts: []session.ArtifactRef{{Path: "adr.md", Type: "decision"}},
+		Body:      "## Key Decisions\n\n- Use a refresh lock.",
+		Git:       &session.GitInfo{Commit: "4f2a9c1e", Branch: "feature/auth", Commits: []string{"9b3d0e7a"}},
+	}
+	statuses := map[string]string{"adr.md": "accepted"}
+	sub := &Subject{
+		Session: s,
+		Artifact: func(ref session.ArtifactRef) *session.Artifact {
+			return &session.Artifact{Status: statuses[ref.Path]}
+		},
+	}
+
+	tests := []struct {
+		input string
+		want  bool
+		files []string
+	}{
+		{"tag:auth AND (file:internal/** OR artifact:decision) AND NOT tag:spike", true, []string{"internal/auth/token.go"}},
+		{"tag:auth AND tag:spike", false, nil},
+		{"tag:auth OR tag:spike", true, nil},
+		{"tag:re*", true, nil},
+		{"file:cmd/*.go", true, []string{"cmd/login.go"}},
+		{"file:*.go", false, nil},
+		{"NOT file:cmd/login.go", false, nil},
+		{"status:accepted", true, nil},
+		{"status:draft", false, nil},
+		{"after:2026-02-24 before:2026-02-24", true, nil},
+		{"date:2026-02-25", false, nil},
+		{"summary:TOKEN", true, nil},
+		{"body:lock", true, nil},
+		{"commit:4f2a9c", true, nil},
+		{"commit:9B3D", true, nil},
+		{"commit:4f2", false, nil},
+		{"branch:feature/*", true, nil},
+		{"branch:main", false, nil},
+		{"expiry", true, nil},
+		{`"refresh lock"`, true, nil},
+		{"NOT (tag:auth AND file:x.go) AND file:cmd/login.go", true, []string{"cmd/login.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			n, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.input, err)
+			}
+			got, hits := Match(n, sub)
+			if got != tt.want {
+				t.Fatalf("Match(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+			if tt.files != nil && !equal(hits.Files, tt.files) {
+				t.Errorf("Match(%q) files = %v, want %v", tt.input, hits.Files, tt.files)
+			}
+		})
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
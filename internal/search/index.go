@@ -0,0 +1,283 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// BM25 parameters.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Field is one searchable section of a document. Weight scales the field's
+// term frequencies and length, so a hit in a summary counts for more than one
+// buried in a long body.
+type Field struct {
+	Name   string
+	Text   string
+	Weight float64
+}
+
+// Document is a unit of search results: a session or an artifact.
+type Document struct {
+	Key       string
+	SessionID string
+	Title     string
+	Fields    []Field
+}
+
+// Hit is a scored search result.
+type Hit struct {
+	Key       string
+	SessionID string
+	Title     string
+	Score     float64
+	Field     string
+	Snippet   string
+}
+
+type posting struct {
+	doc       int
+	field     int
+	positions []int
+}
+
+type docEntry struct {
+	doc    Document
+	tokens [][]token
+	length float64
+}
+
+// Index is an in-memory inverted index over documents.
+type Index struct {
+	docs     []*docEntry
+	postings map[string][]posting
+	totalLen float64
+}
+
+// New returns an empty index.
+func New() *Index {
+	return &Index{postings: make(map[string][]posting)}
+}
+
+// Len returns the number of indexed documents.
+func (ix *Index) Len() int {
+	return len(ix.docs)
+}
+
+// Add tokenizes and indexes a document.
+func (ix *Index) Add(d Document) {
+	id := len(ix.docs)
+	e := &docEntry{doc: d, tokens: make([][]token, len(d.Fields))}
+	for fi, f := range d.Fields {
+		toks := tokenize(f.Text)
+		e.tokens[fi] = toks
+		e.length += f.Weight * float64(len(toks))
+
+		positions := make(map[string][]int)
+		var order []string
+		for _, t := range toks {
+			if _, ok := positions[t.term]; !ok {
+				order = append(order, t.term)
+			}
+			positions[t.term] = append(positions[t.term], t.pos)
+		}
+		for _, term := range order {
+			ix.postings[term] = append(ix.postings[term], posting{doc: id, field: fi, positions: positions[term]})
+		}
+	}
+	ix.docs = append(ix.docs, e)
+	ix.totalLen += e.length
+}
+
+// clause is one required part of a query: a term, a prefix or a phrase.
+type clause struct {
+	terms  []string
+	prefix bool
+}
+
+// parseQuery splits a query into word, prefix ("auth*") and phrase clauses.
+func parseQuery(q string) ([]clause, error) {
+	var clauses []clause
+	rest := q
+	for {
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			break
+		}
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated phrase in search %q", q)
+			}
+			var terms []string
+			for _, t := range tokenize(rest[1 : end+1]) {
+				terms = append(terms, t.term)
+			}
+			if len(terms) > 0 {
+				clauses = append(clauses, clause{terms: terms})
+			}
+			rest = rest[end+2:]
+			continue
+		}
+		word := rest
+		if i := strings.IndexAny(rest, " \t\n\""); i >= 0 {
+			word, rest = rest[:i], rest[i:]
+		} else {
+			rest = ""
+		}
+		if strings.HasSuffix(word, "*") {
+			toks := tokenize(strings.TrimSuffix(word, "*"))
+			if len(toks) == 1 {
+				prefix := strings.ToLower(word[toks[0].start:toks[0].end])
+				clauses = append(clauses, clause{terms: []string{prefix}, prefix: true})
+				continue
+			}
+		}
+		toks := tokenize(word)
+		switch len(toks) {
+		case 0:
+		case 1:
+			clauses = append(clauses, clause{terms: []string{toks[0].term}})
+		default:
+			// "parse_key" or "cmd/new.go" behave like phrases.
+			var terms []string
+			for _, t := range toks {
+				terms = append(terms, t.term)
+			}
+			clauses = append(clauses, clause{terms: terms})
+		}
+	}
+	if len(clauses) == 0 {
+		return nil, fmt.Errorf("search %q contains no searchable words", q)
+	}
+	return clauses, nil
+}
+
+// occurrence is a match of a clause within one field of a document.
+type occurrence struct {
+	field     int
+	positions []int // start position of each match
+	width     int   // number of tokens per match
+}
+
+// match returns every occurrence of the clause, grouped by document.
+func (ix *Index) match(c clause) map[int][]occurrence {
+	out := make(map[int][]occurrence)
+	switch {
+	case c.prefix:
+		for term, postings := range ix.postings {
+			if !strings.HasPrefix(term, c.terms[0]) {
+				continue
+			}
+			for _, p := range postings {
+				out[p.doc] = append(out[p.doc], occurrence{field: p.field, positions: p.positions, width: 1})
+			}
+		}
+	case len(c.terms) == 1:
+		for _, p := range ix.postings[c.terms[0]] {
+			out[p.doc] = append(out[p.doc], occurrence{field: p.field, positions: p.positions, width: 1})
+		}
+	default:
+		for _, p := range ix.postings[c.terms[0]] {
+			var starts []int
+			for _, start := range p.positions {
+				if ix.phraseAt(p.doc, p.field, start, c.terms) {
+					starts = append(starts, start)
+				}
+			}
+			if len(starts) > 0 {
+				out[p.doc] = append(out[p.doc], occurrence{field: p.field, positions: starts, width: len(c.terms)})
+			}
+		}
+	}
+	return out
+}
+
+// phraseAt reports whether terms appear consecutively from position start.
+func (ix *Index) phraseAt(doc, field, start int, terms []string) bool {
+	toks := ix.docs[doc].tokens[field]
+	if start+len(terms) > len(toks) {
+		return false
+	}
+	for i, term := range terms {
+		if toks[start+i].term != term {
+			return false
+		}
+	}
+	return true
+}
+
+// Search returns documents matching every clause of q, best first.
+// Words are stemmed, "quoted phrases" must appear in order, and a trailing
+// asterisk matches any term with that prefix.
+func (ix *Index) Search(q string) ([]Hit, error) {
+	clauses, err := parseQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	if len(ix.docs) == 0 {
+		return nil, nil
+	}
+
+	n := float64(len(ix.docs))
+	avgLen := ix.totalLen / n
+	if avgLen == 0 {
+		avgLen = 1
+	}
+
+	scores := make(map[int]float64)
+	occs := make(map[int][]occurrence)
+	for i, c := range clauses {
+		matches := ix.match(c)
+		df := float64(len(matches))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for doc, docOccs := range matches {
+			if i > 0 {
+				if _, ok := scores[doc]; !ok {
+					continue
+				}
+			}
+			e := ix.docs[doc]
+			tf := 0.0
+			for _, o := range docOccs {
+				tf += e.doc.Fields[o.field].Weight * float64(len(o.positions))
+			}
+			norm := tf + k1*(1-b+b*e.length/avgLen)
+			scores[doc] += idf * tf * (k1 + 1) / norm
+			occs[doc] = append(occs[doc], docOccs...)
+		}
+		// Drop documents that did not match this clause.
+		for doc := range scores {
+			if _, ok := matches[doc]; !ok {
+				delete(scores, doc)
+				delete(occs, doc)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for doc, score := range scores {
+		e := ix.docs[doc]
+		field, snippet := ix.snippet(doc, occs[doc])
+		hits = append(hits, Hit{
+			Key:       e.doc.Key,
+			SessionID: e.doc.SessionID,
+			Title:     e.doc.Title,
+			Score:     score,
+			Field:     field,
+			Snippet:   snippet,
+		})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Key > hits[j].Key
+	})
+	return hits, nil
+}
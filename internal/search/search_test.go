@@ -0,0 +1,146 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		word, want string
+	}{
+		{"caresses", "caress"},
+		{"ponies", "poni"},
+		{"sessions", "session"},
+		{"parsing", "pars"},
+		{"parsed", "pars"},
+		{"hopping", "hop"},
+		{"filing", "file"},
+		{"relational", "relat"},
+		{"happy", "happi"},
+		{"go", "go"},
+		{"ids", "ids"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			if got := stem(tt.word); got != tt.want {
+				t.Errorf("stem(%q) = %q, want %q", tt.word, got, tt.want)
+			}
+		})
+	}
+}
+
+func testIndex() *Index {
+	ix := New()
+	ix.Add(Document{Key: "1", SessionID: "1", Fields: []Field{
+		{Name: "summary", Text: "Refactor token refresh handling", Weight: 2},
+		{Name: "body", Text: "We now retry the refresh when the token expires.", Weight: 1},
+	}})
+	ix.Add(Document{Key: "2", SessionID: "2", Fields: []Field{
+		{Name: "summary", Text: "Add CSV loader", Weight: 2},
+		{Name: "body", Text: "The loader parses nullable columns. Token handling is unchanged.", Weight: 1},
+	}})
+	ix.Add(Document{Key: "2/notes.md", SessionID: "2", Fields: []Field{
+		{Name: "title", Text: "Loader notes", Weight: 3},
+		{Name: "body", Text: "Parsing strategy for nullable columns.", Weight: 1},
+	}})
+	return ix
+}
+
+func TestSearchRanking(t *testing.T) {
+	hits, err := testIndex().Search("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("got %d hits, want 2: %+v", len(hits), hits)
+	}
+	if hits[0].Key != "1" {
+		t.Errorf("top hit = %s, want 1 (token in summary and body)", hits[0].Key)
+	}
+	if hits[0].Score <= hits[1].Score {
+		t.Errorf("scores not descending: %v, %v", hits[0].Score, hits[1].Score)
+	}
+}
+
+func TestSearchStemming(t *testing.T) {
+	hits, err := testIndex().Search("parsed column")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("got %d hits, want 2: %+v", len(hits), hits)
+	}
+}
+
+func TestSearchPhrase(t *testing.T) {
+	ix := testIndex()
+	hits, err := ix.Search(`"token refresh"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].Key != "1" {
+		t.Fatalf("phrase hits = %+v, want only 1", hits)
+	}
+	if !strings.Contains(hits[0].Snippet, "**token refresh**") {
+		t.Errorf("snippet %q does not highlight the phrase", hits[0].Snippet)
+	}
+
+	hits, err = ix.Search(`"refresh token"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 0 {
+		t.Errorf("reversed phrase matched: %+v", hits)
+	}
+}
+
+func TestSearchPrefix(t *testing.T) {
+	hits, err := testIndex().Search("load*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("got %d hits, want 2: %+v", len(hits), hits)
+	}
+}
+
+func TestSearchAllClausesRequired(t *testing.T) {
+	hits, err := testIndex().Search("token csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].Key != "2" {
+		t.Errorf("hits = %+v, want only 2", hits)
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	for _, q := range []string{"", "  ", `"unterminated`, "!!"} {
+		if _, err := testIndex().Search(q); err == nil {
+			t.Errorf("Search(%q) succeeded, want error", q)
+		}
+	}
+}
+
+func TestSnippetWindow(t *testing.T) {
+	ix := New()
+	words := make([]string, 100)
+	for i := range words {
+		words[i] = "filler"
+	}
+	words[50] = "needle"
+	ix.Add(Document{Key: "1", Fields: []Field{{Name: "body", Text: strings.Join(words, " "), Weight: 1}}})
+
+	hits, err := ix.Search("needle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := hits[0].Snippet
+	if !strings.HasPrefix(s, "...") || !strings.HasSuffix(s, "...") {
+		t.Errorf("snippet %q should be elided on both sides", s)
+	}
+	if !strings.Contains(s, "**needle**") {
+		t.Errorf("snippet %q does not highlight the match", s)
+	}
+}
@@ -0,0 +1,103 @@
+package search
+
+import (
+	"sort"
+	"strings"
+)
+
+// Snippet settings: the window is measured in tokens around the first match.
+const (
+	snippetBefore = 8
+	snippetTokens = 32
+)
+
+// Highlight markers wrapped around matched words in snippets.
+const (
+	HighlightStart = "**"
+	HighlightEnd   = "**"
+)
+
+// snippet picks the field with the most weighted matches and returns its name
+// and an excerpt around the first match, with matched words highlighted.
+func (ix *Index) snippet(doc int, occs []occurrence) (string, string) {
+	e := ix.docs[doc]
+	if len(occs) == 0 {
+		return "", ""
+	}
+
+	weights := make(map[int]float64)
+	for _, o := range occs {
+		weights[o.field] += e.doc.Fields[o.field].Weight * float64(len(o.positions))
+	}
+	best := occs[0].field
+	for f, w := range weights {
+		if w > weights[best] || (w == weights[best] && f < best) {
+			best = f
+		}
+	}
+
+	// Token positions to highlight in the chosen field.
+	marked := make(map[int]bool)
+	first := -1
+	for _, o := range occs {
+		if o.field != best {
+			continue
+		}
+		for _, p := range o.positions {
+			for i := 0; i < o.width; i++ {
+				marked[p+i] = true
+			}
+			if first < 0 || p < first {
+				first = p
+			}
+		}
+	}
+
+	toks := e.tokens[best]
+	text := e.doc.Fields[best].Text
+	lo := max(first-snippetBefore, 0)
+	hi := min(lo+snippetTokens, len(toks))
+
+	positions := make([]int, 0, len(marked))
+	for p := range marked {
+		if p >= lo && p < hi {
+			positions = append(positions, p)
+		}
+	}
+	sort.Ints(positions)
+
+	start := toks[lo].start
+	if lo == 0 {
+		start = 0
+	}
+	end := toks[hi-1].end
+	if hi == len(toks) {
+		end = len(text)
+	}
+
+	var sb strings.Builder
+	if lo > 0 {
+		sb.WriteString("...")
+	}
+	cur := start
+	for i := 0; i < len(positions); i++ {
+		// Merge consecutive highlighted tokens (phrases) into one span.
+		j := i
+		for j+1 < len(positions) && positions[j+1] == positions[j]+1 {
+			j++
+		}
+		spanStart, spanEnd := toks[positions[i]].start, toks[positions[j]].end
+		sb.WriteString(text[cur:spanStart])
+		sb.WriteString(HighlightStart)
+		sb.WriteString(text[spanStart:spanEnd])
+		sb.WriteString(HighlightEnd)
+		cur = spanEnd
+		i = j
+	}
+	sb.WriteString(text[cur:end])
+	if hi < len(toks) {
+		sb.WriteString("...")
+	}
+
+	return e.doc.Fields[best].Name, strings.Join(strings.Fields(sb.String()), " ")
+}
@@ -0,0 +1,190 @@
+package search
+
+import "strings"
+
+// stem reduces an English word to an approximate root using the first steps of
+// the Porter algorithm (plurals, -ed/-ing, -y), its most common derivational
+// suffixes, and final -e/-ll cleanup. It is deliberately conservative:
+// over-stemming hurts precision more than under-stemming hurts recall for
+// short technical notes.
+func stem(w string) string {
+	if len(w) <= 3 || !isASCIILetters(w) {
+		return w
+	}
+	w = step1a(w)
+	w = step1b(w)
+	w = step1c(w)
+	w = step2(w)
+	w = step5(w)
+	return w
+}
+
+func isASCIILetters(w string) bool {
+	for i := 0; i < len(w); i++ {
+		if w[i] < 'a' || w[i] > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isConsonant(w string, i int) bool {
+	switch w[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !isConsonant(w, i-1)
+	}
+	return true
+}
+
+// measure counts VC sequences in w, the "m" of the Porter algorithm.
+func measure(w string) int {
+	m := 0
+	i := 0
+	n := len(w)
+	for i < n && isConsonant(w, i) {
+		i++
+	}
+	for i < n {
+		for i < n && !isConsonant(w, i) {
+			i++
+		}
+		if i >= n {
+			break
+		}
+		for i < n && isConsonant(w, i) {
+			i++
+		}
+		m++
+	}
+	return m
+}
+
+func hasVowel(w string) bool {
+	for i := range len(w) {
+		if !isConsonant(w, i) {
+			return true
+		}
+	}
+	return false
+}
+
+func endsDoubleConsonant(w string) bool {
+	n := len(w)
+	return n >= 2 && w[n-1] == w[n-2] && isConsonant(w, n-1)
+}
+
+// endsCVC reports whether w ends consonant-vowel-consonant where the final
+// consonant is not w, x or y (e.g. "hop", not "snow").
+func endsCVC(w string) bool {
+	n := len(w)
+	if n < 3 || !isConsonant(w, n-3) || isConsonant(w, n-2) || !isConsonant(w, n-1) {
+		return false
+	}
+	switch w[n-1] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+func step1a(w string) string {
+	switch {
+	case strings.HasSuffix(w, "sses"):
+		return w[:len(w)-2]
+	case strings.HasSuffix(w, "ies"):
+		return w[:len(w)-2]
+	case strings.HasSuffix(w, "ss"):
+		return w
+	case strings.HasSuffix(w, "s"):
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+func step1b(w string) string {
+	if strings.HasSuffix(w, "eed") {
+		if measure(w[:len(w)-3]) > 0 {
+			return w[:len(w)-1]
+		}
+		return w
+	}
+	var base string
+	switch {
+	case strings.HasSuffix(w, "ed") && hasVowel(w[:len(w)-2]):
+		base = w[:len(w)-2]
+	case strings.HasSuffix(w, "ing") && hasVowel(w[:len(w)-3]):
+		base = w[:len(w)-3]
+	default:
+		return w
+	}
+	switch {
+	case strings.HasSuffix(base, "at"), strings.HasSuffix(base, "bl"), strings.HasSuffix(base, "iz"):
+		return base + "e"
+	case endsDoubleConsonant(base):
+		switch base[len(base)-1] {
+		case 'l', 's', 'z':
+			return base
+		}
+		return base[:len(base)-1]
+	case measure(base) == 1 && endsCVC(base):
+		return base + "e"
+	}
+	return base
+}
+
+func step1c(w string) string {
+	if strings.HasSuffix(w, "y") && hasVowel(w[:len(w)-1]) {
+		return w[:len(w)-1] + "i"
+	}
+	return w
+}
+
+var step2Suffixes = []struct{ suffix, replacement string }{
+	{"ational", "ate"},
+	{"tional", "tion"},
+	{"ization", "ize"},
+	{"iveness", "ive"},
+	{"fulness", "ful"},
+	{"ousness", "ous"},
+	{"ation", "ate"},
+	{"ator", "ate"},
+	{"alism", "al"},
+	{"aliti", "al"},
+	{"iviti", "ive"},
+	{"biliti", "ble"},
+	{"izer", "ize"},
+	{"enci", "ence"},
+	{"anci", "ance"},
+	{"entli", "ent"},
+	{"ousli", "ous"},
+	{"alli", "al"},
+	{"eli", "e"},
+}
+
+func step2(w string) string {
+	for _, s := range step2Suffixes {
+		if strings.HasSuffix(w, s.suffix) {
+			base := w[:len(w)-len(s.suffix)]
+			if measure(base) > 0 {
+				return base + s.replacement
+			}
+			return w
+		}
+	}
+	return w
+}
+
+func step5(w string) string {
+	if strings.HasSuffix(w, "e") {
+		base := w[:len(w)-1]
+		if m := measure(base); m > 1 || (m == 1 && !endsCVC(base)) {
+			w = base
+		}
+	}
+	if measure(w) > 1 && strings.HasSuffix(w, "ll") {
+		w = w[:len(w)-1]
+	}
+	return w
+}
@@ -0,0 +1,55 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// token is a normalized term with its position and byte span in the source text.
+type token struct {
+	term  string
+	pos   int
+	start int
+	end   int
+}
+
+// tokenize splits text into lowercased, stemmed tokens. Letters and digits form
+// words; everything else separates them. Underscores and intra-word dots are
+// treated as separators so identifiers like "parse_key" and "session.Session"
+// are searchable by their parts.
+func tokenize(text string) []token {
+	var toks []token
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			toks = appendToken(toks, text[start:i], start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		toks = appendToken(toks, text[start:], start, len(text))
+	}
+	return toks
+}
+
+func appendToken(toks []token, word string, start, end int) []token {
+	if utf8.RuneCountInString(word) > maxTermLength {
+		return toks
+	}
+	return append(toks, token{
+		term:  stem(strings.ToLower(word)),
+		pos:   len(toks),
+		start: start,
+		end:   end,
+	})
+}
+
+// maxTermLength skips tokens that are almost certainly hashes or encoded data.
+const maxTermLength = 64
@@ -1,6 +1,7 @@
 package session
 
 import (
+	"cmp"
 	"fmt"
 	"path/filepath"
 	"strconv"
@@ -8,8 +9,90 @@ import (
 	"time"
 )
 
+// FormatSessionID returns the ID of the seq'th session created in the second
+// epoch: "EPOCH" for the first and "EPOCH.N" for later ones, N counting from 2.
+func FormatSessionID(epoch int64, seq int) string {
+	if seq <= 1 {
+		return strconv.FormatInt(epoch, 10)
+	}
+	return fmt.Sprintf("%d.%d", epoch, seq)
+}
+
+// parseSessionID splits a session ID into its epoch and sequence number.
+func parseSessionID(id string) (epoch int64, seq int, ok bool) {
+	epochStr, seqStr, hasSeq := strings.Cut(id, ".")
+	epoch, err := strconv.ParseInt(epochStr, 10, 64)
+	if err != nil || epochStr == "" || epochStr[0] == '+' || epochStr[0] == '-' {
+		return 0, 0, false
+	}
+	seq = 1
+	if hasSeq {
+		n, err := strconv.Atoi(seqStr)
+		if err != nil || n < 2 || seqStr[0] == '+' || seqStr[0] == '0' {
+			return 0, 0, false
+		}
+		seq = n
+	}
+	return epoch, seq, true
+}
+
+// SessionIDEpoch returns the creation time, in Unix seconds, encoded in a
+// session ID.
+func SessionIDEpoch(id string) (int64, bool) {
+	epoch, _, ok := parseSessionID(id)
+	return epoch, ok
+}
+
+// IsSessionID reports whether id has the form of a session ID, "EPOCH" or "EPOCH.N".
+func IsSessionID(id string) bool {
+	_, _, ok := parseSessionID(id)
+	return ok
+}
+
+// CompareSessionIDs orders session IDs by creation: by epoch, then by
+// sequence number. IDs that are not epochs sort after those that are, by name.
+func CompareSessionIDs(a, b string) int {
+	ea, sa, aok := parseSessionID(a)
+	eb, sb, bok := parseSessionID(b)
+	switch {
+	case aok && bok:
+		if ea != eb {
+			return cmp.Compare(ea, eb)
+		}
+		return cmp.Compare(sa, sb)
+	case aok:
+		return -1
+	case bok:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// MaxSlugLength is the maximum length of a session slug.
+const MaxSlugLength = 64
+
+// ValidateSlug checks that slug can name a session in keys: lowercase letters,
+// digits and hyphens, starting with a letter so it cannot be mistaken for an ID.
+func ValidateSlug(slug string) error {
+	if slug == "" {
+		return fmt.Errorf("empty slug")
+	}
+	if len(slug) > MaxSlugLength {
+		return fmt.Errorf("slug %q exceeds %d characters", slug, MaxSlugLength)
+	}
+	if slug[0] < 'a' || slug[0] > 'z' {
+		return fmt.Errorf("slug %q must start with a lowercase letter", slug)
+	}
+	for _, r := range slug {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return fmt.Errorf("slug %q may only contain lowercase letters, digits and hyphens", slug)
+		}
+	}
+	return nil
+}
+
 // ParseKey parses a key string into its components.
-// Session key: "1740422423"
+// Session key: "1740422423" (or "1740422423.2", or a slug such as "csv-loader")
 // Artifact key: "1740422423/sessions-cli-spec.md"
 func ParseKey(key string) (sessionID, artifactFile string, isArtifact bool) {
 	if idx := strings.IndexByte(key, '/'); idx >= 0 {
@@ -19,7 +102,9 @@ func ParseKey(key string) (sessionID, artifactFile string, isArtifact bool) {
 }
 
 // EpochToYearMonth converts an epoch string to a "YYYY-MM" directory name using UTC.
+// A ".N" sequence suffix, as in "1740422423.2", is ignored.
 func EpochToYearMonth(epochStr string) (string, error) {
+	epochStr, _, _ = strings.Cut(epochStr, ".")
 	epoch, err := strconv.ParseInt(epochStr, 10, 64)
 	if err != nil {
 		return "", fmt.Errorf("invalid epoch %q: %w", epochStr, err)
@@ -29,8 +114,9 @@ func EpochToYearMonth(epochStr string) (string, error) {
 }
 
 // ResolveKeyToPath resolves a key to a filesystem path.
-// Session: .sessions/sessions/YYYY-MM/EPOCH.md
-// Artifact: .sessions/artifacts/YYYY-MM/EPOCH/artifact.md
+// Session: .sessions/sessions/YYYY-MM/EPOCH[.N].md
+// Artifact: .sessions/artifacts/YYYY-MM/EPOCH[.N]/artifact.md
+// Slugs are not resolved here; see store.Resolve.
 func ResolveKeyToPath(sessionsDir, key string) string {
 	sessionID, artifactFile, isArtifact := ParseKey(key)
 	yearMonth, err := EpochToYearMonth(sessionID)
@@ -1,37 +1,84 @@
 package session
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 const MaxSummaryLength = 150
 
 type Session struct {
-	Timestamp       time.Time     `yaml:"timestamp"`
-	SessionID       string        `yaml:"session_id"`
-	Summary         string        `yaml:"summary"`
-	Tags            []string      `yaml:"tags"`
-	FilesChanged    []FileChange  `yaml:"files_changed"`
-	Artifacts       []ArtifactRef `yaml:"artifacts"`
-	RelatedSessions []string      `yaml:"related_sessions"`
-	Body            string        `yaml:"-"`
+	Timestamp       time.Time     `yaml:"timestamp" json:"timestamp"`
+	SessionID       string        `yaml:"session_id" json:"session_id"`
+	Slug            string        `yaml:"slug,omitempty" json:"slug,omitempty"`
+	Summary         string        `yaml:"summary" json:"summary"`
+	Tags            []string      `yaml:"tags" json:"tags"`
+	FilesChanged    []FileChange  `yaml:"files_changed" json:"files_changed"`
+	Artifacts       []ArtifactRef `yaml:"artifacts" json:"artifacts"`
+	RelatedSessions []string      `yaml:"related_sessions" json:"related_sessions"`
+	Template        string        `yaml:"template,omitempty" json:"template,omitempty"`
+	Git             *GitInfo      `yaml:"git,omitempty" json:"git,omitempty"`
+	Body            string        `yaml:"-" json:"body,omitempty"`
+	// Archived is set on sessions read from .sessions/archive/.
+	Archived bool `yaml:"-" json:"archived,omitempty"`
 }
 
 type FileChange struct {
-	Path    string `yaml:"path"`
-	Action  string `yaml:"action"`
-	Summary string `yaml:"summary"`
+	Path    string `yaml:"path" json:"path"`
+	Action  string `yaml:"action" json:"action"`
+	Summary string `yaml:"summary" json:"summary"`
+	// From is the previous path of a renamed file.
+	From string `yaml:"from,omitempty" json:"from,omitempty"`
+}
+
+// FileActions are the valid values of FileChange.Action.
+var FileActions = []string{"added", "modified", "deleted", "renamed"}
+
+// GitInfo records where in the repository's history a session happened.
+type GitInfo struct {
+	// Commit is HEAD when the session was created.
+	Commit string `yaml:"commit,omitempty" json:"commit,omitempty"`
+	Branch string `yaml:"branch,omitempty" json:"branch,omitempty"`
+	Author string `yaml:"author,omitempty" json:"author,omitempty"`
+	// Dirty reports uncommitted changes when the session was created.
+	Dirty bool `yaml:"dirty,omitempty" json:"dirty,omitempty"`
+	// Commits are the commits made as part of the session, oldest first.
+	Commits []string `yaml:"commits,omitempty" json:"commits,omitempty"`
+}
+
+// MinCommitPrefix is the shortest abbreviated commit hash HasCommit accepts.
+const MinCommitPrefix = 4
+
+// HasCommit reports whether sha, a full or abbreviated commit hash, is the
+// session's base commit or one of its commits.
+func (g *GitInfo) HasCommit(sha string) bool {
+	if g == nil || len(sha) < MinCommitPrefix {
+		return false
+	}
+	sha = strings.ToLower(sha)
+	if strings.HasPrefix(g.Commit, sha) {
+		return true
+	}
+	for _, c := range g.Commits {
+		if strings.HasPrefix(c, sha) {
+			return true
+		}
+	}
+	return false
 }
 
 type ArtifactRef struct {
-	Path    string `yaml:"path"`
-	Type    string `yaml:"type"`
-	Summary string `yaml:"summary"`
+	Path    string `yaml:"path" json:"path"`
+	Type    string `yaml:"type" json:"type"`
+	Summary string `yaml:"summary" json:"summary"`
 }
 
 type Artifact struct {
-	Title      string `yaml:"title"`
-	Type       string `yaml:"type"`
-	Summary    string `yaml:"summary"`
-	Status     string `yaml:"status"`
-	Supersedes string `yaml:"supersedes"`
-	Body       string `yaml:"-"`
+	Title        string `yaml:"title" json:"title"`
+	Type         string `yaml:"type" json:"type"`
+	Summary      string `yaml:"summary" json:"summary"`
+	Status       string `yaml:"status" json:"status"`
+	Supersedes   string `yaml:"supersedes" json:"supersedes"`
+	SupersededBy string `yaml:"superseded_by,omitempty" json:"superseded_by,omitempty"`
+	Body         string `yaml:"-" json:"body,omitempty"`
 }
@@ -0,0 +1,182 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/glopal/sessions/internal/parser"
+	"github.com/glopal/sessions/internal/session"
+)
+
+// DefaultSession is the session template used when none is chosen.
+const DefaultSession = "default"
+
+// Session is the data a session template is executed with.
+type Session struct {
+	Slug   string
+	Tags   []string
+	Files  []session.FileChange // files changed in the working tree
+	Branch string
+	Date   string // YYYY-MM-DD
+	Now    time.Time
+}
+
+// SessionData returns the template data for s, dated by its timestamp, or
+// by the current time if it has none yet.
+func SessionData(s *session.Session) Session {
+	now := s.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+	d := Session{Slug: s.Slug, Tags: s.Tags, Files: s.FilesChanged, Date: now.Format("2006-01-02"), Now: now}
+	if s.Git != nil {
+		d.Branch = s.Git.Branch
+	}
+	return d
+}
+
+// SessionTemplate is a session body template: an optional YAML frontmatter
+// followed by the body in text/template syntax.
+type SessionTemplate struct {
+	Name        string `yaml:"-"`
+	Description string `yaml:"description"`
+	// Required are the headings of the "## " sections a session started
+	// from the template must fill in.
+	Required []string `yaml:"required"`
+
+	source string
+	tmpl   *template.Template
+}
+
+// LoadSession loads the session template called name (DefaultSession if
+// empty) from .sessions/templates/sessions/<name>.md, falling back to the
+// built-in template of that name. With sessionsDir empty only built-in
+// templates are available.
+func LoadSession(sessionsDir, name string) (*SessionTemplate, error) {
+	if name == "" {
+		name = DefaultSession
+	}
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid template name %q", name)
+	}
+	rel := filepath.Join(Dir, "sessions", name+".md")
+	var data []byte
+	err := error(fs.ErrNotExist)
+	if sessionsDir != "" {
+		data, err = os.ReadFile(filepath.Join(sessionsDir, rel))
+	}
+	source := rel
+	if errors.Is(err, fs.ErrNotExist) {
+		data, err = builtin.ReadFile("sessions/" + name + ".md")
+		source = "built-in " + name
+		if err != nil {
+			return nil, fmt.Errorf("unknown session template %q (available: %s)", name, strings.Join(SessionNames(sessionsDir), ", "))
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading template: %w", err)
+	}
+
+	t := &SessionTemplate{Name: name, source: source}
+	text := string(data)
+	if strings.HasPrefix(strings.TrimSpace(text), "---") {
+		if text, err = parser.ParseFrontmatter(text, t); err != nil {
+			return nil, fmt.Errorf("template %s: %w", source, err)
+		}
+	}
+	if t.tmpl, err = template.New(name).Parse(text); err != nil {
+		return nil, fmt.Errorf("parsing template %s: %w", source, err)
+	}
+	return t, nil
+}
+
+// SessionNames returns the names of the built-in session templates and
+// those in .sessions/templates/sessions/, sorted.
+func SessionNames(sessionsDir string) []string {
+	var names []string
+	entries, _ := builtin.ReadDir("sessions")
+	if sessionsDir != "" {
+		if dir, err := os.ReadDir(filepath.Join(sessionsDir, Dir, "sessions")); err == nil {
+			entries = append(entries, dir...)
+		}
+	}
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".md"); ok && !e.IsDir() && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Render executes the template with data.
+func (t *SessionTemplate) Render(data Session) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("executing template %s: %w", t.source, err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// SectionIssue is a required section that a session body has not filled in.
+type SectionIssue struct {
+	Section string
+	Reason  string // "missing", "empty" or "scaffold text"
+}
+
+// Unfilled checks body for the template's required sections, reporting
+// those that are missing, empty, or still hold the template's own text as
+// rendered with data.
+func (t *SessionTemplate) Unfilled(body string, data Session) []SectionIssue {
+	var scaffold map[string]string
+	if rendered, err := t.Render(data); err == nil {
+		scaffold = Sections(rendered)
+	}
+	got := Sections(body)
+	var issues []SectionIssue
+	for _, heading := range t.Required {
+		key := strings.ToLower(strings.TrimSpace(heading))
+		text, ok := got[key]
+		switch {
+		case !ok:
+			issues = append(issues, SectionIssue{Section: heading, Reason: "missing"})
+		case text == "":
+			issues = append(issues, SectionIssue{Section: heading, Reason: "empty"})
+		case text == scaffold[key]:
+			issues = append(issues, SectionIssue{Section: heading, Reason: "scaffold text"})
+		}
+	}
+	return issues
+}
+
+// Sections splits a markdown body into its "## " sections, keyed by
+// lower-cased heading. Each section's text runs to the next "## " heading
+// and is trimmed of surrounding whitespace.
+func Sections(body string) map[string]string {
+	sections := make(map[string]string)
+	var heading string
+	var text []string
+	inSection := false
+	flush := func() {
+		if inSection {
+			sections[heading] = strings.TrimSpace(strings.Join(text, "\n"))
+		}
+	}
+	for _, line := range strings.Split(body, "\n") {
+		if h, ok := strings.CutPrefix(line, "## "); ok {
+			flush()
+			heading, text, inSection = strings.ToLower(strings.TrimSpace(h)), nil, true
+			continue
+		}
+		text = append(text, line)
+	}
+	flush()
+	return sections
+}
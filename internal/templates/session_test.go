@@ -0,0 +1,103 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionTemplates(t *testing.T) {
+	dir := t.TempDir()
+	data := Session{Slug: "outage", Branch: "main", Date: "2026-02-24", Now: time.Date(2026, 2, 24, 10, 30, 0, 0, time.UTC)}
+	render := func(name string) (*SessionTemplate, string) {
+		t.Helper()
+		tmpl, err := LoadSession(dir, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := tmpl.Render(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tmpl, body
+	}
+
+	if _, body := render(""); !strings.HasPrefix(body, "## Overview") || !strings.Contains(body, "## Key Decisions") {
+		t.Errorf("default body = %q", body)
+	}
+	tmpl, body := render("incident")
+	if !strings.Contains(body, "- 2026-02-24: Incident detected.") {
+		t.Errorf("incident body = %q, want the date filled in", body)
+	}
+	if !slices.Equal(tmpl.Required, []string{"Impact", "Timeline", "Root Cause", "Remediation"}) {
+		t.Errorf("incident required = %v", tmpl.Required)
+	}
+	if _, err := LoadSession(dir, "postmortem"); err == nil || !strings.Contains(err.Error(), "bugfix, default, feature, incident") {
+		t.Errorf("unknown template error = %v", err)
+	}
+	if _, err := LoadSession(dir, "../config"); err == nil {
+		t.Error("template name with a path loaded")
+	}
+
+	overrides := filepath.Join(dir, Dir, "sessions")
+	if err := os.MkdirAll(overrides, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range map[string]string{
+		"default.md":    "## Notes\n\nOn {{.Branch}}.\n",
+		"postmortem.md": "---\ndescription: Post-mortem\nrequired: [Lessons]\n---\n## Lessons\n\nWhat {{.Slug}} taught us.\n",
+	} {
+		if err := os.WriteFile(filepath.Join(overrides, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, body := render(""); body != "## Notes\n\nOn main." {
+		t.Errorf("overridden default body = %q", body)
+	}
+	tmpl, body = render("postmortem")
+	if tmpl.Description != "Post-mortem" || body != "## Lessons\n\nWhat outage taught us." {
+		t.Errorf("postmortem = %q, %q", tmpl.Description, body)
+	}
+	if got := SessionNames(dir); !slices.Equal(got, []string{"bugfix", "default", "feature", "incident", "postmortem"}) {
+		t.Errorf("SessionNames = %v", got)
+	}
+	if got := SessionNames(""); slices.Contains(got, "postmortem") {
+		t.Errorf("SessionNames without a store = %v, want only built-ins", got)
+	}
+}
+
+func TestUnfilled(t *testing.T) {
+	tmpl, err := LoadSession(t.TempDir(), "bugfix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := strings.Join([]string{
+		"## Symptoms",
+		"",
+		"Imports hung on files over 2GB.",
+		"",
+		"## Root Cause",
+		"",
+		"Why it happened.",
+		"",
+		"## Verification",
+		"",
+		"Not yet.",
+	}, "\n")
+	got := tmpl.Unfilled(body, Session{})
+	want := []SectionIssue{
+		{Section: "Root Cause", Reason: "scaffold text"},
+		{Section: "Fix", Reason: "missing"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Unfilled = %v, want %v", got, want)
+	}
+
+	body = "## Symptoms\n\nCrash.\n\n## root cause\n\nA race.\n\n## Fix\n\n"
+	if got := tmpl.Unfilled(body, Session{}); !slices.Equal(got, []SectionIssue{{Section: "Fix", Reason: "empty"}}) {
+		t.Errorf("Unfilled = %v, want Fix empty", got)
+	}
+}
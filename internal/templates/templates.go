@@ -0,0 +1,78 @@
+// Package templates renders the bodies new sessions and artifacts start
+// from. Built-in templates can be overridden, and session templates added,
+// per store by files in .sessions/templates/.
+package templates
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Dir is the directory inside .sessions/ that holds template overrides.
+const Dir = "templates"
+
+// defaultName is the template used for artifact types without one of their
+// own.
+const defaultName = "default"
+
+//go:embed artifacts/*.md sessions/*.md
+var builtin embed.FS
+
+// Artifact is the data an artifact template is executed with.
+type Artifact struct {
+	Title     string
+	Type      string
+	SessionID string
+	Now       time.Time
+}
+
+// ArtifactBody renders the body template for an artifact of type data.Type.
+// The template is the first of .sessions/templates/artifacts/<type>.md, the
+// built-in one for the type, .sessions/templates/artifacts/default.md and
+// the built-in default. Templates use text/template syntax.
+func ArtifactBody(sessionsDir string, data Artifact) (string, error) {
+	text, name, err := artifactTemplate(sessionsDir, data.Type)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing template %s: %w", name, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("executing template %s: %w", name, err)
+	}
+	return strings.TrimSpace(b.String()) + "\n", nil
+}
+
+// artifactTemplate finds the template for an artifact type, returning its
+// text and a name for it in errors.
+func artifactTemplate(sessionsDir, typ string) (string, string, error) {
+	names := []string{defaultName}
+	if typ != "" && !strings.ContainsAny(typ, `/\`) && !strings.HasPrefix(typ, ".") {
+		names = []string{typ, defaultName}
+	}
+	for _, name := range names {
+		rel := filepath.Join(Dir, "artifacts", name+".md")
+		data, err := os.ReadFile(filepath.Join(sessionsDir, rel))
+		if err == nil {
+			return string(data), rel, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", "", fmt.Errorf("reading template: %w", err)
+		}
+		data, err = builtin.ReadFile("artifacts/" + name + ".md")
+		if err == nil {
+			return string(data), "built-in " + name, nil
+		}
+	}
+	return "", "", fmt.Errorf("no template for artifact type %q", typ)
+}
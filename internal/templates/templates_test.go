@@ -0,0 +1,61 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArtifactBody(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 2, 24, 10, 30, 0, 0, time.UTC)
+	body := func(typ string) string {
+		t.Helper()
+		b, err := ArtifactBody(dir, Artifact{Title: "Retry Policy", Type: typ, SessionID: "1771900000", Now: now})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	if got := body("analysis"); got != "Content goes here.\n" {
+		t.Errorf("analysis body = %q, want the default", got)
+	}
+	if got := body("decision"); !strings.Contains(got, "## Context") || !strings.Contains(got, "## Consequences") {
+		t.Errorf("decision body = %q", got)
+	}
+	if got := body("debug-log"); !strings.Contains(got, "### 2026-02-24 10:30") {
+		t.Errorf("debug-log body = %q, want a timestamped entry", got)
+	}
+
+	overrides := filepath.Join(dir, Dir, "artifacts")
+	if err := os.MkdirAll(overrides, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range map[string]string{
+		"decision.md": "# {{.Title}} ({{.SessionID}})\n",
+		"default.md":  "Notes on {{.Type}}.\n",
+	} {
+		if err := os.WriteFile(filepath.Join(overrides, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := body("decision"); got != "# Retry Policy (1771900000)\n" {
+		t.Errorf("overridden decision body = %q", got)
+	}
+	if got := body("investigation"); !strings.Contains(got, "## Hypothesis") {
+		t.Errorf("investigation body = %q, want the built-in over the default override", got)
+	}
+	if got := body("analysis"); got != "Notes on analysis.\n" {
+		t.Errorf("analysis body = %q, want the default override", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(overrides, "decision.md"), []byte("{{.Missing}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ArtifactBody(dir, Artifact{Type: "decision"}); err == nil {
+		t.Error("template with an unknown field rendered")
+	}
+}
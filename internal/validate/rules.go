@@ -0,0 +1,449 @@
+package validate
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/glopal/sessions/internal/session"
+	"github.com/glopal/sessions/internal/templates"
+	"github.com/glopal/sessions/pkg/store"
+)
+
+// Rules are the checks Run makes, in the order their issues are reported.
+var Rules = []Rule{
+	{
+		Name:        "parse",
+		Description: "Files must have valid YAML frontmatter",
+		Severity:    Error,
+		Hint:        "sessions edit <KEY> --interactive  (fix the frontmatter by hand)",
+		Check:       checkParse,
+	},
+	{
+		Name:        "summary",
+		Description: fmt.Sprintf("Summaries must be present and at most %d characters", session.MaxSummaryLength),
+		Severity:    Error,
+		Hint:        fmt.Sprintf("sessions edit <KEY> --summary \"<SUMMARY_LTE_%d_CHARS>\"", session.MaxSummaryLength),
+		Check:       checkSummary,
+	},
+	{
+		Name:        "session-id",
+		Description: "A session's session_id and timestamp must match its file name",
+		Severity:    Error,
+		Hint:        "sessions edit <KEY> --interactive",
+		Check:       checkSessionID,
+	},
+	{
+		Name:        "files-changed",
+		Description: "files_changed entries need a path and an action of " + strings.Join(session.FileActions, ", "),
+		Severity:    Error,
+		Hint:        "sessions edit <KEY> --add-file <PATH>:<ACTION>:<SUMMARY>",
+		Check:       checkFilesChanged,
+	},
+	{
+		Name:        "artifact-refs",
+		Description: "A session's artifacts must exist and list every artifact filed under it",
+		Severity:    Error,
+		Hint:        "sessions edit <KEY> --interactive  (or restore the artifact with 'sessions restore')",
+		Check:       checkArtifactRefs,
+	},
+	{
+		Name:        "related-sessions",
+		Description: "related_sessions must name other existing sessions that list the session back",
+		Severity:    Warning,
+		Hint:        "sessions link <KEY> <RELATED_KEY>",
+		Check:       checkRelatedSessions,
+	},
+	{
+		Name:        "tags",
+		Description: "Tags must match the tag_pattern in .sessions/config.yaml and be listed once",
+		Severity:    Warning,
+		Hint:        "sessions edit <KEY> --add-tag <TAG> --remove-tag <TAG>",
+		Check:       checkTags,
+	},
+	{
+		Name:        "artifact-type",
+		Description: "Artifact types must be declared in .sessions/config.yaml",
+		Severity:    Error,
+		Hint:        "sessions edit <KEY> --type <TYPE>  (types are declared in .sessions/config.yaml)",
+		Check:       checkArtifactType,
+	},
+	{
+		Name:        "artifact-status",
+		Description: "Artifact statuses must be declared in .sessions/config.yaml for their type",
+		Severity:    Error,
+		Hint:        "sessions edit <KEY> --status <STATUS>  (statuses are declared in .sessions/config.yaml)",
+		Check:       checkArtifactStatus,
+	},
+	{
+		Name:        "required-sections",
+		Description: "Sessions started from a template must fill in its required sections",
+		Severity:    Warning,
+		Hint:        "sessions edit <KEY> --interactive  (fill in the required sections)",
+		Check:       checkRequiredSections,
+	},
+	{
+		Name:        "paths",
+		Description: "files_changed paths must exist in the working tree, or have been renamed to one that does",
+		Severity:    Warning,
+		Hint:        "sessions paths migrate  (records the renames found in git history)",
+		OptIn:       true,
+		Check:       checkPaths,
+	},
+}
+
+func issuef(format string, args ...any) Issue {
+	return Issue{Message: fmt.Sprintf(format, args...)}
+}
+
+func checkParse(c *Context, f *store.File) []Issue {
+	if f.Err == "" {
+		return nil
+	}
+	return []Issue{issuef("cannot be parsed: %s", f.Err)}
+}
+
+func checkSummary(c *Context, f *store.File) []Issue {
+	var summary string
+	switch {
+	case f.Session != nil:
+		summary = f.Session.Summary
+	case f.Artifact != nil:
+		summary = f.Artifact.Summary
+	default:
+		return nil
+	}
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return []Issue{issuef("summary is missing")}
+	}
+	if len(summary) > session.MaxSummaryLength {
+		return []Issue{issuef("summary is too long (%d characters)", len(summary))}
+	}
+	return nil
+}
+
+func checkSessionID(c *Context, f *store.File) []Issue {
+	s := f.Session
+	if s == nil {
+		return nil
+	}
+	var issues []Issue
+	if id := f.Key; s.SessionID != id {
+		issues = append(issues, Issue{
+			Message: fmt.Sprintf("session_id %q does not match file name %s", s.SessionID, path.Base(f.Path)),
+			Fix: &Fix{
+				Description: "set session_id to " + id,
+				Key:         id,
+				Apply:       func(s *store.Session) { s.SessionID = id },
+			},
+		})
+	}
+	epoch, ok := session.SessionIDEpoch(f.Key)
+	if !ok {
+		return issues
+	}
+	if s.Timestamp.IsZero() {
+		created := time.Unix(epoch, 0).UTC()
+		issues = append(issues, Issue{
+			Message: "timestamp is missing",
+			Fix: &Fix{
+				Description: "set timestamp to " + created.Format(time.RFC3339),
+				Key:         f.Key,
+				Apply:       func(s *store.Session) { s.Timestamp = created },
+			},
+		})
+	} else if s.Timestamp.Unix() != epoch {
+		issues = append(issues, issuef("timestamp %s does not match the creation time in file name %s", s.Timestamp.Format(time.RFC3339), path.Base(f.Path)))
+	}
+	return issues
+}
+
+// actionAliases maps spellings of file actions to the action they mean.
+var actionAliases = map[string]string{
+	"a": "added", "add": "added", "new": "added", "create": "added", "created": "added",
+	"m": "modified", "modify": "modified", "change": "modified", "changed": "modified", "update": "modified", "updated": "modified", "edit": "modified", "edited": "modified",
+	"d": "deleted", "delete": "deleted", "remove": "deleted", "removed": "deleted",
+	"r": "renamed", "rename": "renamed", "move": "renamed", "moved": "renamed",
+}
+
+func checkFilesChanged(c *Context, f *store.File) []Issue {
+	if f.Session == nil {
+		return nil
+	}
+	var issues []Issue
+	for i, fc := range f.Session.FilesChanged {
+		if strings.TrimSpace(fc.Path) == "" {
+			issues = append(issues, issuef("files_changed[%d] has no path", i))
+		}
+		if slices.Contains(session.FileActions, fc.Action) {
+			continue
+		}
+		issue := issuef("files_changed[%d] (%s) has invalid action %q", i, fc.Path, fc.Action)
+		action := strings.ToLower(strings.TrimSpace(fc.Action))
+		if alias, ok := actionAliases[action]; ok {
+			action = alias
+		}
+		if slices.Contains(session.FileActions, action) {
+			p, from := fc.Path, fc.Action
+			issue.Fix = &Fix{
+				Description: fmt.Sprintf("set the action of %s to %s", fc.Path, action),
+				Key:         f.Key,
+				Apply: func(s *store.Session) {
+					for i := range s.FilesChanged {
+						if s.FilesChanged[i].Path == p && s.FilesChanged[i].Action == from {
+							s.FilesChanged[i].Action = action
+						}
+					}
+				},
+			}
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+func checkArtifactRefs(c *Context, f *store.File) []Issue {
+	if f.Session != nil {
+		return checkSessionArtifacts(c, f)
+	}
+	if f.Artifact == nil {
+		return nil
+	}
+	sessionID, name, _ := session.ParseKey(f.Key)
+	sf, ok := c.Session(sessionID)
+	if !ok {
+		return []Issue{issuef("belongs to session %s, which does not exist", sessionID)}
+	}
+	if sf.Session == nil || slices.ContainsFunc(sf.Session.Artifacts, func(ref store.ArtifactRef) bool { return ref.Path == name }) {
+		return nil
+	}
+	ref := store.ArtifactRef{Path: name, Type: f.Artifact.Type, Summary: f.Artifact.Summary}
+	return []Issue{{
+		Message: fmt.Sprintf("is not listed in the artifacts of session %s", sessionID),
+		Fix: &Fix{
+			Description: fmt.Sprintf("add %s to the artifacts of %s", name, sessionID),
+			Key:         sessionID,
+			Apply: func(s *store.Session) {
+				if !slices.ContainsFunc(s.Artifacts, func(r store.ArtifactRef) bool { return r.Path == name }) {
+					s.Artifacts = append(s.Artifacts, ref)
+				}
+			},
+		},
+	}}
+}
+
+// checkSessionArtifacts checks that a session's artifact references name
+// existing artifacts and carry their current type and summary.
+func checkSessionArtifacts(c *Context, f *store.File) []Issue {
+	var issues []Issue
+	for _, ref := range f.Session.Artifacts {
+		name := ref.Path
+		af, ok := c.Artifact(session.FormatArtifactKey(f.Key, name))
+		switch {
+		case !ok:
+			issues = append(issues, Issue{
+				Message: fmt.Sprintf("artifacts lists %s, which does not exist", name),
+				Fix: &Fix{
+					Description: fmt.Sprintf("remove %s from artifacts", name),
+					Key:         f.Key,
+					Apply: func(s *store.Session) {
+						s.Artifacts = slices.DeleteFunc(s.Artifacts, func(r store.ArtifactRef) bool { return r.Path == name })
+					},
+				},
+			})
+		case af.Artifact != nil && (ref.Type != af.Artifact.Type || ref.Summary != af.Artifact.Summary):
+			typ, summary := af.Artifact.Type, af.Artifact.Summary
+			issues = append(issues, Issue{
+				Message: fmt.Sprintf("the type and summary artifacts lists for %s are out of date", name),
+				Fix: &Fix{
+					Description: fmt.Sprintf("copy the type and summary of %s into artifacts", name),
+					Key:         f.Key,
+					Apply: func(s *store.Session) {
+						for i := range s.Artifacts {
+							if s.Artifacts[i].Path == name {
+								s.Artifacts[i].Type, s.Artifacts[i].Summary = typ, summary
+							}
+						}
+					},
+				},
+			})
+		}
+	}
+	return issues
+}
+
+func checkRelatedSessions(c *Context, f *store.File) []Issue {
+	if f.Session == nil {
+		return nil
+	}
+	var issues []Issue
+	for _, id := range f.Session.RelatedSessions {
+		related, ok := c.Session(id)
+		switch {
+		case id == f.Key:
+			issues = append(issues, Issue{
+				Message: "related_sessions lists the session itself",
+				Fix:     removeRelated(f.Key, id),
+			})
+		case !ok:
+			issues = append(issues, Issue{
+				Message: fmt.Sprintf("related session %s does not exist", id),
+				Fix:     removeRelated(f.Key, id),
+			})
+		case related.Session != nil && !slices.Contains(related.Session.RelatedSessions, f.Key):
+			key := f.Key
+			issues = append(issues, Issue{
+				Message: fmt.Sprintf("related session %s does not list it back", id),
+				Fix: &Fix{
+					Description: fmt.Sprintf("add %s to the related_sessions of %s", key, id),
+					Key:         id,
+					Apply: func(s *store.Session) {
+						if !slices.Contains(s.RelatedSessions, key) {
+							s.RelatedSessions = append(s.RelatedSessions, key)
+						}
+					},
+				},
+			})
+		}
+	}
+	return issues
+}
+
+func removeRelated(key, id string) *Fix {
+	return &Fix{
+		Description: fmt.Sprintf("remove %s from related_sessions", id),
+		Key:         key,
+		Apply: func(s *store.Session) {
+			s.RelatedSessions = slices.DeleteFunc(s.RelatedSessions, func(r string) bool { return r == id })
+		},
+	}
+}
+
+func checkTags(c *Context, f *store.File) []Issue {
+	if f.Session == nil {
+		return nil
+	}
+	pattern := c.Config.TagPattern()
+	var issues []Issue
+	seen := make(map[string]bool)
+	for _, tag := range f.Session.Tags {
+		if seen[tag] {
+			issues = append(issues, Issue{
+				Message: fmt.Sprintf("tag %q is listed more than once", tag),
+				Fix: &Fix{
+					Description: fmt.Sprintf("list tag %q once", tag),
+					Key:         f.Key,
+					Apply:       func(s *store.Session) { s.Tags = dedupe(s.Tags) },
+				},
+			})
+			continue
+		}
+		seen[tag] = true
+		if pattern == nil || pattern.MatchString(tag) {
+			continue
+		}
+		issue := issuef("tag %q does not match %s", tag, pattern)
+		if norm := normalizeTag(tag); norm != tag && pattern.MatchString(norm) {
+			from := tag
+			issue.Fix = &Fix{
+				Description: fmt.Sprintf("rename tag %q to %q", tag, norm),
+				Key:         f.Key,
+				Apply: func(s *store.Session) {
+					for i := range s.Tags {
+						if s.Tags[i] == from {
+							s.Tags[i] = norm
+						}
+					}
+					s.Tags = dedupe(s.Tags)
+				},
+			}
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// normalizeTag lower-cases a tag and joins its words with hyphens.
+func normalizeTag(tag string) string {
+	fields := strings.FieldsFunc(strings.ToLower(tag), func(r rune) bool {
+		return r == ' ' || r == '_' || r == '\t'
+	})
+	return strings.Join(fields, "-")
+}
+
+// dedupe removes repeated values from list, keeping the first of each.
+func dedupe(list []string) []string {
+	seen := make(map[string]bool)
+	return slices.DeleteFunc(list, func(v string) bool {
+		if seen[v] {
+			return true
+		}
+		seen[v] = true
+		return false
+	})
+}
+
+func checkArtifactType(c *Context, f *store.File) []Issue {
+	if f.Artifact == nil {
+		return nil
+	}
+	if err := c.Config.CheckType(f.Artifact.Type); err != nil {
+		return []Issue{issuef("%v", err)}
+	}
+	return nil
+}
+
+func checkArtifactStatus(c *Context, f *store.File) []Issue {
+	if f.Artifact == nil {
+		return nil
+	}
+	if err := c.Config.CheckStatus(f.Artifact.Type, f.Artifact.Status); err != nil {
+		return []Issue{issuef("%v", err)}
+	}
+	return nil
+}
+
+func checkRequiredSections(c *Context, f *store.File) []Issue {
+	s := f.Session
+	if s == nil || s.Template == "" {
+		return nil
+	}
+	t, err := templates.LoadSession(c.SessionsDir, s.Template)
+	if err != nil {
+		return []Issue{issuef("%v", err)}
+	}
+	var issues []Issue
+	for _, u := range t.Unfilled(s.Body, templates.SessionData(s)) {
+		issues = append(issues, issuef("required section %q is %s", u.Section, u.Reason))
+	}
+	return issues
+}
+
+func checkPaths(c *Context, f *store.File) []Issue {
+	if f.Session == nil {
+		return nil
+	}
+	exists := func(p string) bool {
+		_, err := os.Stat(filepath.Join(c.WorkTree, filepath.FromSlash(p)))
+		return err == nil
+	}
+	var issues []Issue
+	for i, fc := range f.Session.FilesChanged {
+		if fc.Action == "deleted" || strings.TrimSpace(fc.Path) == "" || exists(fc.Path) {
+			continue
+		}
+		cur := store.FollowRenames(c.Renames, fc.Path)
+		switch {
+		case cur == fc.Path:
+			issues = append(issues, issuef("files_changed[%d] (%s) no longer exists", i, fc.Path))
+		case !exists(cur):
+			issues = append(issues, issuef("files_changed[%d] (%s) was renamed to %s, which no longer exists", i, fc.Path, cur))
+		}
+	}
+	return issues
+}
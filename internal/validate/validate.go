@@ -0,0 +1,220 @@
+// Package validate checks the sessions and artifacts of a store against a
+// set of rules, reporting issues by severity and repairing those that can be
+// fixed mechanically.
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/glopal/sessions/internal/config"
+	"github.com/glopal/sessions/pkg/store"
+)
+
+// Severity is how serious an issue is. Errors fail validation; warnings
+// are reported but do not.
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+)
+
+// Off disables a rule when given as its severity in config.yaml.
+const Off = "off"
+
+// Issue is a problem a rule found with a session or artifact.
+type Issue struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Key      string   `json:"key"`
+	Message  string   `json:"message"`
+	// Fix repairs the issue, if it can be repaired mechanically.
+	Fix *Fix `json:"fix,omitempty"`
+}
+
+// Fix is a mechanical repair of an issue: a change to one session.
+type Fix struct {
+	Description string `json:"description"`
+	// Key is the session the fix changes, which need not be the one the
+	// issue was reported on.
+	Key string `json:"key"`
+	// Apply makes the change to a fresh copy of the session read from disk.
+	Apply func(*store.Session) `json:"-"`
+}
+
+// Rule is a check run against every file of a store that is not archived.
+type Rule struct {
+	Name        string
+	Description string
+	// Severity is the severity of the rule's issues unless config.yaml sets
+	// another.
+	Severity Severity
+	// Hint tells how to resolve the rule's issues by hand.
+	Hint string
+	// OptIn rules only run when named in Options.Enable.
+	OptIn bool
+	// Check reports the issues with f. Their rule and severity are filled in
+	// by Run, as is their key if empty.
+	Check func(c *Context, f *store.File) []Issue
+}
+
+// Options are the optional inputs to Run.
+type Options struct {
+	// Enable names the opt-in rules to run.
+	Enable []string
+	// WorkTree is the directory the paths in files_changed are relative to.
+	WorkTree string
+	// Renames maps old paths to the paths they were renamed to, as recorded
+	// in .sessions/paths.yaml.
+	Renames map[string]string
+}
+
+// Context is the store under validation, as seen by rules.
+type Context struct {
+	Config      *config.Config
+	SessionsDir string
+	// Files are all the store's files, archived or not, in path order.
+	Files []store.File
+	Options
+
+	sessions  map[string]*store.File
+	artifacts map[string]*store.File
+}
+
+// Session returns the file of the session with the given key.
+func (c *Context) Session(key string) (*store.File, bool) {
+	f, ok := c.sessions[key]
+	return f, ok
+}
+
+// Artifact returns the file of the artifact with the given key.
+func (c *Context) Artifact(key string) (*store.File, bool) {
+	f, ok := c.artifacts[key]
+	return f, ok
+}
+
+// Lookup returns the rule named name.
+func Lookup(name string) (*Rule, bool) {
+	for i := range Rules {
+		if Rules[i].Name == name {
+			return &Rules[i], true
+		}
+	}
+	return nil, false
+}
+
+// Run checks files against Rules, with severities adjusted by cfg, and
+// returns the issues found ordered by rule and then by file. Opt-in rules
+// run only if opts enables them.
+func Run(cfg *config.Config, sessionsDir string, files []store.File, opts Options) ([]Issue, error) {
+	for name := range cfg.Validation.Rules {
+		if _, ok := Lookup(name); !ok {
+			return nil, fmt.Errorf("%s: validation: unknown rule %q", config.FileName, name)
+		}
+	}
+	for _, name := range opts.Enable {
+		if r, ok := Lookup(name); !ok || !r.OptIn {
+			return nil, fmt.Errorf("%q is not an opt-in rule", name)
+		}
+	}
+	c := &Context{
+		Config:      cfg,
+		SessionsDir: sessionsDir,
+		Files:       files,
+		Options:     opts,
+		sessions:    make(map[string]*store.File),
+		artifacts:   make(map[string]*store.File),
+	}
+	for i := range files {
+		// A file both live and archived is seen as the live one.
+		f, byKey := &files[i], c.sessions
+		if strings.Contains(f.Key, "/") {
+			byKey = c.artifacts
+		}
+		if cur, ok := byKey[f.Key]; !ok || cur.Archived {
+			byKey[f.Key] = f
+		}
+	}
+
+	var issues []Issue
+	for _, rule := range Rules {
+		if rule.OptIn && !slices.Contains(opts.Enable, rule.Name) {
+			continue
+		}
+		severity := rule.Severity
+		if s, ok := cfg.Validation.Rules[rule.Name]; ok {
+			if s == Off {
+				continue
+			}
+			severity = Severity(s)
+		}
+		for i := range files {
+			if files[i].Archived {
+				continue
+			}
+			for _, issue := range rule.Check(c, &files[i]) {
+				issue.Rule, issue.Severity = rule.Name, severity
+				if issue.Key == "" {
+					issue.Key = files[i].Key
+				}
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues, nil
+}
+
+// Count returns the number of errors and warnings among issues.
+func Count(issues []Issue) (errs, warnings int) {
+	for _, issue := range issues {
+		if issue.Severity == Error {
+			errs++
+		} else {
+			warnings++
+		}
+	}
+	return errs, warnings
+}
+
+// Apply applies the fixes of issues to st, one write per session. It returns
+// the issues fixed, and an error joining the failures to write.
+func Apply(st *store.Store, issues []Issue) ([]Issue, error) {
+	var keys []string
+	byKey := make(map[string][]Issue)
+	for _, issue := range issues {
+		if issue.Fix == nil {
+			continue
+		}
+		if _, ok := byKey[issue.Fix.Key]; !ok {
+			keys = append(keys, issue.Fix.Key)
+		}
+		byKey[issue.Fix.Key] = append(byKey[issue.Fix.Key], issue)
+	}
+
+	var fixed []Issue
+	var errs []error
+	for _, key := range keys {
+		err := st.UpdateSession(key, func(s *store.Session) error {
+			for _, issue := range byKey[key] {
+				issue.Fix.Apply(s)
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("fixing %s: %w", key, err))
+			continue
+		}
+		fixed = append(fixed, byKey[key]...)
+	}
+	return fixed, errors.Join(errs...)
+}
+
+// Filter returns the issues reported on one of keys.
+func Filter(issues []Issue, keys []string) []Issue {
+	return slices.DeleteFunc(slices.Clone(issues), func(issue Issue) bool {
+		return !slices.Contains(keys, issue.Key)
+	})
+}
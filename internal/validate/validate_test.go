@@ -0,0 +1,289 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/glopal/sessions/internal/config"
+	"github.com/glopal/sessions/pkg/store"
+)
+
+// newTestStore opens a store over .sessions/ files written as given, keyed
+// by path relative to .sessions/.
+func newTestStore(t *testing.T, files map[string]string) *store.Store {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), ".sessions")
+	for _, sub := range []string{"sessions", "artifacts"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for rel, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st, err := store.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func run(t *testing.T, st *store.Store, cfg *config.Config) []Issue {
+	t.Helper()
+	files, err := st.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err := Run(cfg, st.Dir(), files, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return issues
+}
+
+// describe formats issues as "rule key: message", marking fixable ones.
+func describe(issues []Issue) []string {
+	var out []string
+	for _, issue := range issues {
+		s := issue.Rule + " " + issue.Key + ": " + issue.Message
+		if issue.Fix != nil {
+			s += " [fix]"
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+const (
+	first = `---
+timestamp: 2026-02-24T02:26:40Z
+session_id: "1771900000"
+summary: Split the CSV loader
+tags: [CSV Loader, parsing, parsing]
+files_changed:
+  - path: loader.go
+    action: Modified
+    summary: Split
+  - path: old.go
+    action: shredded
+    summary: Gone
+artifacts:
+  - path: adr.md
+    type: decision
+    summary: Old summary
+  - path: gone.md
+    type: analysis
+    summary: Deleted
+related_sessions: ["1771900100", "1771999999"]
+---
+Body.
+`
+	second = `---
+timestamp: 2026-02-24T02:15:00Z
+session_id: "1771900001"
+summary: ""
+tags: []
+files_changed: []
+artifacts: []
+related_sessions: []
+---
+`
+	adr = `---
+title: ADR
+type: decision
+summary: Split the loader
+status: draft
+supersedes: ""
+---
+Body.
+`
+	notes = `---
+title: Notes
+type: notes
+summary: Loose notes
+status: pending
+supersedes: ""
+---
+Body.
+`
+)
+
+func TestRun(t *testing.T) {
+	st := newTestStore(t, map[string]string{
+		"sessions/2026-02/1771900000.md":            first,
+		"sessions/2026-02/1771900100.md":            second,
+		"sessions/2026-02/1771900200.md":            "---\nsummary: [unclosed\n---\n",
+		"artifacts/2026-02/1771900000/adr.md":       adr,
+		"artifacts/2026-02/1771900000/notes.md":     notes,
+		"artifacts/2026-02/1771999998/orphan.md":    adr,
+		"archive/sessions/2026-02/1771900300.md":    second,
+		"archive/artifacts/2026-02/1771900300/x.md": notes,
+	})
+	cfg := config.Default()
+
+	got := describe(run(t, st, cfg))
+	want := []string{
+		`parse 1771900200: cannot be parsed: parsing frontmatter YAML: yaml: line 1: did not find expected ',' or ']'`,
+		`summary 1771900100: summary is missing`,
+		`session-id 1771900100: session_id "1771900001" does not match file name 1771900100.md [fix]`,
+		`session-id 1771900100: timestamp 2026-02-24T02:15:00Z does not match the creation time in file name 1771900100.md`,
+		`files-changed 1771900000: files_changed[0] (loader.go) has invalid action "Modified" [fix]`,
+		`files-changed 1771900000: files_changed[1] (old.go) has invalid action "shredded"`,
+		`artifact-refs 1771900000/notes.md: is not listed in the artifacts of session 1771900000 [fix]`,
+		`artifact-refs 1771999998/orphan.md: belongs to session 1771999998, which does not exist`,
+		`artifact-refs 1771900000: the type and summary artifacts lists for adr.md are out of date [fix]`,
+		`artifact-refs 1771900000: artifacts lists gone.md, which does not exist [fix]`,
+		`related-sessions 1771900000: related session 1771900100 does not list it back [fix]`,
+		`related-sessions 1771900000: related session 1771999999 does not exist [fix]`,
+		`tags 1771900000: tag "CSV Loader" does not match ^[a-z0-9][a-z0-9._/-]*$ [fix]`,
+		`tags 1771900000: tag "parsing" is listed more than once [fix]`,
+		`artifact-type 1771900000/notes.md: invalid type "notes" (expected decision, analysis, investigation, architecture, debug-log)`,
+		`artifact-status 1771900000/notes.md: invalid status "pending" (expected draft, accepted, superseded, deprecated)`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("issues:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	cfg.Validation.Rules = map[string]string{"tags": Off, "related-sessions": string(Error)}
+	for _, issue := range run(t, st, cfg) {
+		if issue.Rule == "tags" || issue.Rule == "related-sessions" && issue.Severity != Error {
+			t.Errorf("severity override ignored: %+v", issue)
+		}
+	}
+	cfg.Validation.Rules = map[string]string{"tagz": Off}
+	if _, err := Run(cfg, st.Dir(), nil, Options{}); err == nil {
+		t.Error("unknown rule in config accepted")
+	}
+}
+
+func TestApply(t *testing.T) {
+	st := newTestStore(t, map[string]string{
+		"sessions/2026-02/1771900000.md":        first,
+		"sessions/2026-02/1771900100.md":        second,
+		"artifacts/2026-02/1771900000/adr.md":   adr,
+		"artifacts/2026-02/1771900000/notes.md": notes,
+	})
+	cfg := config.Default()
+
+	issues := run(t, st, cfg)
+	fixed, err := Apply(st, issues)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixed) != 9 {
+		t.Errorf("fixed %d issues, want 9: %q", len(fixed), describe(fixed))
+	}
+
+	got := describe(run(t, st, cfg))
+	want := []string{
+		`summary 1771900100: summary is missing`,
+		`session-id 1771900100: timestamp 2026-02-24T02:15:00Z does not match the creation time in file name 1771900100.md`,
+		`files-changed 1771900000: files_changed[1] (old.go) has invalid action "shredded"`,
+		`artifact-type 1771900000/notes.md: invalid type "notes" (expected decision, analysis, investigation, architecture, debug-log)`,
+		`artifact-status 1771900000/notes.md: invalid status "pending" (expected draft, accepted, superseded, deprecated)`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("issues after fixing:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	s, err := st.Get("1771900000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(s.Tags, []string{"csv-loader", "parsing"}) {
+		t.Errorf("tags = %q", s.Tags)
+	}
+	if !slices.Equal(s.RelatedSessions, []string{"1771900100"}) {
+		t.Errorf("related_sessions = %q", s.RelatedSessions)
+	}
+	var refs []string
+	for _, ref := range s.Artifacts {
+		refs = append(refs, ref.Path+" "+ref.Summary)
+	}
+	if !slices.Equal(refs, []string{"adr.md Split the loader", "notes.md Loose notes"}) {
+		t.Errorf("artifacts = %q", refs)
+	}
+}
+
+func TestPaths(t *testing.T) {
+	st := newTestStore(t, map[string]string{
+		"sessions/2026-02/1771900000.md": `---
+timestamp: 2026-02-24T02:26:40Z
+session_id: "1771900000"
+summary: Move the loader
+files_changed:
+  - path: main.go
+    action: modified
+    summary: Wire
+  - path: lib/loader.go
+    action: modified
+    summary: Split
+  - path: lib/reader.go
+    action: modified
+    summary: Split
+  - path: old.go
+    action: deleted
+    summary: Drop
+  - path: gone.go
+    action: added
+    summary: Try
+---
+`,
+	})
+	workTree := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workTree, "main.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(workTree, "csv"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workTree, "csv", "loader.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := st.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+
+	issues, err := Run(cfg, st.Dir(), files, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, issue := range issues {
+		if issue.Rule == "paths" {
+			t.Errorf("opt-in rule ran unasked: %+v", issue)
+		}
+	}
+
+	opts := Options{
+		Enable:   []string{"paths"},
+		WorkTree: workTree,
+		Renames:  map[string]string{"lib/loader.go": "csv/loader.go", "lib/reader.go": "csv/reader.go"},
+	}
+	issues, err = Run(cfg, st.Dir(), files, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := describe(issues)
+	want := []string{
+		`paths 1771900000: files_changed[2] (lib/reader.go) was renamed to csv/reader.go, which no longer exists`,
+		`paths 1771900000: files_changed[4] (gone.go) no longer exists`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("issues:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if _, err := Run(cfg, st.Dir(), files, Options{Enable: []string{"tags"}}); err == nil {
+		t.Error("enabling a rule that is not opt-in accepted")
+	}
+}
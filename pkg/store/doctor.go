@@ -0,0 +1,264 @@
+package store
+
+import (
+	"cmp"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/glopal/sessions/internal/parser"
+	"github.com/glopal/sessions/internal/session"
+)
+
+// ProblemKind classifies the problems Doctor finds.
+type ProblemKind string
+
+const (
+	// UnlistedArtifact is an artifact file its session's artifacts list
+	// does not mention. Repaired by adding it to the list.
+	UnlistedArtifact ProblemKind = "unlisted-artifact"
+	// MissingArtifact is an entry in a session's artifacts list whose file
+	// does not exist. Repaired by dropping the entry.
+	MissingArtifact ProblemKind = "missing-artifact"
+	// DanglingRelated is an entry in a session's related_sessions naming a
+	// session that does not exist. Repaired by dropping the entry.
+	DanglingRelated ProblemKind = "dangling-related"
+	// OrphanedArtifactDir is a directory of artifacts whose session does not
+	// exist. It is only reported, as its artifacts may still be wanted.
+	OrphanedArtifactDir ProblemKind = "orphaned-artifact-dir"
+	// EmptyDir is a directory below sessions/ or artifacts/ with no files
+	// in it. Repaired by removing it.
+	EmptyDir ProblemKind = "empty-dir"
+)
+
+// ProblemKinds are the kinds of problem Doctor reports, in report order.
+var ProblemKinds = []ProblemKind{UnlistedArtifact, MissingArtifact, DanglingRelated, OrphanedArtifactDir, EmptyDir}
+
+// Problem is a break in the store's referential integrity.
+type Problem struct {
+	Kind ProblemKind `json:"kind"`
+	// Key is the session or artifact the problem is with.
+	Key string `json:"key,omitempty"`
+	// Path is the file or directory concerned, as a slash-separated path
+	// relative to the .sessions/ directory.
+	Path   string `json:"path"`
+	Detail string `json:"detail"`
+	// Repairable reports whether Doctor can repair the problem, and
+	// Repaired whether it did.
+	Repairable bool `json:"repairable"`
+	Repaired   bool `json:"repaired"`
+}
+
+// Doctor checks the referential integrity of the active store: that every
+// artifact file is listed by its session and every listed artifact exists,
+// that related_sessions name existing sessions, and that no artifact
+// directory outlives its session. Archived sessions and artifacts count as
+// existing. With repair set, unlisted artifacts are registered from their
+// frontmatter, dead references dropped and empty directories removed.
+// Failures to repair a session are reported through Warn. Problems are
+// returned ordered by kind, then path.
+func (s *Store) Doctor(repair bool) ([]Problem, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ix, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	d := &doctor{s: s, active: make(map[string]*Session), archived: make(map[string]bool), fixes: make(map[string][]func(*Session))}
+	for _, sess := range ix.Sessions() {
+		d.active[sess.SessionID] = sess
+	}
+	for _, sess := range ix.ArchivedSessions() {
+		d.archived[sess.SessionID] = true
+	}
+	for id, sess := range d.active {
+		d.checkSession(id, sess)
+	}
+	if err := d.checkTree(); err != nil {
+		return nil, &Error{Op: "checking store", Err: err}
+	}
+
+	problems := d.problems
+	slices.SortFunc(problems, func(a, b Problem) int {
+		if a.Kind != b.Kind {
+			return slices.Index(ProblemKinds, a.Kind) - slices.Index(ProblemKinds, b.Kind)
+		}
+		return cmp.Or(strings.Compare(a.Path, b.Path), strings.Compare(a.Detail, b.Detail))
+	})
+	if !repair {
+		return problems, nil
+	}
+
+	p := &changePlan{}
+	for id, fixes := range d.fixes {
+		path, err := s.path(session.FormatSessionKey(id))
+		if err != nil {
+			continue
+		}
+		p.edits = append(p.edits, refEdit{key: id, path: path, session: func(sess *Session) {
+			for _, fix := range fixes {
+				fix(sess)
+			}
+		}})
+	}
+	slices.SortFunc(p.edits, func(a, b refEdit) int { return strings.Compare(a.key, b.key) })
+	if err := s.apply("repairing", p); err != nil {
+		return nil, err
+	}
+	for i := range problems {
+		if problems[i].Kind == EmptyDir {
+			if err := os.RemoveAll(filepath.Join(s.dir, filepath.FromSlash(problems[i].Path))); err != nil {
+				s.warn(fmt.Errorf("removing %s: %w", problems[i].Path, err))
+				continue
+			}
+		}
+		problems[i].Repaired = problems[i].Repairable
+	}
+	return problems, nil
+}
+
+// doctor collects the problems Doctor finds and the session edits that
+// repair them.
+type doctor struct {
+	s        *Store
+	active   map[string]*Session
+	archived map[string]bool
+	problems []Problem
+	fixes    map[string][]func(*Session)
+}
+
+// exists reports whether session id exists, archived or not.
+func (d *doctor) exists(id string) bool {
+	_, ok := d.active[id]
+	return ok || d.archived[id]
+}
+
+// addFix records a problem repaired by applying fix to session id.
+func (d *doctor) addFix(p Problem, id string, fix func(*Session)) {
+	p.Repairable = true
+	d.problems = append(d.problems, p)
+	d.fixes[id] = append(d.fixes[id], fix)
+}
+
+// checkSession checks that the artifacts and related sessions a session
+// lists exist.
+func (d *doctor) checkSession(id string, sess *Session) {
+	for _, ref := range sess.Artifacts {
+		name := ref.Path
+		key := session.FormatArtifactKey(id, name)
+		path := session.ResolveKeyToPath(d.s.dir, key)
+		if fileExists(path) || fileExists(d.s.archivePath(path)) {
+			continue
+		}
+		d.addFix(Problem{Kind: MissingArtifact, Key: key, Path: d.s.relPath(path), Detail: "listed by session " + id + " but missing"}, id, func(sess *Session) {
+			sess.Artifacts = slices.DeleteFunc(sess.Artifacts, func(ref ArtifactRef) bool { return ref.Path == name })
+		})
+	}
+	for _, related := range sess.RelatedSessions {
+		if related == id || d.exists(related) {
+			continue
+		}
+		path := session.ResolveSessionPath(d.s.dir, id)
+		d.addFix(Problem{Kind: DanglingRelated, Key: id, Path: d.s.relPath(path), Detail: "related session " + related + " does not exist"}, id, func(sess *Session) {
+			sess.RelatedSessions = slices.DeleteFunc(sess.RelatedSessions, func(r string) bool { return r == related })
+		})
+	}
+}
+
+// checkTree looks for empty directories below sessions/ and artifacts/, and
+// checks each artifact directory against its session.
+func (d *doctor) checkTree() error {
+	dirs, files, err := d.s.scanTree()
+	if err != nil {
+		return err
+	}
+	var empty []string
+	for _, dir := range dirs {
+		if !slices.ContainsFunc(files, func(f string) bool { return strings.HasPrefix(f, dir+string(filepath.Separator)) }) {
+			// Only the topmost of nested empty directories is reported.
+			if !slices.Contains(empty, filepath.Dir(dir)) {
+				d.problems = append(d.problems, Problem{Kind: EmptyDir, Path: d.s.relPath(dir), Detail: "no files", Repairable: true})
+			}
+			empty = append(empty, dir)
+			continue
+		}
+		if parts := strings.Split(d.s.relPath(dir), "/"); len(parts) == 3 && parts[0] == "artifacts" {
+			d.checkArtifactDir(dir, parts[2])
+		}
+	}
+	return nil
+}
+
+// checkArtifactDir checks the artifact directory of session id: the session
+// must exist and list each artifact in it.
+func (d *doctor) checkArtifactDir(dir, id string) {
+	sess, ok := d.active[id]
+	if !ok {
+		detail := "session " + id + " does not exist"
+		if d.archived[id] {
+			detail = "session " + id + " is archived"
+		}
+		d.problems = append(d.problems, Problem{Kind: OrphanedArtifactDir, Key: id, Path: d.s.relPath(dir), Detail: detail})
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".md") || slices.ContainsFunc(sess.Artifacts, func(ref ArtifactRef) bool { return ref.Path == name }) {
+			continue
+		}
+		key := session.FormatArtifactKey(id, name)
+		path := filepath.Join(dir, name)
+		p := Problem{Kind: UnlistedArtifact, Key: key, Path: d.s.relPath(path), Detail: "not listed by session " + id}
+		a, err := parser.ParseArtifactFile(path)
+		if err != nil {
+			p.Detail += "; cannot be registered: " + err.Error()
+			d.problems = append(d.problems, p)
+			continue
+		}
+		ref := ArtifactRef{Path: name, Type: a.Type, Summary: a.Summary}
+		d.addFix(p, id, func(sess *Session) {
+			if !slices.ContainsFunc(sess.Artifacts, func(r ArtifactRef) bool { return r.Path == name }) {
+				sess.Artifacts = append(sess.Artifacts, ref)
+			}
+		})
+	}
+}
+
+// scanTree returns the directories below sessions/ and artifacts/, parents
+// before children, and the files in them.
+func (s *Store) scanTree() (dirs, files []string, err error) {
+	for _, top := range []string{"sessions", "artifacts"} {
+		root := filepath.Join(s.dir, top)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if path == root && os.IsNotExist(err) {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			switch {
+			case path == root:
+			case d.IsDir():
+				dirs = append(dirs, path)
+			default:
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return dirs, files, nil
+}
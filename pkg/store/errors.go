@@ -0,0 +1,45 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds. Use errors.Is to test which kind a store error is.
+var (
+	// ErrNotFound means the session, artifact or store does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrExists means a create would overwrite an existing session or artifact.
+	ErrExists = errors.New("already exists")
+	// ErrInvalid means the caller supplied an invalid key, field or query.
+	ErrInvalid = errors.New("invalid")
+)
+
+// Error describes a failed store operation.
+type Error struct {
+	Op   string // operation, e.g. "get", "create artifact"
+	Key  string // session or artifact key, if any
+	Kind error  // ErrNotFound, ErrExists, ErrInvalid, or nil for I/O and parse failures
+	Err  error  // underlying cause
+}
+
+func (e *Error) Error() string {
+	if e.Op == "" {
+		return e.Err.Error()
+	}
+	if e.Key == "" {
+		return fmt.Sprintf("%s: %v", e.Op, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Key, e.Err)
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+func newError(op, key string, kind error, format string, args ...any) *Error {
+	return &Error{Op: op, Key: key, Kind: kind, Err: fmt.Errorf(format, args...)}
+}
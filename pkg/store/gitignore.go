@@ -0,0 +1,44 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/glopal/sessions/internal/index"
+)
+
+// EnsureGitignored adds the index and lock files to the .gitignore of the
+// store at sessionsDir, so that local state is never committed alongside
+// the sessions it describes.
+func EnsureGitignored(sessionsDir string) error {
+	path := filepath.Join(sessionsDir, ".gitignore")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading .gitignore: %w", err)
+	}
+	content := string(data)
+	present := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		present[strings.TrimSpace(line)] = true
+	}
+	missing := false
+	for _, name := range []string{index.FileName, LockFileName} {
+		if present[name] {
+			continue
+		}
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += name + "\n"
+		missing = true
+	}
+	if !missing {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing .gitignore: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/glopal/sessions/internal/fsutil"
+	"gopkg.in/yaml.v3"
+)
+
+// PathsFileName is the file in .sessions/ recording renames of changed files
+// that no session records, so that history can be found under a file's
+// current name.
+const PathsFileName = "paths.yaml"
+
+const pathsHeader = `# Renames of files recorded in files_changed, old path: current path.
+# Written by 'sessions paths migrate' from git history; 'sessions context'
+# and 'sessions query --file' follow them to find a file's earlier sessions.
+`
+
+type pathsFile struct {
+	Renames map[string]string `yaml:"renames"`
+}
+
+// Renames returns the recorded renames, mapping old paths to the paths they
+// were renamed to. It returns an empty map if none are recorded.
+func (s *Store) Renames() (map[string]string, error) {
+	renames, err := s.readRenames()
+	if err != nil {
+		return nil, &Error{Op: "reading " + PathsFileName, Err: err}
+	}
+	return renames, nil
+}
+
+func (s *Store) readRenames() (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, PathsFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]string), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f pathsFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if f.Renames == nil {
+		f.Renames = make(map[string]string)
+	}
+	return f.Renames, nil
+}
+
+// AddRenames records renames from old paths to new ones, replacing any
+// recorded for the same old paths. Renames of a path to itself are ignored.
+func (s *Store) AddRenames(renames map[string]string) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	recorded, err := s.readRenames()
+	if err != nil {
+		return &Error{Op: "reading " + PathsFileName, Err: err}
+	}
+	for old, cur := range renames {
+		if old != cur && old != "" && cur != "" {
+			recorded[old] = cur
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(pathsHeader)
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(pathsFile{Renames: recorded}); err != nil {
+		return &Error{Op: "writing " + PathsFileName, Err: err}
+	}
+	if err := fsutil.WriteFileAtomic(filepath.Join(s.dir, PathsFileName), buf.Bytes(), 0644); err != nil {
+		return &Error{Op: "writing " + PathsFileName, Err: err}
+	}
+	return nil
+}
+
+// FollowRenames returns the path p was last renamed to by renames, following
+// chains of renames, or p itself if it was not renamed.
+func FollowRenames(renames map[string]string, p string) string {
+	seen := map[string]bool{p: true}
+	for {
+		next, ok := renames[p]
+		if !ok || seen[next] {
+			return p
+		}
+		seen[next] = true
+		p = next
+	}
+}
+
+// RenamedFrom inverts renames, mapping each path to the paths renamed to it,
+// sorted.
+func RenamedFrom(renames map[string]string) map[string][]string {
+	from := make(map[string][]string)
+	for _, old := range slices.Sorted(maps.Keys(renames)) {
+		from[renames[old]] = append(from[renames[old]], old)
+	}
+	return from
+}
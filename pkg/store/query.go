@@ -0,0 +1,348 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/glopal/sessions/internal/query"
+	"github.com/glopal/sessions/internal/search"
+	"github.com/glopal/sessions/internal/session"
+	"github.com/gobwas/glob"
+)
+
+// Hit is a full-text search hit on a session or artifact.
+type Hit = search.Hit
+
+// QueryError is a syntax error in a query expression, with the column it occurred at.
+type QueryError = query.Error
+
+// Query holds the filters for a session query. All set filters must match.
+type Query struct {
+	File         string // changed file path, exact or glob
+	Tag          string // session tag
+	ArtifactType string // type of an attached artifact
+	After        string // on or after a date (YYYY-MM-DD)
+	Before       string // on or before a date (YYYY-MM-DD)
+	Search       string // full-text search ("phrases", prefix*)
+	Commit       string // full or abbreviated hash of a commit recorded on the session
+	Branch       string // git branch the session was recorded on
+	Expr         string // boolean query expression
+
+	IncludeArchived bool // also match archived sessions
+}
+
+// Match is a session matched by a query, with the parts that matched.
+type Match struct {
+	Session          *Session
+	MatchedFiles     []string
+	MatchedTags      []string
+	MatchedArtifacts []string
+}
+
+// Field weights for ranked search. Short, curated fields outweigh long bodies.
+const (
+	weightTitle   = 3.0
+	weightSummary = 2.0
+	weightTags    = 2.0
+	weightFiles   = 1.5
+	weightBody    = 1.0
+)
+
+// Query returns the sessions matching q, most recent first. When q.Search is
+// set it also returns the full-text hits within those sessions, best first.
+// Malformed expressions, dates and searches fail with ErrInvalid; expression
+// errors wrap a *QueryError.
+func (s *Store) Query(q Query) ([]*Match, []Hit, error) {
+	var expr query.Node
+	if q.Expr != "" {
+		var err error
+		expr, err = query.Parse(q.Expr)
+		if err != nil {
+			var qe *QueryError
+			if errors.As(err, &qe) {
+				err = fmt.Errorf("%w\n%s", qe, qe.Caret())
+			}
+			return nil, nil, &Error{Op: "invalid query", Kind: ErrInvalid, Err: err}
+		}
+	}
+	for _, d := range []string{q.After, q.Before} {
+		if d != "" {
+			if _, err := ParseDate(d); err != nil {
+				return nil, nil, &Error{Kind: ErrInvalid, Err: err}
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ix, err := s.index()
+	if err != nil {
+		return nil, nil, err
+	}
+	s.warnFailures(ix)
+	sessions := ix.Sessions()
+	if q.IncludeArchived {
+		sessions = ix.AllSessions()
+	}
+
+	var hits []Hit
+	var hitSessions map[string]bool
+	if q.Search != "" {
+		hits, err = s.search(sessions, q.Search)
+		if err != nil {
+			return nil, nil, err
+		}
+		hitSessions = make(map[string]bool)
+		for _, h := range hits {
+			hitSessions[h.SessionID] = true
+		}
+	}
+
+	var renames map[string]string
+	if q.File != "" || expr != nil {
+		if renames, err = s.readRenames(); err != nil {
+			s.warn(&Error{Op: "reading " + PathsFileName, Err: err})
+		}
+	}
+
+	var results []*Match
+	matched := make(map[string]bool)
+	for _, sess := range sessions {
+		if hitSessions != nil && !hitSessions[sess.SessionID] {
+			continue
+		}
+		r := matchSession(sess, q, renames)
+		if r != nil && expr != nil {
+			r = s.matchExpr(expr, r, renames)
+		}
+		if r != nil {
+			results = append(results, r)
+			matched[sess.SessionID] = true
+		}
+	}
+
+	var ranked []Hit
+	for _, h := range hits {
+		if matched[h.SessionID] {
+			ranked = append(ranked, h)
+		}
+	}
+	return results, ranked, nil
+}
+
+// Search runs a full-text search over every session and artifact, returning
+// hits best first. A malformed search fails with ErrInvalid.
+func (s *Store) Search(q string) ([]Hit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ix, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	return s.search(ix.Sessions(), q)
+}
+
+// search indexes the given sessions and all of their artifacts and runs a
+// full-text search. The caller must hold s.mu.
+func (s *Store) search(sessions []*Session, q string) ([]Hit, error) {
+	ix := search.New()
+	for _, sess := range sessions {
+		ix.Add(sessionDocument(sess))
+		for _, ref := range sess.Artifacts {
+			key := session.FormatArtifactKey(sess.SessionID, ref.Path)
+			path, err := s.path(key)
+			if err != nil {
+				continue
+			}
+			a, err := s.loadArtifact(key, path)
+			if err != nil {
+				continue
+			}
+			ix.Add(artifactDocument(sess.SessionID, ref.Path, a))
+		}
+	}
+	hits, err := ix.Search(q)
+	if err != nil {
+		return nil, &Error{Op: "invalid search", Kind: ErrInvalid, Err: err}
+	}
+	return hits, nil
+}
+
+func sessionDocument(s *Session) search.Document {
+	var files strings.Builder
+	for _, f := range s.FilesChanged {
+		fmt.Fprintf(&files, "%s: %s\n", f.Path, f.Summary)
+	}
+	return search.Document{
+		Key:       session.FormatSessionKey(s.SessionID),
+		SessionID: s.SessionID,
+		Title:     s.Summary,
+		Fields: []search.Field{
+			{Name: "summary", Text: s.Summary, Weight: weightSummary},
+			{Name: "tags", Text: strings.Join(s.Tags, " "), Weight: weightTags},
+			{Name: "files", Text: files.String(), Weight: weightFiles},
+			{Name: "body", Text: s.Body, Weight: weightBody},
+		},
+	}
+}
+
+func artifactDocument(sessionID, name string, a *Artifact) search.Document {
+	return search.Document{
+		Key:       session.FormatArtifactKey(sessionID, name),
+		SessionID: sessionID,
+		Title:     a.Title,
+		Fields: []search.Field{
+			{Name: "title", Text: a.Title, Weight: weightTitle},
+			{Name: "summary", Text: a.Summary, Weight: weightSummary},
+			{Name: "body", Text: a.Body, Weight: weightBody},
+		},
+	}
+}
+
+// matchSession matches a session against q's filters. The file filter also
+// matches files under the names renames says they have now.
+func matchSession(s *Session, q Query, renames map[string]string) *Match {
+	r := &Match{Session: s}
+	matched := true
+
+	// File filter
+	if q.File != "" {
+		matched = false
+		g, err := glob.Compile(q.File)
+		if err != nil {
+			// Fall back to exact match
+			for _, f := range s.FilesChanged {
+				if f.Path == q.File || FollowRenames(renames, f.Path) == q.File {
+					r.MatchedFiles = append(r.MatchedFiles, f.Path)
+					matched = true
+				}
+			}
+		} else {
+			for _, f := range s.FilesChanged {
+				if g.Match(f.Path) || g.Match(FollowRenames(renames, f.Path)) {
+					r.MatchedFiles = append(r.MatchedFiles, f.Path)
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			return nil
+		}
+	}
+
+	// Tag filter
+	if q.Tag != "" {
+		found := false
+		for _, t := range s.Tags {
+			if t == q.Tag {
+				r.MatchedTags = append(r.MatchedTags, t)
+				found = true
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+
+	// Artifact type filter
+	if q.ArtifactType != "" {
+		found := false
+		for _, a := range s.Artifacts {
+			if a.Type == q.ArtifactType {
+				r.MatchedArtifacts = append(r.MatchedArtifacts, a.Path)
+				found = true
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+
+	// Git filters
+	if q.Commit != "" && !s.Git.HasCommit(q.Commit) {
+		return nil
+	}
+	if q.Branch != "" && (s.Git == nil || s.Git.Branch != q.Branch) {
+		return nil
+	}
+
+	// Date filters
+	if q.After != "" {
+		afterDate, err := ParseDate(q.After)
+		if err == nil && s.Timestamp.Before(afterDate) {
+			return nil
+		}
+	}
+
+	if q.Before != "" {
+		beforeDate, err := ParseDate(q.Before)
+		if err == nil {
+			// Add a day to make "before" inclusive of the date
+			endOfDay := beforeDate.AddDate(0, 0, 1)
+			if s.Timestamp.After(endOfDay) || s.Timestamp.Equal(endOfDay) {
+				return nil
+			}
+		}
+	}
+
+	return r
+}
+
+// matchExpr applies a query expression to a result already matched by the
+// flag filters, merging the expression's hits into it. Returns nil on no
+// match. file: terms follow renames. The caller must hold s.mu.
+func (s *Store) matchExpr(expr query.Node, r *Match, renames map[string]string) *Match {
+	sub := &query.Subject{
+		Session: r.Session,
+		Artifact: func(ref ArtifactRef) *Artifact {
+			key := session.FormatArtifactKey(r.Session.SessionID, ref.Path)
+			path, err := s.path(key)
+			if err != nil {
+				return nil
+			}
+			a, err := s.loadArtifact(key, path)
+			if err != nil {
+				return nil
+			}
+			return a
+		},
+		CurrentPath: func(path string) string { return FollowRenames(renames, path) },
+	}
+	ok, hits := query.Match(expr, sub)
+	if !ok {
+		return nil
+	}
+	r.MatchedFiles = appendMissing(r.MatchedFiles, hits.Files)
+	r.MatchedTags = appendMissing(r.MatchedTags, hits.Tags)
+	r.MatchedArtifacts = appendMissing(r.MatchedArtifacts, hits.Artifacts)
+	return r
+}
+
+// appendMissing appends the items of add that are not already in list.
+func appendMissing(list, add []string) []string {
+	for _, item := range add {
+		found := false
+		for _, existing := range list {
+			if existing == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// ParseDate parses a YYYY-MM-DD date as used by the After and Before filters.
+func ParseDate(dateStr string) (time.Time, error) {
+	t, err := time.Parse("2006-01-02", dateStr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date format %q (expected YYYY-MM-DD): %w", dateStr, err)
+	}
+	return t, nil
+}
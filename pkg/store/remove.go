@@ -0,0 +1,391 @@
+package store
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/glopal/sessions/internal/config"
+	"github.com/glopal/sessions/internal/index"
+	"github.com/glopal/sessions/internal/parser"
+	"github.com/glopal/sessions/internal/session"
+)
+
+// Change describes what Remove, Archive or Restore did, or in a dry run
+// would do.
+type Change struct {
+	// Key is the canonical key of the session or artifact.
+	Key string
+	// Files are the files deleted, or moved into or out of the archive, as
+	// slash-separated paths relative to the .sessions/ directory.
+	Files []string
+	// Updated are the keys of the other sessions and artifacts whose
+	// references to Key were removed or restored.
+	Updated []string
+}
+
+// changePlan is a Change together with the operations that carry it out.
+type changePlan struct {
+	Change
+	deletes    []string    // files to delete
+	removeDirs []string    // directories to delete with everything left in them
+	moves      [][2]string // files to move, from and to
+	edits      []refEdit   // reference updates, applied after the moves
+}
+
+// refEdit updates the references held by one session or artifact.
+type refEdit struct {
+	key, path string
+	session   func(*Session)
+	artifact  func(*Artifact)
+}
+
+// Remove deletes the session or artifact at key, archived or not. Removing a
+// session deletes its artifacts too. References to what was removed are
+// cleaned up across the store, archive included: other sessions'
+// related_sessions, the parent session's artifacts list, and supersedes and
+// superseded_by pointers. If dryRun is set nothing is changed.
+func (s *Store) Remove(key string, dryRun bool) (*Change, error) {
+	return s.change("removing", key, dryRun, s.planRemove)
+}
+
+// Archive moves the session or artifact at key into .sessions/archive/,
+// hiding it from List and Query. Archiving a session moves its artifacts
+// too. Links from other sessions to an archived session and the parent's
+// reference to an archived artifact are removed; Restore adds them back.
+// Archived artifacts stay valid supersedes targets. If dryRun is set nothing
+// is changed.
+func (s *Store) Archive(key string, dryRun bool) (*Change, error) {
+	return s.change("archiving", key, dryRun, s.planArchive)
+}
+
+// Restore moves an archived session or artifact back out of the archive and
+// repairs the references Archive removed. A restored session brings back the
+// artifacts it lists; an artifact can only be restored into an active
+// session. If dryRun is set nothing is changed.
+func (s *Store) Restore(key string, dryRun bool) (*Change, error) {
+	return s.change("restoring", key, dryRun, s.planRestore)
+}
+
+// change plans an operation on key under the store lock and applies it
+// unless dryRun is set.
+func (s *Store) change(op, key string, dryRun bool, plan func(ix *index.Index, key, path string) (*changePlan, error)) (*Change, error) {
+	key, path, err := s.resolvePath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if !fileExists(path) {
+		return nil, newError(op, key, ErrNotFound, "no such session or artifact")
+	}
+	ix, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	p, err := plan(ix, key, path)
+	if err != nil {
+		return nil, err
+	}
+	p.Key = key
+	for _, f := range p.deletes {
+		p.Files = append(p.Files, s.relPath(f))
+	}
+	for _, m := range p.moves {
+		p.Files = append(p.Files, s.relPath(m[0]))
+	}
+	for _, e := range p.edits {
+		p.Updated = append(p.Updated, e.key)
+	}
+	if !dryRun {
+		if err := s.apply(op, p); err != nil {
+			return nil, err
+		}
+	}
+	return &p.Change, nil
+}
+
+func (s *Store) planRemove(ix *index.Index, key, path string) (*changePlan, error) {
+	p := &changePlan{deletes: []string{path}}
+	sessionID, name, isArtifact := session.ParseKey(key)
+	if isArtifact {
+		if sessionPath, err := s.path(session.FormatSessionKey(sessionID)); err == nil && fileExists(sessionPath) {
+			p.edits = append(p.edits, refEdit{key: sessionID, path: sessionPath, session: func(sess *Session) {
+				sess.Artifacts = slices.DeleteFunc(sess.Artifacts, func(ref ArtifactRef) bool { return ref.Path == name })
+			}})
+		}
+		p.edits = append(p.edits, s.supersedesEdits(ix, func(target string) bool { return target == key })...)
+		return p, nil
+	}
+
+	for _, dir := range []string{session.ResolveArtifactDir(s.dir, sessionID), s.archivePath(session.ResolveArtifactDir(s.dir, sessionID))} {
+		files, err := filesUnder(dir)
+		if err != nil {
+			return nil, &Error{Op: "removing", Key: key, Err: err}
+		}
+		if files != nil {
+			p.deletes = append(p.deletes, files...)
+			p.removeDirs = append(p.removeDirs, dir)
+		}
+	}
+	p.edits = append(p.edits, s.relatedEdits(ix, sessionID)...)
+	p.edits = append(p.edits, s.supersedesEdits(ix, func(target string) bool {
+		id, _, _ := session.ParseKey(target)
+		return id == sessionID
+	})...)
+	return p, nil
+}
+
+func (s *Store) planArchive(ix *index.Index, key, path string) (*changePlan, error) {
+	if s.isArchived(path) {
+		return nil, newError("archiving", key, ErrInvalid, "already archived")
+	}
+	p := &changePlan{moves: [][2]string{{path, s.archivePath(path)}}}
+	sessionID, name, isArtifact := session.ParseKey(key)
+	if isArtifact {
+		if sessionPath, err := s.path(session.FormatSessionKey(sessionID)); err == nil && fileExists(sessionPath) {
+			p.edits = append(p.edits, refEdit{key: sessionID, path: sessionPath, session: func(sess *Session) {
+				sess.Artifacts = slices.DeleteFunc(sess.Artifacts, func(ref ArtifactRef) bool { return ref.Path == name })
+			}})
+		}
+		return p, nil
+	}
+
+	files, err := filesUnder(session.ResolveArtifactDir(s.dir, sessionID))
+	if err != nil {
+		return nil, &Error{Op: "archiving", Key: key, Err: err}
+	}
+	for _, f := range files {
+		p.moves = append(p.moves, [2]string{f, s.archivePath(f)})
+	}
+	p.edits = append(p.edits, s.relatedEdits(ix, sessionID)...)
+	return p, nil
+}
+
+func (s *Store) planRestore(ix *index.Index, key, path string) (*changePlan, error) {
+	if !s.isArchived(path) {
+		return nil, newError("restoring", key, ErrInvalid, "not archived")
+	}
+	activePath := session.ResolveKeyToPath(s.dir, key)
+	if fileExists(activePath) {
+		return nil, newError("restoring", key, ErrExists, "%s already exists", s.relPath(activePath))
+	}
+	p := &changePlan{moves: [][2]string{{path, activePath}}}
+	sessionID, name, isArtifact := session.ParseKey(key)
+	if isArtifact {
+		sessionPath, err := s.path(session.FormatSessionKey(sessionID))
+		if err != nil {
+			return nil, err
+		}
+		if !fileExists(sessionPath) {
+			return nil, newError("restoring", key, ErrNotFound, "session %s does not exist", sessionID)
+		}
+		if s.isArchived(sessionPath) {
+			return nil, newError("restoring", key, ErrInvalid, "session %s is archived; restore it first", sessionID)
+		}
+		a, err := parseArtifact(key, path)
+		if err != nil {
+			return nil, err
+		}
+		p.edits = append(p.edits, refEdit{key: sessionID, path: sessionPath, session: func(sess *Session) {
+			if !slices.ContainsFunc(sess.Artifacts, func(ref ArtifactRef) bool { return ref.Path == name }) {
+				sess.Artifacts = append(sess.Artifacts, ArtifactRef{Path: name, Type: a.Type, Summary: a.Summary})
+			}
+		}})
+		return p, nil
+	}
+
+	sess, err := parseSession(sessionID, path)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range sess.Artifacts {
+		active := session.ResolveKeyToPath(s.dir, session.FormatArtifactKey(sessionID, ref.Path))
+		if archived := s.archivePath(active); fileExists(archived) && !fileExists(active) {
+			p.moves = append(p.moves, [2]string{archived, active})
+		}
+	}
+	for _, id := range sess.RelatedSessions {
+		relatedPath, err := s.path(session.FormatSessionKey(id))
+		if err != nil || id == sessionID || !fileExists(relatedPath) {
+			continue
+		}
+		if related := findSession(ix.AllSessions(), id); related != nil && slices.Contains(related.RelatedSessions, sessionID) {
+			continue
+		}
+		p.edits = append(p.edits, refEdit{key: id, path: relatedPath, session: func(related *Session) {
+			addRelated(related, sessionID)
+		}})
+	}
+	return p, nil
+}
+
+// relatedEdits removes sessionID from the related_sessions of every other
+// session that lists it.
+func (s *Store) relatedEdits(ix *index.Index, sessionID string) []refEdit {
+	var edits []refEdit
+	for _, other := range ix.AllSessions() {
+		if other.SessionID == sessionID || !slices.Contains(other.RelatedSessions, sessionID) {
+			continue
+		}
+		path, err := s.path(session.FormatSessionKey(other.SessionID))
+		if err != nil {
+			continue
+		}
+		edits = append(edits, refEdit{key: other.SessionID, path: path, session: func(sess *Session) {
+			sess.RelatedSessions = slices.DeleteFunc(sess.RelatedSessions, func(id string) bool { return id == sessionID })
+		}})
+	}
+	return edits
+}
+
+// supersedesEdits clears the supersedes and superseded_by pointers of every
+// artifact whose target is removed, other than the removed artifacts
+// themselves. Artifacts superseded by a removed one are released from
+// SupersededStatus.
+func (s *Store) supersedesEdits(ix *index.Index, removed func(key string) bool) []refEdit {
+	var edits []refEdit
+	var cfg *config.Config
+	for key, a := range ix.Artifacts() {
+		if removed(key) || !(a.Supersedes != "" && removed(a.Supersedes) || a.SupersededBy != "" && removed(a.SupersededBy)) {
+			continue
+		}
+		path, err := s.path(key)
+		if err != nil {
+			continue
+		}
+		if cfg == nil {
+			cfg = s.config()
+		}
+		edits = append(edits, refEdit{key: key, path: path, artifact: func(a *Artifact) {
+			if removed(a.Supersedes) {
+				a.Supersedes = ""
+			}
+			if removed(a.SupersededBy) {
+				release(cfg, a)
+			}
+		}})
+	}
+	slices.SortFunc(edits, func(a, b refEdit) int { return strings.Compare(a.key, b.key) })
+	return edits
+}
+
+// apply carries out a plan. The caller must hold the store lock. Failures to
+// update references are reported through Warn once the files themselves
+// have been changed.
+func (s *Store) apply(op string, p *changePlan) error {
+	var touched []string
+	for _, f := range p.deletes {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			s.reindex(touched...)
+			return &Error{Op: op, Key: p.Key, Err: err}
+		}
+		touched = append(touched, f)
+	}
+	for _, dir := range p.removeDirs {
+		if err := os.RemoveAll(dir); err != nil {
+			s.warn(fmt.Errorf("%s %s: %w", op, p.Key, err))
+		}
+	}
+	for _, m := range p.moves {
+		if err := os.MkdirAll(filepath.Dir(m[1]), 0755); err != nil {
+			s.reindex(touched...)
+			return &Error{Op: op, Key: p.Key, Err: err}
+		}
+		if err := os.Rename(m[0], m[1]); err != nil {
+			s.reindex(touched...)
+			return &Error{Op: op, Key: p.Key, Err: err}
+		}
+		touched = append(touched, m[0], m[1])
+		s.removeEmptyDirs(filepath.Dir(m[0]))
+	}
+
+	for _, e := range p.edits {
+		var err error
+		if e.session != nil {
+			var sess *Session
+			if sess, err = parseSession(e.key, e.path); err == nil {
+				e.session(sess)
+				err = parser.WriteSessionFile(e.path, sess)
+			}
+		} else {
+			var a *Artifact
+			if a, err = parseArtifact(e.key, e.path); err == nil {
+				e.artifact(a)
+				err = parser.WriteArtifactFile(e.path, a)
+			}
+		}
+		if err != nil {
+			s.warn(fmt.Errorf("updating references in %s: %w", e.key, err))
+			continue
+		}
+		touched = append(touched, e.path)
+	}
+	s.reindex(touched...)
+	return nil
+}
+
+// removeEmptyDirs removes dir and then its parents as long as they are left
+// empty, such as the artifact directory a session's last artifact moved out
+// of and the month directory above it. sessions/ and artifacts/, archived or
+// not, are kept.
+func (s *Store) removeEmptyDirs(dir string) {
+	for {
+		rel, err := filepath.Rel(s.dir, dir)
+		if err != nil {
+			return
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if parts[0] == index.ArchiveDir {
+			parts = parts[1:]
+		}
+		if len(parts) < 2 || os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// relPath returns path relative to the store directory, slash-separated.
+func (s *Store) relPath(path string) string {
+	rel, err := filepath.Rel(s.dir, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// filesUnder returns the files below dir, or nil if dir does not exist.
+func filesUnder(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func findSession(sessions []*Session, id string) *Session {
+	for _, sess := range sessions {
+		if sess.SessionID == id {
+			return sess
+		}
+	}
+	return nil
+}
@@ -0,0 +1,484 @@
+// Package store reads and writes a .sessions/ session memory store.
+//
+// A Store wraps one .sessions/ directory and keeps its on-disk index up to
+// date. All methods are safe for concurrent use within a process, and writes
+// are serialized across processes with an advisory lock on .sessions/.lock.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/glopal/sessions/internal/fsutil"
+	"github.com/glopal/sessions/internal/index"
+	"github.com/glopal/sessions/internal/parser"
+	"github.com/glopal/sessions/internal/root"
+	"github.com/glopal/sessions/internal/session"
+)
+
+// Aliases for the store's document types so callers outside this module can name them.
+type (
+	Session     = session.Session
+	FileChange  = session.FileChange
+	GitInfo     = session.GitInfo
+	ArtifactRef = session.ArtifactRef
+	Artifact    = session.Artifact
+)
+
+// MaxSummaryLength is the maximum length of session and artifact summaries.
+const MaxSummaryLength = session.MaxSummaryLength
+
+// LockFileName is the file in .sessions/ locked around every write.
+const LockFileName = ".lock"
+
+// Store is an open .sessions/ directory.
+type Store struct {
+	dir string
+
+	// Warn, if set, receives non-fatal problems such as unparseable session
+	// files or a failure to persist the index.
+	Warn func(err error)
+
+	mu sync.Mutex
+	ix *index.Index
+}
+
+// Open opens the store rooted at sessionsDir (the .sessions/ directory itself).
+func Open(sessionsDir string) (*Store, error) {
+	abs, err := filepath.Abs(sessionsDir)
+	if err != nil {
+		return nil, &Error{Op: "opening store", Err: err}
+	}
+	if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+		return nil, &Error{Kind: ErrNotFound, Err: errors.New(".sessions/ directory not found; run 'sessions init' first")}
+	}
+	return &Store{dir: abs}, nil
+}
+
+// OpenProject finds the project root above the working directory and opens its store.
+func OpenProject() (*Store, error) {
+	dir, err := root.SessionsDir()
+	if err != nil {
+		return nil, &Error{Kind: ErrNotFound, Err: err}
+	}
+	return Open(dir)
+}
+
+// Dir returns the .sessions/ directory.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+func (s *Store) warn(err error) {
+	if s.Warn != nil {
+		s.Warn(err)
+	}
+}
+
+// lock takes s.mu and the store's file lock, so that a read-modify-write
+// cycle cannot interleave with one in another goroutine or process. The
+// returned function releases both. Creating the lock file also adds it to
+// .sessions/.gitignore, so it never shows up as untracked.
+func (s *Store) lock() (func(), error) {
+	s.mu.Lock()
+	path := filepath.Join(s.dir, LockFileName)
+	_, err := os.Stat(path)
+	created := errors.Is(err, os.ErrNotExist)
+	l, err := fsutil.Lock(path)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, &Error{Op: "locking store", Err: err}
+	}
+	if created {
+		if err := EnsureGitignored(s.dir); err != nil {
+			s.warn(err)
+		}
+	}
+	return func() {
+		l.Unlock()
+		s.mu.Unlock()
+	}, nil
+}
+
+// index returns the store's index, refreshed against the files on disk.
+// The caller must hold s.mu.
+func (s *Store) index() (*index.Index, error) {
+	if s.ix == nil {
+		s.ix = index.Open(s.dir)
+	}
+	if err := s.ix.Refresh(); err != nil {
+		return nil, &Error{Op: "loading sessions", Err: err}
+	}
+	if err := s.ix.Save(); err != nil {
+		s.warn(err)
+	}
+	return s.ix, nil
+}
+
+// reindex re-parses files after a write. The caller must hold s.mu.
+func (s *Store) reindex(paths ...string) {
+	if s.ix == nil {
+		s.ix = index.Open(s.dir)
+	}
+	s.ix.Update(paths...)
+	if err := s.ix.Save(); err != nil {
+		s.warn(fmt.Errorf("updating index: %w", err))
+	}
+}
+
+// Rebuild discards the index and re-parses every file, returning the number
+// of sessions and artifacts indexed.
+func (s *Store) Rebuild() (sessions, artifacts int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ix, err := index.Rebuild(s.dir)
+	if ix == nil {
+		return 0, 0, &Error{Op: "rebuilding index", Err: err}
+	}
+	s.ix = ix
+	if err != nil {
+		s.warn(err)
+	}
+	sessions, artifacts = ix.Counts()
+	return sessions, artifacts, nil
+}
+
+// Failure is a session file that could not be parsed.
+type Failure = index.Failure
+
+// List returns every session that is not archived, most recent first.
+// Unparseable session files are skipped and reported through Warn and
+// Failures. The returned sessions must not be modified.
+func (s *Store) List() ([]*Session, error) {
+	return s.list(false)
+}
+
+// ListAll is like List but includes archived sessions, which have Archived set.
+func (s *Store) ListAll() ([]*Session, error) {
+	return s.list(true)
+}
+
+func (s *Store) list(includeArchived bool) ([]*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ix, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	s.warnFailures(ix)
+	if includeArchived {
+		return ix.AllSessions(), nil
+	}
+	return ix.Sessions(), nil
+}
+
+// warnFailures reports unparseable session files through Warn.
+func (s *Store) warnFailures(ix *index.Index) {
+	for _, f := range ix.Failures() {
+		s.warn(fmt.Errorf("skipping %s: %s", path.Base(f.Path), f.Err))
+	}
+}
+
+// Failures returns the session files that could not be parsed.
+func (s *Store) Failures() ([]Failure, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ix, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	return ix.Failures(), nil
+}
+
+// File is a session or artifact file in the store, as last parsed.
+type File struct {
+	// Path is the file's slash-separated path relative to the store.
+	Path string
+	// Key is the session or artifact key the file's location gives it.
+	Key      string
+	Archived bool
+	// Exactly one of Session, Artifact and Err is set.
+	Session  *Session
+	Artifact *Artifact
+	Err      string
+}
+
+// Files returns every session and artifact file in the store, archived or
+// not, including those that could not be parsed, in path order. The returned
+// documents are shared with the store's cache and must not be modified.
+func (s *Store) Files() ([]File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ix, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	files := make([]File, 0, len(ix.Entries))
+	for rel, e := range ix.Entries {
+		f := File{Path: rel, Session: e.Session, Artifact: e.Artifact, Err: e.Error}
+		trimmed, archived := strings.CutPrefix(rel, index.ArchiveDir+"/")
+		f.Archived = archived
+		name := strings.TrimSuffix(path.Base(trimmed), ".md")
+		if strings.HasPrefix(trimmed, "sessions/") {
+			f.Key = session.FormatSessionKey(name)
+		} else {
+			f.Key = session.FormatArtifactKey(path.Base(path.Dir(trimmed)), path.Base(trimmed))
+		}
+		files = append(files, f)
+	}
+	slices.SortFunc(files, func(a, b File) int { return strings.Compare(a.Path, b.Path) })
+	return files, nil
+}
+
+// Resolve returns the canonical form of a session or artifact key, replacing
+// a session slug with the session's ID. Keys naming a session by ID, and
+// slugs no session uses, are returned unchanged. A slug shared by several
+// sessions fails with ErrInvalid.
+func (s *Store) Resolve(key string) (string, error) {
+	if !namesSlug(key) {
+		return key, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ix, err := s.index()
+	if err != nil {
+		return "", err
+	}
+	return resolveKey(ix, key)
+}
+
+// namesSlug reports whether key names its session by something that may be
+// a slug rather than by ID.
+func namesSlug(key string) bool {
+	id, _, _ := session.ParseKey(key)
+	return id != "" && !session.IsSessionID(id) && session.ValidateSlug(id) == nil
+}
+
+// resolveKey is Resolve against an already loaded index.
+func resolveKey(ix *index.Index, key string) (string, error) {
+	if !namesSlug(key) {
+		return key, nil
+	}
+	id, name, isArtifact := session.ParseKey(key)
+	switch ids := slugSessions(ix.AllSessions(), id); len(ids) {
+	case 0:
+		return key, nil
+	case 1:
+		id = ids[0]
+	default:
+		return "", newError("resolving key", key, ErrInvalid, "slug %q is used by several sessions: %s", id, strings.Join(ids, ", "))
+	}
+	if isArtifact {
+		return session.FormatArtifactKey(id, name), nil
+	}
+	return session.FormatSessionKey(id), nil
+}
+
+// slugSessions returns the IDs of the sessions with the given slug.
+func slugSessions(sessions []*Session, slug string) []string {
+	var ids []string
+	for _, sess := range sessions {
+		if sess.Slug == slug {
+			ids = append(ids, sess.SessionID)
+		}
+	}
+	return ids
+}
+
+// resolvePath resolves a key with Resolve and returns it with its file path.
+func (s *Store) resolvePath(key string) (string, string, error) {
+	key, err := s.Resolve(key)
+	if err != nil {
+		return "", "", err
+	}
+	path, err := s.path(key)
+	return key, path, err
+}
+
+// Path resolves a session or artifact key, which may name the session by its
+// slug, to its file path. Keys that would resolve outside the store are
+// rejected with ErrInvalid.
+func (s *Store) Path(key string) (string, error) {
+	_, path, err := s.resolvePath(key)
+	return path, err
+}
+
+// path resolves a canonical key to its file path: the archived file if the
+// key has been archived, and otherwise the active one, existing or not.
+func (s *Store) path(key string) (string, error) {
+	if key == "" {
+		return "", newError("resolving key", key, ErrInvalid, "empty key")
+	}
+	path := session.ResolveKeyToPath(s.dir, key)
+	rel, err := filepath.Rel(s.dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", newError("resolving key", key, ErrInvalid, "key escapes the sessions directory")
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if archived := s.archivePath(path); fileExists(archived) {
+			return archived, nil
+		}
+	}
+	return path, nil
+}
+
+// archivePath returns where the active file or directory at path is kept
+// once archived.
+func (s *Store) archivePath(path string) string {
+	rel, err := filepath.Rel(s.dir, path)
+	if err != nil {
+		return path
+	}
+	return filepath.Join(s.dir, index.ArchiveDir, rel)
+}
+
+// isArchived reports whether path is inside the archive.
+func (s *Store) isArchived(path string) bool {
+	return strings.HasPrefix(path, filepath.Join(s.dir, index.ArchiveDir)+string(filepath.Separator))
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Get loads a session by ID or slug. The returned session is shared with the
+// store's cache and must not be modified; use UpdateSession to change it.
+func (s *Store) Get(id string) (*Session, error) {
+	id, path, err := s.resolvePath(session.FormatSessionKey(id))
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadSession(id, path)
+}
+
+// loadSession loads the session at path through the index. The caller must hold s.mu.
+func (s *Store) loadSession(id, path string) (*Session, error) {
+	if s.ix == nil {
+		s.ix = index.Open(s.dir)
+	}
+	e, ok := s.ix.File(path)
+	if !ok {
+		return nil, newError("loading session", id, ErrNotFound, "no such session")
+	}
+	if e.Session == nil {
+		return nil, newError("loading session", id, nil, "%s", e.Error)
+	}
+	return e.Session, nil
+}
+
+// Artifact loads an artifact attached to a session.
+func (s *Store) Artifact(sessionID, name string) (*Artifact, error) {
+	return s.GetArtifact(session.FormatArtifactKey(sessionID, name))
+}
+
+// GetArtifact loads an artifact by key ("SESSION_ID/name.md"). Like Get, the
+// result must not be modified.
+func (s *Store) GetArtifact(key string) (*Artifact, error) {
+	if _, _, isArtifact := session.ParseKey(key); !isArtifact {
+		return nil, newError("loading artifact", key, ErrInvalid, "not an artifact key")
+	}
+	key, path, err := s.resolvePath(key)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadArtifact(key, path)
+}
+
+// loadArtifact loads the artifact at path through the index. The caller must hold s.mu.
+func (s *Store) loadArtifact(key, path string) (*Artifact, error) {
+	if s.ix == nil {
+		s.ix = index.Open(s.dir)
+	}
+	e, ok := s.ix.File(path)
+	if !ok {
+		return nil, newError("loading artifact", key, ErrNotFound, "no such artifact")
+	}
+	if e.Artifact == nil {
+		return nil, newError("loading artifact", key, nil, "%s", e.Error)
+	}
+	return e.Artifact, nil
+}
+
+// Exists reports whether the file for a session or artifact key exists. It
+// does not take the store's lock, so UpdateSession and UpdateArtifact
+// callbacks may call it; for the same reason it does not resolve slugs.
+func (s *Store) Exists(key string) bool {
+	path, err := s.path(key)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// MostRecent returns the ID of the most recent session.
+func (s *Store) MostRecent() (string, error) {
+	sessionsSubDir := filepath.Join(s.dir, "sessions")
+	ymDirs, err := os.ReadDir(sessionsSubDir)
+	if err != nil {
+		return "", &Error{Op: "reading sessions directory", Err: err}
+	}
+
+	var sessionIDs []string
+	for _, ym := range ymDirs {
+		if !ym.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(sessionsSubDir, ym.Name()))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".md") {
+				continue
+			}
+			sessionIDs = append(sessionIDs, strings.TrimSuffix(e.Name(), ".md"))
+		}
+	}
+
+	if len(sessionIDs) == 0 {
+		return "", newError("finding most recent session", "", ErrNotFound, "no sessions found; create one with 'sessions new'")
+	}
+
+	return slices.MaxFunc(sessionIDs, session.CompareSessionIDs), nil
+}
+
+// ReadRaw returns the raw file contents for a session or artifact key.
+func (s *Store) ReadRaw(key string) ([]byte, os.FileInfo, error) {
+	key, path, err := s.resolvePath(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, nil, newError("reading", key, ErrNotFound, "no such session or artifact")
+	}
+	if err != nil {
+		return nil, nil, &Error{Op: "reading", Key: key, Err: err}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, &Error{Op: "reading", Key: key, Err: err}
+	}
+	return data, info, nil
+}
+
+// writeSessionFile writes a session and re-indexes it. The caller must hold s.mu.
+func (s *Store) writeSessionFile(path string, sess *Session) error {
+	if err := parser.WriteSessionFile(path, sess); err != nil {
+		return err
+	}
+	s.reindex(path)
+	return nil
+}
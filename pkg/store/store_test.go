@@ -0,0 +1,654 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), ".sessions")
+	for _, sub := range []string{"sessions", "artifacts"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func TestOpenMissing(t *testing.T) {
+	_, err := Open(filepath.Join(t.TempDir(), ".sessions"))
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Open = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCreateAndGet(t *testing.T) {
+	st := newTestStore(t)
+	s, err := st.CreateSession(&Session{
+		Summary:      "Add loader",
+		Tags:         []string{"csv"},
+		FilesChanged: []FileChange{{Path: "loader.go", Action: "added", Summary: "New"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := st.Get(s.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Summary != "Add loader" || len(got.Artifacts) != 0 {
+		t.Errorf("Get = %+v", got)
+	}
+
+	if err := st.CreateArtifact(s.SessionID, "adr.md", &Artifact{Title: "ADR", Type: "decision", Status: "accepted", Summary: "Why"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.CreateArtifact(s.SessionID, "adr.md", &Artifact{}); !errors.Is(err, ErrExists) {
+		t.Errorf("duplicate CreateArtifact = %v, want ErrExists", err)
+	}
+	if err := st.CreateArtifact("1771953023", "adr.md", &Artifact{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("CreateArtifact on missing session = %v, want ErrNotFound", err)
+	}
+	if err := st.CreateArtifact(s.SessionID, "../x.md", &Artifact{}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("CreateArtifact with a path = %v, want ErrInvalid", err)
+	}
+	if err := st.CreateArtifact(s.SessionID, "long.md", &Artifact{Summary: strings.Repeat("x", MaxSummaryLength+1)}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("CreateArtifact with a long summary = %v, want ErrInvalid", err)
+	}
+	for _, in := range []*Session{
+		{Summary: strings.Repeat("x", MaxSummaryLength+1)},
+		{FilesChanged: []FileChange{{Action: "added"}}},
+		{FilesChanged: []FileChange{{Path: "a.go", Action: "moved"}}},
+	} {
+		if _, err := st.CreateSession(in); !errors.Is(err, ErrInvalid) {
+			t.Errorf("CreateSession(%+v) = %v, want ErrInvalid", in, err)
+		}
+	}
+
+	got, err = st.Get(s.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Artifacts) != 1 || got.Artifacts[0].Path != "adr.md" {
+		t.Errorf("artifacts = %+v", got.Artifacts)
+	}
+	a, err := st.Artifact(s.SessionID, "adr.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Status != "accepted" {
+		t.Errorf("status = %q", a.Status)
+	}
+
+	if _, err := st.Get("1771953023"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get missing = %v, want ErrNotFound", err)
+	}
+	if _, err := st.GetArtifact("../../etc/passwd"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("GetArtifact outside the store = %v, want ErrInvalid", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	st := newTestStore(t)
+	s, err := st.CreateSession(&Session{Summary: "Before"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.CreateArtifact(s.SessionID, "notes.md", &Artifact{Type: "analysis"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.SetSummary(s.SessionID, strings.Repeat("x", MaxSummaryLength+1)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("SetSummary too long = %v, want ErrInvalid", err)
+	}
+	if err := st.SetSummary(s.SessionID, "After"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetSummary(s.SessionID+"/notes.md", "Findings"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := st.Get(s.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Summary != "After" {
+		t.Errorf("summary = %q", got.Summary)
+	}
+	if got.Artifacts[0].Summary != "Findings" {
+		t.Errorf("artifact ref summary = %q, want it synced from the artifact", got.Artifacts[0].Summary)
+	}
+
+	boom := errors.New("boom")
+	if err := st.UpdateSession(s.SessionID, func(*Session) error { return boom }); err != boom {
+		t.Errorf("UpdateSession = %v, want the callback's error", err)
+	}
+}
+
+func TestQueryAndLink(t *testing.T) {
+	st := newTestStore(t)
+	writeSession(t, st, "1771900000", "tags: [auth]\nfiles_changed:\n  - path: internal/auth/token.go\n    action: modified\n    summary: Retry")
+	writeSession(t, st, "1771900100", "tags: [csv]\nfiles_changed:\n  - path: internal/auth/token.go\n    action: modified\n    summary: Log")
+
+	matches, _, err := st.Query(Query{Expr: "tag:auth AND file:internal/**"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Session.SessionID != "1771900000" {
+		t.Fatalf("Query = %+v", matches)
+	}
+	if len(matches[0].MatchedFiles) != 1 {
+		t.Errorf("matched files = %v", matches[0].MatchedFiles)
+	}
+
+	_, _, err = st.Query(Query{Expr: "tag:auth AND"})
+	var qe *QueryError
+	if !errors.Is(err, ErrInvalid) || !errors.As(err, &qe) {
+		t.Errorf("bad expression = %v, want ErrInvalid wrapping *QueryError", err)
+	}
+	if _, _, err := st.Query(Query{After: "yesterday"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("bad date = %v, want ErrInvalid", err)
+	}
+
+	n, err := st.AutoLink()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("AutoLink updated %d sessions, want 2", n)
+	}
+	s, err := st.Get("1771900000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.RelatedSessions) != 1 || s.RelatedSessions[0] != "1771900100" {
+		t.Errorf("related = %v", s.RelatedSessions)
+	}
+
+	if err := st.Link("1771900000", "1771953023"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Link to missing session = %v, want ErrNotFound", err)
+	}
+}
+
+// writeSession writes a session file by hand, as an editor or older version would.
+func writeSession(t *testing.T, st *Store, id, frontmatter string) {
+	t.Helper()
+	path, err := st.Path(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := "---\ntimestamp: 2026-02-24T10:00:00Z\nsession_id: \"" + id + "\"\nsummary: Session " + id + "\n" + frontmatter + "\n---\n\nBody.\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGitMetadata(t *testing.T) {
+	st := newTestStore(t)
+	s, err := st.CreateSession(&Session{Summary: "On a branch", Git: &GitInfo{Commit: "4f2a9c1e0b", Branch: "feature/x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sha := range []string{"9B3D0E7A11", "9b3d0e7a11"} {
+		if _, err := st.AttachCommit(s.SessionID, sha); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := st.AttachCommit(s.SessionID, "HEAD"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("AttachCommit of a ref = %v, want ErrInvalid", err)
+	}
+	got, err := st.Get(s.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Git.Branch != "feature/x" || len(got.Git.Commits) != 1 || got.Git.Commits[0] != "9b3d0e7a11" {
+		t.Errorf("git = %+v", got.Git)
+	}
+
+	for _, q := range []Query{{Commit: "4f2a9c"}, {Commit: "9b3d0e7a11"}, {Branch: "feature/x"}, {Expr: "branch:feature/*"}} {
+		matches, _, err := st.Query(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != 1 {
+			t.Errorf("Query(%+v) = %d matches, want 1", q, len(matches))
+		}
+	}
+	if matches, _, _ := st.Query(Query{Branch: "main"}); len(matches) != 0 {
+		t.Errorf("Query on another branch matched %d sessions", len(matches))
+	}
+}
+
+// TestConcurrentWrites hammers one session from separate Store instances, as
+// separate processes would, and checks that no update is lost.
+func TestConcurrentWrites(t *testing.T) {
+	st := newTestStore(t)
+	writeSession(t, st, "1771900000", "")
+
+	const writers = 8
+	const rounds = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*rounds*2)
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			other, err := Open(st.Dir())
+			if err != nil {
+				errs <- err
+				return
+			}
+			for r := range rounds {
+				tag := fmt.Sprintf("w%d-%d", w, r)
+				errs <- other.UpdateSession("1771900000", func(s *Session) error {
+					s.Tags = append(s.Tags, tag)
+					return nil
+				})
+				errs <- other.CreateArtifact("1771900000", tag+".md", &Artifact{Title: tag, Type: "analysis", Status: "draft"})
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := st.Get("1771900000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Tags) != writers*rounds || len(s.Artifacts) != writers*rounds {
+		t.Errorf("got %d tags and %d artifacts, want %d of each", len(s.Tags), len(s.Artifacts), writers*rounds)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(st.Dir(), "sessions", "*", ".*.tmp"))
+	if len(leftovers) > 0 {
+		t.Errorf("temp files left behind: %v", leftovers)
+	}
+}
+
+func TestLockGitignored(t *testing.T) {
+	st := newTestStore(t)
+	gitignore := filepath.Join(st.Dir(), ".gitignore")
+	if err := os.WriteFile(gitignore, []byte("index.json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.CreateSession(&Session{Summary: "Add loader"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(gitignore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "index.json\n"+LockFileName+"\n" {
+		t.Errorf(".gitignore = %q, want the lock file added", data)
+	}
+}
+
+func TestSessionIDs(t *testing.T) {
+	st := newTestStore(t)
+
+	// Sessions created in the same second get sequence suffixes, and a
+	// hand-written file for the current second is not overwritten.
+	now := time.Now().Unix()
+	writeSession(t, st, fmt.Sprint(now), "")
+	seen := map[string]bool{}
+	for range 3 {
+		s, err := st.CreateSession(&Session{Summary: "Parallel"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[s.SessionID] || s.SessionID == fmt.Sprint(now) {
+			t.Fatalf("duplicate session ID %s", s.SessionID)
+		}
+		seen[s.SessionID] = true
+	}
+	if got, err := st.Get(fmt.Sprint(now)); err != nil || got.Summary != "Session "+fmt.Sprint(now) {
+		t.Errorf("hand-written session = %+v, %v", got, err)
+	}
+
+	st = newTestStore(t)
+	for _, id := range []string{"1771900000", "1771900000.9", "1771900000.10", "1771800000.2"} {
+		writeSession(t, st, id, "")
+	}
+	if id, err := st.MostRecent(); err != nil || id != "1771900000.10" {
+		t.Errorf("MostRecent = %q, %v", id, err)
+	}
+	sessions, err := st.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, s := range sessions {
+		ids = append(ids, s.SessionID)
+	}
+	if got := strings.Join(ids, " "); got != "1771900000.10 1771900000.9 1771900000 1771800000.2" {
+		t.Errorf("List order = %s", got)
+	}
+	if path, _ := st.Path("1771900000.9/adr.md"); !strings.HasSuffix(path, filepath.Join("artifacts", "2026-02", "1771900000.9", "adr.md")) {
+		t.Errorf("artifact path = %s", path)
+	}
+}
+
+func TestSlugs(t *testing.T) {
+	st := newTestStore(t)
+	s, err := st.CreateSession(&Session{Slug: "csv-loader", Summary: "Add loader"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.CreateSession(&Session{Slug: "csv-loader"}); !errors.Is(err, ErrExists) {
+		t.Errorf("duplicate slug = %v, want ErrExists", err)
+	}
+	for _, slug := range []string{"CSV", "1771900000", "csv loader", "-csv"} {
+		if _, err := st.CreateSession(&Session{Slug: slug}); !errors.Is(err, ErrInvalid) {
+			t.Errorf("slug %q = %v, want ErrInvalid", slug, err)
+		}
+	}
+
+	if got, err := st.Get("csv-loader"); err != nil || got.SessionID != s.SessionID {
+		t.Fatalf("Get by slug = %+v, %v", got, err)
+	}
+	if err := st.CreateArtifact("csv-loader", "adr.md", &Artifact{Title: "ADR", Type: "decision", Status: "draft"}); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := st.Resolve("csv-loader/adr.md"); err != nil || key != s.SessionID+"/adr.md" {
+		t.Errorf("Resolve = %q, %v", key, err)
+	}
+	if a, err := st.GetArtifact("csv-loader/adr.md"); err != nil || a.Title != "ADR" {
+		t.Errorf("GetArtifact by slug = %+v, %v", a, err)
+	}
+
+	writeSession(t, st, "1771900000", "slug: other")
+	err = st.UpdateSession("other", func(sess *Session) error {
+		sess.Slug = "csv-loader"
+		return nil
+	})
+	if !errors.Is(err, ErrExists) {
+		t.Errorf("renaming to a used slug = %v, want ErrExists", err)
+	}
+	if err := st.Link("csv-loader", s.SessionID); !errors.Is(err, ErrInvalid) {
+		t.Errorf("linking a session to itself = %v, want ErrInvalid", err)
+	}
+}
+
+func TestArchiveRestoreRemove(t *testing.T) {
+	st := newTestStore(t)
+	writeSession(t, st, "1771900000", "")
+	writeSession(t, st, "1771900100", "")
+	if err := st.Link("1771900000", "1771900100"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct{ id, name, supersedes string }{
+		{"1771900000", "old.md", ""},
+		{"1771900000", "new.md", "1771900000/old.md"},
+		{"1771900100", "later.md", "1771900000/new.md"},
+	} {
+		if err := st.CreateArtifact(c.id, c.name, &Artifact{Title: c.name, Type: "decision", Status: "draft", Supersedes: c.supersedes}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	related := func(id string) []string {
+		t.Helper()
+		s, err := st.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.RelatedSessions
+	}
+
+	c, err := st.Archive("1771900000", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Files) != 3 || strings.Join(c.Updated, ",") != "1771900100" || len(related("1771900100")) != 1 {
+		t.Errorf("dry run = %+v, related = %v", c, related("1771900100"))
+	}
+
+	if _, err := st.Archive("1771900000", false); err != nil {
+		t.Fatal(err)
+	}
+	if sessions, _ := st.List(); len(sessions) != 1 {
+		t.Errorf("List has %d sessions after archiving, want 1", len(sessions))
+	}
+	if all, _ := st.ListAll(); len(all) != 2 || !all[1].Archived {
+		t.Errorf("ListAll = %+v", all)
+	}
+	if len(related("1771900100")) != 0 {
+		t.Errorf("related_sessions still lists the archived session")
+	}
+	if a, err := st.GetArtifact("1771900000/new.md"); err != nil || a.Title != "new.md" {
+		t.Errorf("archived artifact = %+v, %v", a, err)
+	}
+	if m, _, _ := st.Query(Query{IncludeArchived: true}); len(m) != 2 {
+		t.Errorf("Query with archived = %d matches, want 2", len(m))
+	}
+	if err := st.CreateArtifact("1771900000", "more.md", &Artifact{}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("CreateArtifact on archived session = %v, want ErrInvalid", err)
+	}
+	if _, err := st.Archive("1771900000", false); !errors.Is(err, ErrInvalid) {
+		t.Errorf("archiving twice = %v, want ErrInvalid", err)
+	}
+
+	if _, err := st.Restore("1771900000", false); err != nil {
+		t.Fatal(err)
+	}
+	if r := related("1771900100"); len(r) != 1 || r[0] != "1771900000" {
+		t.Errorf("related_sessions after restore = %v", r)
+	}
+	if path, _ := st.Path("1771900000/new.md"); st.isArchived(path) {
+		t.Errorf("artifact still archived at %s", path)
+	}
+	for _, dir := range []string{"sessions/2026-02", "artifacts/2026-02"} {
+		if _, err := os.Stat(filepath.Join(st.Dir(), "archive", dir)); !os.IsNotExist(err) {
+			t.Errorf("archive/%s left behind by restore: %v", dir, err)
+		}
+	}
+
+	// An artifact archived on its own leaves its session's list and comes back to it.
+	if _, err := st.Archive("1771900000/old.md", false); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := st.Get("1771900000"); len(s.Artifacts) != 1 {
+		t.Errorf("artifacts after archiving old.md = %+v", s.Artifacts)
+	}
+	if _, err := st.Restore("1771900000/old.md", false); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := st.Get("1771900000"); len(s.Artifacts) != 2 {
+		t.Errorf("artifacts after restoring old.md = %+v", s.Artifacts)
+	}
+
+	c, err = st.Remove("1771900000", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Files) != 3 || strings.Join(c.Updated, ",") != "1771900100,1771900100/later.md" {
+		t.Errorf("Remove = %+v", c)
+	}
+	if _, err := st.Get("1771900000"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Remove = %v, want ErrNotFound", err)
+	}
+	if a, _ := st.GetArtifact("1771900100/later.md"); a.Supersedes != "" {
+		t.Errorf("supersedes not cleared: %q", a.Supersedes)
+	}
+	if len(related("1771900100")) != 0 {
+		t.Errorf("related_sessions still lists the removed session")
+	}
+	if _, err := st.Remove("1771900100/later.md", false); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := st.Get("1771900100"); len(s.Artifacts) != 0 {
+		t.Errorf("artifacts after removing later.md = %+v", s.Artifacts)
+	}
+}
+
+func TestSupersede(t *testing.T) {
+	st := newTestStore(t)
+	writeSession(t, st, "1771900000", "slug: adr-work\n")
+	create := func(name, supersedes string) error {
+		return st.CreateArtifact("1771900000", name, &Artifact{Title: name, Type: "decision", Status: "accepted", Supersedes: supersedes})
+	}
+	if err := create("v1.md", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := create("v2.md", "adr-work/v1.md"); err != nil {
+		t.Fatal(err)
+	}
+	v1, _ := st.GetArtifact("1771900000/v1.md")
+	if v1.Status != "superseded" || v1.SupersededBy != "1771900000/v2.md" {
+		t.Errorf("v1 = %+v, want superseded by v2", v1)
+	}
+	if v2, _ := st.GetArtifact("1771900000/v2.md"); v2.Supersedes != "1771900000/v1.md" {
+		t.Errorf("v2 supersedes %q, want the canonical key", v2.Supersedes)
+	}
+
+	for _, c := range []struct {
+		name, supersedes string
+		kind             error
+	}{
+		{"missing.md", "1771900000/nope.md", ErrNotFound},
+		{"session.md", "1771900000", ErrInvalid},
+		{"again.md", "1771900000/v1.md", ErrInvalid},
+	} {
+		if err := create(c.name, c.supersedes); !errors.Is(err, c.kind) {
+			t.Errorf("superseding %s = %v, want %v", c.supersedes, err, c.kind)
+		}
+	}
+	if err := create("v3.md", "1771900000/v2.md"); err != nil {
+		t.Fatal(err)
+	}
+	err := st.UpdateArtifact("1771900000/v1.md", func(a *Artifact) error {
+		a.Supersedes = "1771900000/v3.md"
+		return nil
+	})
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("closing a cycle = %v, want ErrInvalid", err)
+	}
+
+	chain, err := st.Chain("1771900000/v2.md")
+	if err != nil || strings.Join(chain, ",") != "1771900000/v1.md,1771900000/v2.md,1771900000/v3.md" {
+		t.Errorf("Chain = %v, %v", chain, err)
+	}
+	heads, err := st.Heads()
+	if err != nil || len(heads) != 2 || heads["1771900000/v1.md"] != "1771900000/v3.md" {
+		t.Errorf("Heads = %v, %v", heads, err)
+	}
+
+	// Repointing v3 releases v2 and takes over v1, which v2 no longer holds.
+	if err := st.UpdateArtifact("1771900000/v2.md", func(a *Artifact) error {
+		a.Supersedes = ""
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if v1, _ := st.GetArtifact("1771900000/v1.md"); v1.SupersededBy != "" || v1.Status != "accepted" {
+		t.Errorf("v1 = %+v, want it released to accepted", v1)
+	}
+	if err := st.UpdateArtifact("1771900000/v3.md", func(a *Artifact) error {
+		a.Supersedes = "1771900000/v1.md"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if v2, _ := st.GetArtifact("1771900000/v2.md"); v2.SupersededBy != "" {
+		t.Errorf("v2 still superseded by %q", v2.SupersededBy)
+	}
+	if v1, _ := st.GetArtifact("1771900000/v1.md"); v1.SupersededBy != "1771900000/v3.md" {
+		t.Errorf("v1 superseded by %q, want v3", v1.SupersededBy)
+	}
+
+	if _, err := st.Remove("1771900000/v3.md", false); err != nil {
+		t.Fatal(err)
+	}
+	v1, _ = st.GetArtifact("1771900000/v1.md")
+	if v1.SupersededBy != "" || v1.Status != "accepted" {
+		t.Errorf("v1 after removing v3 = %+v, want it released to accepted", v1)
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	st := newTestStore(t)
+	writeSession(t, st, "1771900000", "artifacts:\n  - path: gone.md\n    type: decision\n    summary: Gone\nrelated_sessions: [\"1771999999\"]")
+	for rel, content := range map[string]string{
+		"artifacts/2026-02/1771900000/notes.md":  "---\ntitle: Notes\ntype: analysis\nsummary: Loose notes\nstatus: draft\n---\n\nBody.\n",
+		"artifacts/2026-02/1771900000/bad.md":    "---\ntitle: [\n---\n",
+		"artifacts/2026-02/1771999998/x.md":      "---\ntitle: X\n---\n",
+		"artifacts/2026-01/1769000000/sub/.keep": "",
+		"sessions/2025-12/.keep":                 "",
+	} {
+		path := filepath.Join(st.Dir(), filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasSuffix(rel, ".keep") {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	describe := func(problems []Problem) []string {
+		var out []string
+		for _, p := range problems {
+			out = append(out, fmt.Sprintf("%s %s repairable=%v repaired=%v", p.Kind, p.Path, p.Repairable, p.Repaired))
+		}
+		return out
+	}
+
+	problems, err := st.Doctor(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"unlisted-artifact artifacts/2026-02/1771900000/bad.md repairable=false repaired=false",
+		"unlisted-artifact artifacts/2026-02/1771900000/notes.md repairable=true repaired=false",
+		"missing-artifact artifacts/2026-02/1771900000/gone.md repairable=true repaired=false",
+		"dangling-related sessions/2026-02/1771900000.md repairable=true repaired=false",
+		"orphaned-artifact-dir artifacts/2026-02/1771999998 repairable=false repaired=false",
+		"empty-dir artifacts/2026-01 repairable=true repaired=false",
+		"empty-dir sessions/2025-12 repairable=true repaired=false",
+	}
+	if got := describe(problems); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if _, err := st.Doctor(true); err != nil {
+		t.Fatal(err)
+	}
+	s, err := st.Get("1771900000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Artifacts) != 1 || s.Artifacts[0] != (ArtifactRef{Path: "notes.md", Type: "analysis", Summary: "Loose notes"}) || len(s.RelatedSessions) != 0 {
+		t.Errorf("repaired session = %+v", s)
+	}
+	if fileExists(filepath.Join(st.Dir(), "artifacts", "2026-01")) {
+		t.Error("empty directory not removed")
+	}
+
+	problems, err = st.Doctor(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := describe(problems); len(got) != 2 || got[0] != want[0] || got[1] != want[4] {
+		t.Errorf("problems after repair = %q", got)
+	}
+}
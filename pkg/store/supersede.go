@@ -0,0 +1,217 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/glopal/sessions/internal/config"
+	"github.com/glopal/sessions/internal/parser"
+	"github.com/glopal/sessions/internal/session"
+)
+
+// SupersededStatus is the status an artifact is given when another one
+// supersedes it.
+const SupersededStatus = "superseded"
+
+// checkSupersedes checks that the artifact at key may supersede target and
+// returns target in canonical form. target must be another existing
+// artifact, archived or not, that no other artifact supersedes, and must not
+// itself lead back to key through its own supersedes chain. The caller must
+// hold the store lock.
+func (s *Store) checkSupersedes(key, target string) (string, error) {
+	ix, err := s.index()
+	if err != nil {
+		return "", err
+	}
+	target, err = resolveKey(ix, target)
+	if err != nil {
+		return "", err
+	}
+	if _, _, isArtifact := session.ParseKey(target); !isArtifact {
+		return "", newError("superseding", key, ErrInvalid, "supersedes must be an artifact key (SESSION_ID/name.md), got %q", target)
+	}
+	if target == key {
+		return "", newError("superseding", key, ErrInvalid, "an artifact cannot supersede itself")
+	}
+	artifacts := ix.Artifacts()
+	a, ok := artifacts[target]
+	if !ok {
+		return "", newError("superseding", key, ErrNotFound, "no such artifact %s", target)
+	}
+	if a.SupersededBy != "" && a.SupersededBy != key {
+		if _, ok := artifacts[a.SupersededBy]; ok {
+			return "", newError("superseding", key, ErrInvalid, "%s is already superseded by %s", target, a.SupersededBy)
+		}
+	}
+	seen := map[string]bool{}
+	for k := target; k != "" && !seen[k]; k = artifacts[k].Supersedes {
+		if k == key {
+			return "", newError("superseding", key, ErrInvalid, "superseding %s would create a cycle", target)
+		}
+		seen[k] = true
+		if _, ok := artifacts[k]; !ok {
+			break
+		}
+	}
+	return target, nil
+}
+
+// markSuperseded records that the artifact at key now supersedes target
+// instead of previous: target is marked superseded and pointed back at key,
+// and previous is released. Either may be empty. It returns the paths
+// written. Failures are reported through Warn, as the artifact at key has
+// already been written. The caller must hold the store lock.
+func (s *Store) markSuperseded(key, target, previous string) []string {
+	var written []string
+	if previous != "" && previous != target {
+		cfg := s.config()
+		written = append(written, s.updateSupersededBy(previous, func(a *Artifact) bool {
+			if a.SupersededBy != key {
+				return false
+			}
+			release(cfg, a)
+			return true
+		})...)
+	}
+	if target != "" {
+		written = append(written, s.updateSupersededBy(target, func(a *Artifact) bool {
+			if a.Status == SupersededStatus && a.SupersededBy == key {
+				return false
+			}
+			a.Status = SupersededStatus
+			a.SupersededBy = key
+			return true
+		})...)
+	}
+	return written
+}
+
+// release clears the back-pointer of an artifact whose superseding artifact
+// is gone or points elsewhere, and moves it out of SupersededStatus into the
+// status cfg reinstates it to, so that it is not left stale with nothing
+// superseding it.
+func release(cfg *config.Config, a *Artifact) {
+	a.SupersededBy = ""
+	if a.Status == SupersededStatus {
+		a.Status = cfg.ReinstatedStatus(a.Type, SupersededStatus)
+	}
+}
+
+// config returns the store's config, falling back to the default one, with
+// a warning, if it cannot be read.
+func (s *Store) config() *config.Config {
+	cfg, err := config.Load(s.dir)
+	if err != nil {
+		s.warn(err)
+		return config.Default()
+	}
+	return cfg
+}
+
+// updateSupersededBy applies fn to the artifact at key and writes it back if
+// fn reports a change, returning the path written. Artifacts that no longer
+// exist are skipped. The caller must hold the store lock.
+func (s *Store) updateSupersededBy(key string, fn func(*Artifact) bool) []string {
+	path, err := s.path(key)
+	if err != nil || !fileExists(path) {
+		return nil
+	}
+	a, err := parseArtifact(key, path)
+	if err != nil {
+		s.warn(fmt.Errorf("updating superseded artifact %s: %w", key, err))
+		return nil
+	}
+	if !fn(a) {
+		return nil
+	}
+	if err := parser.WriteArtifactFile(path, a); err != nil {
+		s.warn(fmt.Errorf("updating superseded artifact %s: %w", key, err))
+		return nil
+	}
+	return []string{path}
+}
+
+// Chain returns the supersession chain the artifact at key belongs to, oldest
+// first: the artifacts it supersedes, itself, and the artifacts that
+// supersede it, up to the chain's head. An artifact outside any chain is a
+// chain of its own.
+func (s *Store) Chain(key string) ([]string, error) {
+	if _, _, isArtifact := session.ParseKey(key); !isArtifact {
+		return nil, newError("loading chain", key, ErrInvalid, "not an artifact key")
+	}
+	key, err := s.Resolve(key)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ix, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	artifacts := ix.Artifacts()
+	if _, ok := artifacts[key]; !ok {
+		return nil, newError("loading chain", key, ErrNotFound, "no such artifact")
+	}
+
+	chain := []string{key}
+	seen := map[string]bool{key: true}
+	for k := artifacts[key].Supersedes; k != "" && !seen[k]; {
+		a, ok := artifacts[k]
+		if !ok {
+			break
+		}
+		chain = append(chain, k)
+		seen[k] = true
+		k = a.Supersedes
+	}
+	slices.Reverse(chain)
+	next := successors(artifacts)
+	for k := next[key]; k != "" && !seen[k]; k = next[k] {
+		chain = append(chain, k)
+		seen[k] = true
+	}
+	return chain, nil
+}
+
+// Heads maps every superseded artifact to the head of its chain: the most
+// recent artifact that supersedes it, directly or through others, and is not
+// itself superseded.
+func (s *Store) Heads() (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ix, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	next := successors(ix.Artifacts())
+	heads := make(map[string]string, len(next))
+	for key := range next {
+		head := key
+		seen := map[string]bool{key: true}
+		for k := next[key]; k != "" && !seen[k]; k = next[k] {
+			head = k
+			seen[k] = true
+		}
+		heads[key] = head
+	}
+	return heads, nil
+}
+
+// successors maps each superseded artifact to the artifact that supersedes
+// it. Artifacts written before supersedes was enforced may share a target;
+// the one the target points back at wins, and otherwise the highest key.
+func successors(artifacts map[string]*Artifact) map[string]string {
+	next := make(map[string]string)
+	for key, a := range artifacts {
+		target, ok := artifacts[a.Supersedes]
+		if a.Supersedes == "" || a.Supersedes == key || !ok {
+			continue
+		}
+		if cur, ok := next[a.Supersedes]; ok && (target.SupersededBy == cur || target.SupersededBy != key && cur > key) {
+			continue
+		}
+		next[a.Supersedes] = key
+	}
+	return next
+}
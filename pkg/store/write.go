@@ -0,0 +1,588 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/glopal/sessions/internal/fsutil"
+	"github.com/glopal/sessions/internal/parser"
+	"github.com/glopal/sessions/internal/session"
+)
+
+// CreateSession writes a new session stamped with the current time. Only the
+// caller-settable fields of in (slug, summary, tags, files_changed, template,
+// git, body) are used; the ID, timestamp, artifacts and related sessions are
+// set by the store. The ID is the creation time in Unix seconds, with a ".N"
+// suffix if other sessions were created in the same second. A summary longer
+// than MaxSummaryLength, a files_changed entry without a path or with an
+// unknown action, or an invalid slug fails with ErrInvalid, and a slug
+// already in use with ErrExists.
+func (s *Store) CreateSession(in *Session) (*Session, error) {
+	if len(in.Summary) > MaxSummaryLength {
+		return nil, newError("creating session", "", ErrInvalid, "summary exceeds %d characters (%d given)", MaxSummaryLength, len(in.Summary))
+	}
+	for i, f := range in.FilesChanged {
+		if f.Path == "" {
+			return nil, newError("creating session", "", ErrInvalid, "files_changed[%d]: path is required", i)
+		}
+		if !slices.Contains(session.FileActions, f.Action) {
+			return nil, newError("creating session", "", ErrInvalid, "files_changed[%d]: invalid action %q (expected %s)", i, f.Action, strings.Join(session.FileActions, ", "))
+		}
+	}
+
+	now := time.Now()
+	sess := &Session{
+		Timestamp:       now,
+		Slug:            in.Slug,
+		Summary:         in.Summary,
+		Tags:            in.Tags,
+		FilesChanged:    in.FilesChanged,
+		Artifacts:       []ArtifactRef{},
+		RelatedSessions: []string{},
+		Template:        in.Template,
+		Git:             in.Git,
+		Body:            in.Body,
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err := s.checkSlug("", sess.Slug); err != nil {
+		return nil, err
+	}
+
+	// The store lock keeps other writers out, but files may also be created
+	// by older versions or by hand, so the create itself is exclusive too.
+	for seq := 1; ; seq++ {
+		sess.SessionID = session.FormatSessionID(now.Unix(), seq)
+		path := session.ResolveSessionPath(s.dir, sess.SessionID)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, &Error{Op: "creating session", Key: sess.SessionID, Err: err}
+		}
+		if fileExists(s.archivePath(path)) {
+			continue
+		}
+		err := parser.CreateSessionFile(path, sess)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, &Error{Op: "writing session", Key: sess.SessionID, Err: err}
+		}
+		s.reindex(path)
+		return sess, nil
+	}
+}
+
+// CreateArtifact writes a new artifact named name (a plain file name ending
+// in .md) and records it in the parent session's artifacts list. It fails
+// with ErrNotFound if the session does not exist, ErrExists if the artifact
+// does, and ErrInvalid if its summary is longer than MaxSummaryLength. If a.Supersedes is set, it is replaced by its canonical key
+// and the artifact it names is marked superseded, with a superseded_by
+// pointer back to the new one; a target that does not exist fails with
+// ErrNotFound, and one already superseded or that would close a cycle with
+// ErrInvalid.
+func (s *Store) CreateArtifact(sessionID, name string, a *Artifact) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".md") {
+		return newError("creating artifact", session.FormatArtifactKey(sessionID, name), ErrInvalid, "name must be a plain file name ending in .md")
+	}
+	if len(a.Summary) > MaxSummaryLength {
+		return newError("creating artifact", session.FormatArtifactKey(sessionID, name), ErrInvalid, "summary exceeds %d characters (%d given)", MaxSummaryLength, len(a.Summary))
+	}
+	sessionID, sessionPath, err := s.resolvePath(session.FormatSessionKey(sessionID))
+	if err != nil {
+		return err
+	}
+	key := session.FormatArtifactKey(sessionID, name)
+	artifactPath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	sess, err := parseSession(sessionID, sessionPath)
+	if err != nil {
+		return err
+	}
+	if s.isArchived(sessionPath) {
+		return newError("creating artifact", key, ErrInvalid, "session %s is archived; restore it first", sessionID)
+	}
+	if _, err := os.Stat(artifactPath); err == nil {
+		return newError("creating artifact", key, ErrExists, "artifact already exists")
+	}
+	if a.Supersedes != "" {
+		if a.Supersedes, err = s.checkSupersedes(key, a.Supersedes); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(artifactPath), 0755); err != nil {
+		return &Error{Op: "creating artifact", Key: key, Err: err}
+	}
+	if err := parser.WriteArtifactFile(artifactPath, a); err != nil {
+		return &Error{Op: "writing artifact", Key: key, Err: err}
+	}
+
+	sess.Artifacts = append(sess.Artifacts, ArtifactRef{
+		Path:    name,
+		Type:    a.Type,
+		Summary: a.Summary,
+	})
+	if err := parser.WriteSessionFile(sessionPath, sess); err != nil {
+		return &Error{Op: "updating session", Key: sessionID, Err: err}
+	}
+	s.reindex(append(s.markSuperseded(key, a.Supersedes, ""), artifactPath, sessionPath)...)
+	return nil
+}
+
+// UpdateSession re-reads session id from disk, applies fn and writes the
+// result back. If fn returns an error nothing is written. A changed slug must
+// be valid and unused, as for CreateSession.
+func (s *Store) UpdateSession(id string, fn func(*Session) error) error {
+	id, path, err := s.resolvePath(session.FormatSessionKey(id))
+	if err != nil {
+		return err
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	sess, err := parseSession(id, path)
+	if err != nil {
+		return err
+	}
+	slug := sess.Slug
+	if err := fn(sess); err != nil {
+		return err
+	}
+	if sess.Slug != slug {
+		if err := s.checkSlug(id, sess.Slug); err != nil {
+			return err
+		}
+	}
+	if err := s.writeSessionFile(path, sess); err != nil {
+		return &Error{Op: "writing session", Key: id, Err: err}
+	}
+	return nil
+}
+
+// UpdateArtifact re-reads the artifact at key from disk, applies fn and
+// writes the result back. The parent session's reference is kept in sync
+// with the artifact's type and summary, and a changed supersedes is checked
+// and recorded on the artifacts it names as for CreateArtifact. If fn
+// returns an error nothing is written.
+func (s *Store) UpdateArtifact(key string, fn func(*Artifact) error) error {
+	if _, _, isArtifact := session.ParseKey(key); !isArtifact {
+		return newError("updating artifact", key, ErrInvalid, "not an artifact key")
+	}
+	key, path, err := s.resolvePath(key)
+	if err != nil {
+		return err
+	}
+	sessionID, name, _ := session.ParseKey(key)
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	a, err := parseArtifact(key, path)
+	if err != nil {
+		return err
+	}
+	supersedes := a.Supersedes
+	if err := fn(a); err != nil {
+		return err
+	}
+	if a.Supersedes != supersedes && a.Supersedes != "" {
+		if a.Supersedes, err = s.checkSupersedes(key, a.Supersedes); err != nil {
+			return err
+		}
+	}
+	if err := parser.WriteArtifactFile(path, a); err != nil {
+		return &Error{Op: "writing artifact", Key: key, Err: err}
+	}
+	written := append(s.syncArtifactRef(sessionID, name, a), path)
+	if a.Supersedes != supersedes {
+		written = append(written, s.markSuperseded(key, a.Supersedes, supersedes)...)
+	}
+	s.reindex(written...)
+	return nil
+}
+
+// syncArtifactRef copies an artifact's type and summary into its session's
+// reference to it, returning the session's path if it was rewritten. The
+// caller must hold s.mu.
+func (s *Store) syncArtifactRef(sessionID, name string, a *Artifact) []string {
+	sessionPath, err := s.path(session.FormatSessionKey(sessionID))
+	if err != nil {
+		return nil
+	}
+	sess, err := parseSession(sessionID, sessionPath)
+	if err != nil {
+		return nil
+	}
+	changed := false
+	for i := range sess.Artifacts {
+		ref := &sess.Artifacts[i]
+		if ref.Path == name && (ref.Type != a.Type || ref.Summary != a.Summary) {
+			ref.Type = a.Type
+			ref.Summary = a.Summary
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if err := parser.WriteSessionFile(sessionPath, sess); err != nil {
+		s.warn(fmt.Errorf("updating session %s: %w", sessionID, err))
+		return nil
+	}
+	return []string{sessionPath}
+}
+
+// WriteRaw replaces the file of an existing session or artifact with data,
+// as written by hand. data must parse as that kind of document, a session's
+// session_id must match its key, and summaries must fit MaxSummaryLength;
+// otherwise it fails with ErrInvalid. A session's slug is checked as for
+// UpdateSession, and an artifact's supersedes as for UpdateArtifact. The
+// file is replaced atomically, and an artifact's type and summary are synced
+// into its session's reference.
+func (s *Store) WriteRaw(key string, data []byte) error {
+	key, path, err := s.resolvePath(key)
+	if err != nil {
+		return err
+	}
+	sessionID, name, isArtifact := session.ParseKey(key)
+
+	var summary, slug string
+	var art *Artifact
+	if isArtifact {
+		art, err = parser.ParseArtifact(string(data))
+		if err != nil {
+			return &Error{Op: "parsing artifact", Key: key, Kind: ErrInvalid, Err: err}
+		}
+		summary = art.Summary
+	} else {
+		sess, err := parser.ParseSession(string(data))
+		if err != nil {
+			return &Error{Op: "parsing session", Key: key, Kind: ErrInvalid, Err: err}
+		}
+		if sess.SessionID != sessionID {
+			return newError("parsing session", key, ErrInvalid, "session_id %q does not match the session's key", sess.SessionID)
+		}
+		summary, slug = sess.Summary, sess.Slug
+	}
+	if len(summary) > MaxSummaryLength {
+		return newError("writing", key, ErrInvalid, "summary exceeds %d characters (%d given)", MaxSummaryLength, len(summary))
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return newError("writing", key, ErrNotFound, "no such session or artifact")
+	}
+	if slug != "" {
+		if err := s.checkSlug(sessionID, slug); err != nil {
+			return err
+		}
+	}
+	var supersedes string
+	if isArtifact {
+		if old, err := parser.ParseArtifactFile(path); err == nil {
+			supersedes = old.Supersedes
+		}
+		if art.Supersedes != supersedes && art.Supersedes != "" {
+			if art.Supersedes, err = s.checkSupersedes(key, art.Supersedes); err != nil {
+				return err
+			}
+		}
+	}
+	if err := fsutil.WriteFileAtomic(path, data, 0644); err != nil {
+		return &Error{Op: "writing", Key: key, Err: err}
+	}
+	written := []string{path}
+	if isArtifact {
+		written = append(written, s.syncArtifactRef(sessionID, name, art)...)
+		if art.Supersedes != supersedes {
+			written = append(written, s.markSuperseded(key, art.Supersedes, supersedes)...)
+		}
+	}
+	s.reindex(written...)
+	return nil
+}
+
+// checkSlug checks that slug is valid and not used by a session other than
+// id. An empty slug is always allowed. The caller must hold the store lock.
+func (s *Store) checkSlug(id, slug string) error {
+	if slug == "" {
+		return nil
+	}
+	if err := session.ValidateSlug(slug); err != nil {
+		return &Error{Op: "setting slug", Key: id, Kind: ErrInvalid, Err: err}
+	}
+	ix, err := s.index()
+	if err != nil {
+		return err
+	}
+	for _, other := range slugSessions(ix.AllSessions(), slug) {
+		if other != id {
+			return newError("setting slug", id, ErrExists, "slug %q already used by session %s", slug, other)
+		}
+	}
+	return nil
+}
+
+// SetSummary sets the summary of the session or artifact at key. Summaries
+// longer than MaxSummaryLength are rejected with ErrInvalid.
+func (s *Store) SetSummary(key, summary string) error {
+	if len(summary) > MaxSummaryLength {
+		return newError("editing", key, ErrInvalid, "summary exceeds %d characters (%d given)", MaxSummaryLength, len(summary))
+	}
+	if _, _, isArtifact := session.ParseKey(key); isArtifact {
+		return s.UpdateArtifact(key, func(a *Artifact) error {
+			a.Summary = summary
+			return nil
+		})
+	}
+	return s.UpdateSession(key, func(sess *Session) error {
+		sess.Summary = summary
+		return nil
+	})
+}
+
+// AttachCommit records a commit as made as part of session id, reporting
+// whether it was not already recorded. sha must be a commit hash; callers
+// resolve refs with git first.
+func (s *Store) AttachCommit(id, sha string) (bool, error) {
+	sha = strings.ToLower(sha)
+	if len(sha) < session.MinCommitPrefix || strings.Trim(sha, "0123456789abcdef") != "" {
+		return false, newError("attaching commit", id, ErrInvalid, "%q is not a commit hash", sha)
+	}
+	added := false
+	err := s.UpdateSession(id, func(sess *Session) error {
+		if sess.Git == nil {
+			sess.Git = &GitInfo{}
+		}
+		if slices.Contains(sess.Git.Commits, sha) {
+			return nil
+		}
+		sess.Git.Commits = append(sess.Git.Commits, sha)
+		added = true
+		return nil
+	})
+	return added, err
+}
+
+// Link adds each session to the other's related_sessions.
+func (s *Store) Link(id1, id2 string) error {
+	id1, path1, err := s.resolvePath(session.FormatSessionKey(id1))
+	if err != nil {
+		return err
+	}
+	id2, path2, err := s.resolvePath(session.FormatSessionKey(id2))
+	if err != nil {
+		return err
+	}
+	if id1 == id2 {
+		return newError("linking", id1, ErrInvalid, "a session cannot be related to itself")
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s1, err := parseSession(id1, path1)
+	if err != nil {
+		return err
+	}
+	s2, err := parseSession(id2, path2)
+	if err != nil {
+		return err
+	}
+
+	addRelated(s1, id2)
+	addRelated(s2, id1)
+
+	if err := parser.WriteSessionFile(path1, s1); err != nil {
+		return &Error{Op: "writing session", Key: id1, Err: err}
+	}
+	if err := parser.WriteSessionFile(path2, s2); err != nil {
+		return &Error{Op: "writing session", Key: id2, Err: err}
+	}
+	s.reindex(path1, path2)
+	return nil
+}
+
+// Unlink removes each session from the other's related_sessions. A second
+// session that no longer exists is treated as already unlinked.
+func (s *Store) Unlink(id1, id2 string) error {
+	id1, path1, err := s.resolvePath(session.FormatSessionKey(id1))
+	if err != nil {
+		return err
+	}
+	id2, path2, err := s.resolvePath(session.FormatSessionKey(id2))
+	if err != nil {
+		return err
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s1, err := parseSession(id1, path1)
+	if err != nil {
+		return err
+	}
+	// A missing second session is already unlinked from its side.
+	s2, err := parseSession(id2, path2)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	s1.RelatedSessions = slices.DeleteFunc(s1.RelatedSessions, func(id string) bool { return id == id2 })
+	if err := parser.WriteSessionFile(path1, s1); err != nil {
+		return &Error{Op: "writing session", Key: id1, Err: err}
+	}
+	if s2 == nil {
+		s.reindex(path1)
+		return nil
+	}
+	s2.RelatedSessions = slices.DeleteFunc(s2.RelatedSessions, func(id string) bool { return id == id1 })
+	if err := parser.WriteSessionFile(path2, s2); err != nil {
+		return &Error{Op: "writing session", Key: id2, Err: err}
+	}
+	s.reindex(path1, path2)
+	return nil
+}
+
+// AutoLink links every pair of sessions that changed a common file, returning
+// the number of sessions updated. Sessions that fail to update are reported
+// through Warn and skipped.
+func (s *Store) AutoLink() (int, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	ix, err := s.index()
+	if err != nil {
+		return 0, err
+	}
+
+	// Build file -> session ID map
+	fileMap := make(map[string][]string)
+	for _, sess := range ix.Sessions() {
+		for _, f := range sess.FilesChanged {
+			fileMap[f.Path] = append(fileMap[f.Path], sess.SessionID)
+		}
+	}
+
+	// Find sessions that share files
+	links := make(map[string]map[string]bool)
+	for _, ids := range fileMap {
+		for i := 0; i < len(ids); i++ {
+			for j := i + 1; j < len(ids); j++ {
+				if links[ids[i]] == nil {
+					links[ids[i]] = make(map[string]bool)
+				}
+				if links[ids[j]] == nil {
+					links[ids[j]] = make(map[string]bool)
+				}
+				links[ids[i]][ids[j]] = true
+				links[ids[j]][ids[i]] = true
+			}
+		}
+	}
+
+	// Apply links to fresh copies so the cached sessions are never mutated
+	count := 0
+	var written []string
+	for id, related := range links {
+		path := session.ResolveSessionPath(s.dir, id)
+		sess, err := parseSession(id, path)
+		if err != nil {
+			s.warn(fmt.Errorf("failed to update %s: %w", id, err))
+			continue
+		}
+		changed := false
+		for relID := range related {
+			if addRelated(sess, relID) {
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		if err := parser.WriteSessionFile(path, sess); err != nil {
+			s.warn(fmt.Errorf("failed to update %s: %w", id, err))
+			continue
+		}
+		written = append(written, path)
+		count++
+	}
+	s.reindex(written...)
+	return count, nil
+}
+
+func addRelated(s *Session, id string) bool {
+	for _, existing := range s.RelatedSessions {
+		if existing == id {
+			return false
+		}
+	}
+	s.RelatedSessions = append(s.RelatedSessions, id)
+	return true
+}
+
+// parseSession parses a session file directly, bypassing the index, for
+// read-modify-write operations.
+func parseSession(id, path string) (*Session, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, newError("loading session", id, ErrNotFound, "no such session")
+	}
+	sess, err := parser.ParseSessionFile(path)
+	if err != nil {
+		return nil, &Error{Op: "loading session", Key: id, Err: err}
+	}
+	return sess, nil
+}
+
+// parseArtifact is the artifact counterpart of parseSession.
+func parseArtifact(key, path string) (*Artifact, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, newError("loading artifact", key, ErrNotFound, "no such artifact")
+	}
+	a, err := parser.ParseArtifactFile(path)
+	if err != nil {
+		return nil, &Error{Op: "loading artifact", Key: key, Err: err}
+	}
+	return a, nil
+}